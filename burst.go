@@ -0,0 +1,86 @@
+package dmr
+
+import (
+	"fmt"
+
+	"github.com/polkabana/go-dmr/crc/quadres_16_7"
+	"github.com/polkabana/go-dmr/fec"
+)
+
+// BuildSlotType returns the Slot Type bits for the given color code and data
+// type, protected by Golay (20, 8). See DMR AI spec. page 87.
+func BuildSlotType(colorCode, dataType uint8) []byte {
+	var bits = make([]byte, SlotTypeBits)
+	copy(bits, toBits((colorCode&B00001111)<<4|(dataType&B00001111)))
+	copy(bits[8:], fec.Golay_20_8_Parity(bits[:8]))
+	return bits
+}
+
+// BuildDataBurst assembles a burst from the (BPTC or trellis coded) info bits,
+// the Slot Type for the color code and data type and a BS sourced data SYNC.
+func BuildDataBurst(info []byte, colorCode, dataType uint8) ([]byte, error) {
+	if len(info) != InfoBits {
+		return nil, fmt.Errorf("dmr: expected %d info bits, got %d", InfoBits, len(info))
+	}
+
+	var (
+		bits     = make([]byte, PayloadBits)
+		slotType = BuildSlotType(colorCode, dataType)
+	)
+	copy(bits[:InfoHalfBits], info[:InfoHalfBits])
+	copy(bits[InfoHalfBits:], slotType[:SlotTypeHalfBits])
+	copy(bits[SyncOffsetBits:], BytesToBits(bsSourcedData))
+	copy(bits[SyncOffsetBits+SyncBits:], slotType[SlotTypeHalfBits:])
+	copy(bits[SyncOffsetBits+SyncBits+SlotTypeHalfBits:], info[InfoHalfBits:])
+
+	return BitsToBytes(bits), nil
+}
+
+// BuildEMB returns the EMB bits for the given color code and LCSS, protected
+// by QR (16, 7, 6). See DMR AI spec. page 96.
+func BuildEMB(colorCode, lcss uint8) []byte {
+	var bits = make([]byte, EMBBits)
+	copy(bits, toBits((colorCode&B00001111)<<4 | (lcss&B00000011)<<1)[:7])
+	copy(bits[7:], quadres_16_7.ParityBits(bits[:7]))
+	return bits
+}
+
+// BuildEmbeddedSignalling returns the SYNC field bits of a voice burst carrying
+// embedded signalling: the EMB with the 32 bit LC fragment in between.
+func BuildEmbeddedSignalling(colorCode, lcss uint8, fragment []byte) ([]byte, error) {
+	if len(fragment) != EMBSignallingLCFragmentBits {
+		return nil, fmt.Errorf("dmr: expected %d fragment bits, got %d", EMBSignallingLCFragmentBits, len(fragment))
+	}
+
+	var (
+		bits = make([]byte, SignalBits)
+		emb  = BuildEMB(colorCode, lcss)
+	)
+	copy(bits[:EMBHalfBits], emb[:EMBHalfBits])
+	copy(bits[EMBHalfBits:], fragment)
+	copy(bits[EMBHalfBits+EMBSignallingLCFragmentBits:], emb[EMBHalfBits:])
+	return bits, nil
+}
+
+// VoiceSyncBits returns the BS sourced voice SYNC bits, carried by voice burst A.
+func VoiceSyncBits() []byte {
+	return BytesToBits(bsSourcedVoice)
+}
+
+// BuildVoiceBurst assembles a voice burst from the voice bits, split around
+// the SYNC field carrying either a voice SYNC or embedded signalling.
+func BuildVoiceBurst(voice, signal []byte) ([]byte, error) {
+	if len(voice) != VoiceBits {
+		return nil, fmt.Errorf("dmr: expected %d voice bits, got %d", VoiceBits, len(voice))
+	}
+	if len(signal) != SignalBits {
+		return nil, fmt.Errorf("dmr: expected %d signal bits, got %d", SignalBits, len(signal))
+	}
+
+	var bits = make([]byte, PayloadBits)
+	copy(bits[:VoiceHalfBits], voice[:VoiceHalfBits])
+	copy(bits[VoiceHalfBits:], signal)
+	copy(bits[VoiceHalfBits+SignalBits:], voice[VoiceHalfBits:])
+
+	return BitsToBytes(bits), nil
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/polkabana/go-dmr/crc"
 )
 
 // Control Block Opcode
@@ -40,16 +42,7 @@ func (cb *ControlBlock) Bytes() ([]byte, error) {
 	data[8] = uint8(cb.SrcID >> 8)
 	data[9] = uint8(cb.SrcID)
 
-	// Calculate CRC16
-	for i := 0; i < 10; i++ {
-		crc16(&cb.CRC, data[i])
-	}
-	crc16end(&cb.CRC)
-
-	// Inverting according to the inversion polynomial.
-	cb.CRC = ^cb.CRC
-	// Applying CRC mask, see DMR AI spec. page 143.
-	cb.CRC ^= 0xa5a5
+	cb.CRC = crc.CCITT16(data[:10], crc.MaskCSBK)
 
 	data[10] = uint8(cb.CRC >> 8)
 	data[11] = uint8(cb.CRC)
@@ -235,17 +228,7 @@ func ParseControlBlock(data []byte) (*ControlBlock, error) {
 		return nil, fmt.Errorf("dmr: expected %d info bytes, got %d", InfoSize, len(data))
 	}
 
-	// Calculate CRC16
-	var crc uint16
-	for i := 0; i < 10; i++ {
-		crc16(&crc, data[i])
-	}
-	crc16end(&crc)
-
-	// Inverting according to the inversion polynomial
-	crc = ^crc
-	// Applying CRC mask, see DMR AI spec. page 143.
-	crc ^= 0xa5a5
+	var sum = crc.CCITT16(data[:10], crc.MaskCSBK)
 
 	// Check packet
 	if data[0]&B01000000 > 0 {
@@ -263,8 +246,8 @@ func ParseControlBlock(data []byte) (*ControlBlock, error) {
 		SrcID:  uint32(data[7])<<16 | uint32(data[8])<<8 | uint32(data[9]),
 	}
 
-	if crc != cb.CRC {
-		return nil, fmt.Errorf("dmr: control block CRC error (%#04x != %#04x)", crc, cb.CRC)
+	if sum != cb.CRC {
+		return nil, fmt.Errorf("dmr: control block CRC error (%#04x != %#04x)", sum, cb.CRC)
 	}
 
 	switch cb.Opcode {
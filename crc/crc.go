@@ -0,0 +1,72 @@
+// Package crc implements the CRC-CCITT and CRC-9 checksums used by the DMR
+// Air Interface, see DMR AI spec. page 139 (Annex B.3).
+package crc
+
+// CRC masks per data type, applied after inverting the checksum, see DMR AI
+// spec. page 143 (Table B.21).
+const (
+	MaskPIHeader    uint16 = 0x6969
+	MaskDataHeader  uint16 = 0xcccc
+	MaskCSBK        uint16 = 0xa5a5
+	MaskMBCHeader   uint16 = 0xaaaa
+	MaskMBCContinue uint16 = 0xaaaa
+	MaskUSBD        uint16 = 0x3333
+	MaskRate12Data  uint16 = 0x00f0
+	MaskRate34Data  uint16 = 0x01ff
+	MaskRate1Data   uint16 = 0x010f
+)
+
+// G(x) = x^16+x^12+x^5+1
+const ccittPoly = 0x1021
+
+// G(x) = x^9+x^6+x^4+x^3+1
+const crc9Poly = 0x0059
+
+// CCITT16 returns the 16-bit CRC-CCITT of data, with an initial value of zero,
+// inverted and XORed with the data type specific mask.
+func CCITT16(data []byte, mask uint16) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ ccittPoly
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return ^crc ^ mask
+}
+
+// CheckCCITT16 verifies the CRC-CCITT in the last two bytes of data.
+func CheckCCITT16(data []byte, mask uint16) bool {
+	if len(data) < 2 {
+		return false
+	}
+	var n = len(data) - 2
+	return CCITT16(data[:n], mask) == uint16(data[n])<<8|uint16(data[n+1])
+}
+
+// CRC9 returns the 9-bit CRC of a data block, calculated over the data bytes
+// followed by the 7-bit block serial number, with an initial value of zero,
+// inverted and XORed with the data type specific mask.
+func CRC9(data []byte, serial uint8, mask uint16) uint16 {
+	var crc uint16
+	update := func(b uint8, bits int) {
+		for i := bits - 1; i >= 0; i-- {
+			var in = uint16(b>>uint(i)) & 0x01
+			if (crc>>8)&0x01 != in {
+				crc = ((crc << 1) ^ crc9Poly) & 0x01ff
+			} else {
+				crc = (crc << 1) & 0x01ff
+			}
+		}
+	}
+	for _, b := range data {
+		update(b, 8)
+	}
+	update(serial, 7)
+
+	return (^crc ^ mask) & 0x01ff
+}
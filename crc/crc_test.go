@@ -0,0 +1,69 @@
+package crc
+
+import "testing"
+
+func TestCCITT16(t *testing.T) {
+	tests := []struct {
+		data []byte
+		mask uint16
+		want uint16
+	}{
+		// CRC-16/XMODEM check value 0x31c3, inverted
+		{[]byte("123456789"), 0, 0xce3c},
+		// Preamble CSBK, see the bptc test vectors
+		{[]byte{0xbd, 0x00, 0x80, 0x03, 0x1f, 0x29, 0x66, 0x1f, 0x2c, 0xa4}, MaskCSBK, 0x667e},
+		// All zero blocks leave the register zero, so only the inversion and
+		// the Table B.21 mask remain
+		{make([]byte, 10), MaskPIHeader, 0x9696},
+		{make([]byte, 10), MaskDataHeader, 0x3333},
+		{make([]byte, 10), MaskCSBK, 0x5a5a},
+		{make([]byte, 10), MaskMBCHeader, 0x5555},
+		{make([]byte, 10), MaskUSBD, 0xcccc},
+	}
+
+	for _, test := range tests {
+		if got := CCITT16(test.data, test.mask); got != test.want {
+			t.Fatalf("CCITT16(%x, %#04x): got %#04x, want %#04x", test.data, test.mask, got, test.want)
+		}
+	}
+
+	var csbk = []byte{0xbd, 0x00, 0x80, 0x03, 0x1f, 0x29, 0x66, 0x1f, 0x2c, 0xa4, 0x66, 0x7e}
+	if !CheckCCITT16(csbk, MaskCSBK) {
+		t.Fatal("expected CSBK CRC to check")
+	}
+	if CheckCCITT16(csbk, MaskDataHeader) {
+		t.Fatal("expected CSBK CRC with data header mask to fail")
+	}
+	csbk[3] ^= 0x10
+	if CheckCCITT16(csbk, MaskCSBK) {
+		t.Fatal("expected corrupted CSBK CRC to fail")
+	}
+}
+
+func TestCRC9(t *testing.T) {
+	var seq = make([]byte, 16)
+	for i := range seq {
+		seq[i] = byte(i)
+	}
+
+	tests := []struct {
+		data   []byte
+		serial uint8
+		mask   uint16
+		want   uint16
+	}{
+		// All zero blocks, see TestCCITT16
+		{make([]byte, 12), 0, 0, 0x01ff},
+		{make([]byte, 10), 0, MaskRate12Data, 0x010f},
+		{make([]byte, 16), 0, MaskRate34Data, 0x0000},
+		{make([]byte, 22), 0, MaskRate1Data, 0x00f0},
+		{seq[1:13], 5, MaskRate12Data, 0x006f},
+		{seq, 42, MaskRate34Data, 0x00e0},
+	}
+
+	for _, test := range tests {
+		if got := CRC9(test.data, test.serial, test.mask); got != test.want {
+			t.Fatalf("CRC9(%x, %d, %#03x): got %#03x, want %#03x", test.data, test.serial, test.mask, got, test.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/polkabana/go-dmr/crc"
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/encoding/unicode"
@@ -15,28 +16,16 @@ const (
 	MaxPacketFragmentSize = 1500
 )
 
-// CRC Masks for data block's CRC-9 calculation, see DMR AI spec. page 148 (Table B.21).
-var crc9Masks = map[uint8]uint16{
-	Rate12Data: 0x00f0,
-	Rate34Data: 0x01ff,
-	//Rate1Data: 0x010f,
-}
-
-func calculateCRC9(serial uint8, data []byte, dataType uint8) (crc uint16) {
-	for _, block := range data {
-		crc9(&crc, block, 8)
+// crc9Mask returns the mask of a data block's CRC-9 for the data type.
+func crc9Mask(dataType uint8) uint16 {
+	switch dataType {
+	case Rate12Data:
+		return crc.MaskRate12Data
+	case Rate34Data:
+		return crc.MaskRate34Data
+	default:
+		return 0
 	}
-	crc9(&crc, serial, 7)
-	crc9end(&crc, 8)
-
-	// Inverting according to the inversion polynomial.
-	crc = ^crc
-	crc &= 0x01ff
-
-	// Applying Data Type CRC Mask
-	crc ^= crc9Masks[dataType]
-
-	return crc
 }
 
 type DataBlock struct {
@@ -49,7 +38,7 @@ type DataBlock struct {
 
 func ParseDataBlock(data []byte, dataType uint8, confirmed bool) (*DataBlock, error) {
 	var (
-		crc uint16
+		sum uint16
 		db  = &DataBlock{
 			Length: dataBlockLength(dataType, confirmed),
 		}
@@ -61,11 +50,11 @@ func ParseDataBlock(data []byte, dataType uint8, confirmed bool) (*DataBlock, er
 		db.Data = make([]byte, db.Length)
 		copy(db.Data, data[2:2+db.Length])
 
-		crc = calculateCRC9(db.Serial, db.Data, dataType)
+		sum = crc.CRC9(db.Data, db.Serial, crc9Mask(dataType))
 
 		// FIXME(pd0mz): this is not working
-		if crc != db.CRC {
-			return nil, fmt.Errorf("dmr: block CRC error (%#04x != %#04x)", crc, db.CRC)
+		if sum != db.CRC {
+			return nil, fmt.Errorf("dmr: block CRC error (%#04x != %#04x)", sum, db.CRC)
 		}
 	} else {
 		db.Data = make([]byte, db.Length)
@@ -83,7 +72,7 @@ func (db *DataBlock) Bytes(dataType uint8, confirmed bool) []byte {
 	)
 
 	if confirmed {
-		db.CRC = calculateCRC9(db.Serial, db.Data, dataType)
+		db.CRC = crc.CRC9(db.Data, db.Serial, crc9Mask(dataType))
 
 		// Grow data slice to support the two byte prefix
 		data = append(data, make([]byte, 2)...)
@@ -184,7 +173,7 @@ func (df *DataFragment) DataBlocks(dataType uint8, confirm bool) ([]*DataBlock,
 		}
 
 		// Calculate block CRC9
-		block.CRC = calculateCRC9(block.Serial, block.Data, dataType)
+		block.CRC = crc.CRC9(block.Data, block.Serial, crc9Mask(dataType))
 
 		blocks[i] = block
 	}
@@ -3,6 +3,8 @@ package dmr
 import (
 	"fmt"
 	"strings"
+
+	"github.com/polkabana/go-dmr/crc"
 )
 
 // Data Header Packet Format
@@ -236,16 +238,7 @@ func (h *DataHeader) Bytes() ([]byte, error) {
 		}
 	}
 
-	h.CRC = 0
-	for i := 0; i < 10; i++ {
-		crc16(&h.CRC, data[i])
-	}
-	crc16end(&h.CRC)
-
-	// Inverting according to the inversion polynomial.
-	h.CRC = ^h.CRC
-	// Applying CRC mask, see DMR AI spec. page 143.
-	h.CRC ^= 0xcccc
+	h.CRC = crc.CCITT16(data[:10], crc.MaskDataHeader)
 
 	data[10] = uint8(h.CRC >> 8)
 	data[11] = uint8(h.CRC)
@@ -529,15 +522,8 @@ func ParseDataHeader(data []byte, proprietary bool) (*DataHeader, error) {
 }
 
 func dataHeaderCRC(data []byte) uint16 {
-	var crc uint16
 	if len(data) < 10 {
-		return crc
+		return 0
 	}
-
-	for i := 0; i < 10; i++ {
-		crc16(&crc, data[i])
-	}
-	crc16end(&crc)
-
-	return (^crc) ^ 0xcccc
+	return crc.CCITT16(data[:10], crc.MaskDataHeader)
 }
@@ -24,7 +24,7 @@ func Golay_20_8_Check(bits []byte) error {
 		return fmt.Errorf("fec/golay_20_8: expected 20 bits, got %d", len(bits))
 	}
 	parity := Golay_20_8_Parity(bits[:8])
-	for i := 0; i < 20; i++ {
+	for i := range parity {
 		if parity[i] != bits[8+i] {
 			return fmt.Errorf("fec/golay_20_8: parity error at bit %d: %v != %v", i, parity, bits[8:])
 		}
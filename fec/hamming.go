@@ -0,0 +1,100 @@
+package fec
+
+import "fmt"
+
+// hamming is a systematic Hamming code operating on bit slices, with the
+// data bits followed by the parity bits.
+type hamming struct {
+	name     string
+	n, k     int
+	parity   func(bits []byte) []byte
+	syndrome map[uint32]int // syndrome to erroneous bit position
+}
+
+func newHamming(name string, n, k int, parity func([]byte) []byte) *hamming {
+	h := &hamming{name: name, n: n, k: k, parity: parity, syndrome: map[uint32]int{}}
+	for i := 0; i < n; i++ {
+		var bits = make([]byte, n)
+		bits[i] = 1
+		h.syndrome[h.calcSyndrome(bits)] = i
+	}
+	return h
+}
+
+func (h *hamming) calcSyndrome(bits []byte) uint32 {
+	var s uint32
+	for i, p := range h.parity(bits[:h.k]) {
+		if p != bits[h.k+i] {
+			s |= 1 << uint(i)
+		}
+	}
+	return s
+}
+
+func (h *hamming) encode(bits []byte) ([]byte, error) {
+	if len(bits) != h.k {
+		return nil, fmt.Errorf("fec/%s: expected %d data bits, got %d", h.name, h.k, len(bits))
+	}
+	var out = make([]byte, h.n)
+	copy(out, bits)
+	copy(out[h.k:], h.parity(bits))
+	return out, nil
+}
+
+func (h *hamming) decode(bits []byte) ([]byte, error) {
+	if len(bits) != h.n {
+		return nil, fmt.Errorf("fec/%s: expected %d bits, got %d", h.name, h.n, len(bits))
+	}
+	if s := h.calcSyndrome(bits); s != 0 {
+		i, ok := h.syndrome[s]
+		if !ok {
+			return nil, fmt.Errorf("fec/%s: uncorrectable error, syndrome %#02x", h.name, s)
+		}
+		bits[i] ^= 1
+	}
+	var data = make([]byte, h.k)
+	copy(data, bits[:h.k])
+	return data, nil
+}
+
+var (
+	hamming7_4 = newHamming("hamming_7_4", 7, 4, func(d []byte) []byte {
+		return []byte{
+			d[0] ^ d[1] ^ d[2],
+			d[1] ^ d[2] ^ d[3],
+			d[0] ^ d[1] ^ d[3],
+		}
+	})
+	hamming17_12 = newHamming("hamming_17_12", 17, 12, func(d []byte) []byte {
+		return []byte{
+			d[0] ^ d[1] ^ d[2] ^ d[3] ^ d[6] ^ d[7] ^ d[9],
+			d[0] ^ d[1] ^ d[2] ^ d[3] ^ d[4] ^ d[7] ^ d[8] ^ d[10],
+			d[1] ^ d[2] ^ d[3] ^ d[4] ^ d[5] ^ d[8] ^ d[9] ^ d[11],
+			d[0] ^ d[1] ^ d[4] ^ d[5] ^ d[7] ^ d[10],
+			d[0] ^ d[2] ^ d[5] ^ d[6] ^ d[8] ^ d[11],
+		}
+	})
+)
+
+// Hamming7_4_Encode returns the Hamming(7, 4, 3) codeword for 4 data bits.
+func Hamming7_4_Encode(bits []byte) ([]byte, error) {
+	return hamming7_4.encode(bits)
+}
+
+// Hamming7_4_Decode corrects a single bit error in the 7 bit codeword, in
+// place, and returns the 4 data bits.
+func Hamming7_4_Decode(bits []byte) ([]byte, error) {
+	return hamming7_4.decode(bits)
+}
+
+// Hamming17_12_Encode returns the Hamming(17, 12, 3) codeword for 12 data bits.
+func Hamming17_12_Encode(bits []byte) ([]byte, error) {
+	return hamming17_12.encode(bits)
+}
+
+// Hamming17_12_Decode corrects a single bit error in the 17 bit codeword, in
+// place, and returns the 12 data bits. Syndromes that don't map to a single
+// bit error are reported as uncorrectable.
+func Hamming17_12_Decode(bits []byte) ([]byte, error) {
+	return hamming17_12.decode(bits)
+}
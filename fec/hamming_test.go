@@ -0,0 +1,71 @@
+package fec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func bitString(s string) []byte {
+	var bits = make([]byte, len(s))
+	for i, c := range s {
+		if c == '1' {
+			bits[i] = 1
+		}
+	}
+	return bits
+}
+
+func testHamming(t *testing.T, vectors map[string]string, encode, decode func([]byte) ([]byte, error)) {
+	for data, codeword := range vectors {
+		want := bitString(codeword)
+		test, err := encode(bitString(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(test, want) {
+			t.Fatalf("encode %s: got %v, want %s", data, test, codeword)
+		}
+
+		// Every single bit error is corrected
+		for i := range want {
+			var bits = make([]byte, len(want))
+			copy(bits, want)
+			bits[i] ^= 1
+
+			test, err := decode(bits)
+			if err != nil {
+				t.Fatalf("decode %s with bit %d flipped: %v", codeword, i, err)
+			}
+			if !bytes.Equal(test, bitString(data)) || !bytes.Equal(bits, want) {
+				t.Fatalf("decode %s with bit %d flipped: got %v", codeword, i, test)
+			}
+		}
+	}
+}
+
+func TestHamming7_4(t *testing.T) {
+	testHamming(t, map[string]string{
+		"0000": "0000000",
+		"1011": "1011000",
+		"0110": "0110001",
+		"1111": "1111111",
+	}, Hamming7_4_Encode, Hamming7_4_Decode)
+}
+
+func TestHamming17_12(t *testing.T) {
+	testHamming(t, map[string]string{
+		"000000000000": "00000000000000000",
+		"101100101110": "10110010111011000",
+		"000011110101": "00001111010110011",
+	}, Hamming17_12_Encode, Hamming17_12_Decode)
+
+	// Two parity bit errors don't map to a single bit error
+	bits := bitString("10110010111011011")
+	if _, err := Hamming17_12_Decode(bits); err == nil {
+		t.Fatal("expected uncorrectable error")
+	}
+
+	if _, err := Hamming17_12_Decode(make([]byte, 12)); err == nil {
+		t.Fatal("expected length error")
+	}
+}
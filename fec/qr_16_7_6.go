@@ -0,0 +1,65 @@
+package fec
+
+import (
+	"fmt"
+
+	"github.com/polkabana/go-dmr/crc/quadres_16_7"
+)
+
+// qr16_7_6Codewords holds all 128 codewords, indexed by their data bits.
+var qr16_7_6Codewords [128][]byte
+
+func init() {
+	for i := range qr16_7_6Codewords {
+		var bits = make([]byte, 7)
+		for j := range bits {
+			bits[j] = byte(i>>uint(6-j)) & 0x01
+		}
+		qr16_7_6Codewords[i] = append(bits, quadres_16_7.ParityBits(bits)...)
+	}
+}
+
+// QR_16_7_6_Encode returns the Quadratic Residue (16, 7, 6) codeword for 7
+// data bits, as used to protect the EMB and the short LC.
+func QR_16_7_6_Encode(bits []byte) ([]byte, error) {
+	if len(bits) != 7 {
+		return nil, fmt.Errorf("fec/qr_16_7_6: expected 7 data bits, got %d", len(bits))
+	}
+	var i int
+	for _, b := range bits {
+		i = i<<1 | int(b&0x01)
+	}
+	var out = make([]byte, 16)
+	copy(out, qr16_7_6Codewords[i])
+	return out, nil
+}
+
+// QR_16_7_6_Decode corrects up to two bit errors in the 16 bit codeword, in
+// place, and returns the 7 data bits. With a minimum distance of 6, three
+// bit errors are detected and reported as uncorrectable.
+func QR_16_7_6_Decode(bits []byte) ([]byte, error) {
+	if len(bits) != 16 {
+		return nil, fmt.Errorf("fec/qr_16_7_6: expected 16 bits, got %d", len(bits))
+	}
+
+	var best, distance = 0, 17
+	for i, codeword := range qr16_7_6Codewords {
+		var d int
+		for j, b := range codeword {
+			if b != bits[j] {
+				d++
+			}
+		}
+		if d < distance {
+			best, distance = i, d
+		}
+	}
+	if distance > 2 {
+		return nil, fmt.Errorf("fec/qr_16_7_6: uncorrectable error, %d bits from nearest codeword", distance)
+	}
+
+	copy(bits, qr16_7_6Codewords[best])
+	var data = make([]byte, 7)
+	copy(data, bits[:7])
+	return data, nil
+}
@@ -0,0 +1,61 @@
+package fec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQR_16_7_6(t *testing.T) {
+	// Codewords as packed 16 bit values, data bits in the 7 most significant bits.
+	tests := []uint16{0x0000, 0x0273, 0x04e5, 0x0696, 0x09c9, 0x11e2}
+
+	for _, test := range tests {
+		var (
+			want = bitString(fmtBits(test))
+			data = want[:7]
+		)
+		codeword, err := QR_16_7_6_Encode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(codeword, want) {
+			t.Fatalf("encode %#04x: got %v", test, codeword)
+		}
+
+		// Up to two bit errors are corrected
+		for i := 0; i < 16; i++ {
+			for j := i; j < 16; j++ {
+				var bits = make([]byte, 16)
+				copy(bits, want)
+				bits[i] ^= 1
+				bits[j] ^= 1
+
+				decoded, err := QR_16_7_6_Decode(bits)
+				if err != nil {
+					t.Fatalf("decode %#04x with bits %d, %d flipped: %v", test, i, j, err)
+				}
+				if !bytes.Equal(decoded, data) {
+					t.Fatalf("decode %#04x with bits %d, %d flipped: got %v", test, i, j, decoded)
+				}
+			}
+		}
+
+		// Three bit errors are detected
+		var bits = make([]byte, 16)
+		copy(bits, want)
+		bits[0] ^= 1
+		bits[7] ^= 1
+		bits[15] ^= 1
+		if _, err := QR_16_7_6_Decode(bits); err == nil {
+			t.Fatalf("decode %#04x with 3 bits flipped: expected error", test)
+		}
+	}
+}
+
+func fmtBits(v uint16) string {
+	var s = make([]byte, 16)
+	for i := range s {
+		s[i] = '0' + byte(v>>uint(15-i))&0x01
+	}
+	return string(s)
+}
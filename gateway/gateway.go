@@ -0,0 +1,217 @@
+// Package gateway bridges DMR voice streams to an analog gateway, such as
+// AllStarLink. The library takes care of the framing and the stream lifecycle,
+// the gateway transcodes between AMBE and PCM outside of the library.
+package gateway
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/lc"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+var log = logging.MustGetLogger("dmr/gateway")
+
+// AMBEFrameSize is the size of the three AMBE frames carried in a voice burst.
+const AMBEFrameSize = dmr.VoiceBits / 8
+
+// StreamTimeout is the default time after which a stream that went silent
+// without a terminator is ended.
+const StreamTimeout = time.Second * 2
+
+// Adapter is implemented by the gateway.
+type Adapter interface {
+	// StartStream is called when a voice stream starts.
+	StartStream(*dmr.Packet)
+
+	// WriteAudio receives the AMBE frames of a voice burst.
+	WriteAudio([]byte)
+
+	// ReadAudio returns the AMBE frames for the next voice burst to transmit,
+	// or nil if the transmission has ended.
+	ReadAudio() []byte
+
+	// EndStream is called when a voice stream ends. For a stream that timed
+	// out, it's called from the timer goroutine of the Bridge.
+	EndStream(*dmr.Packet)
+}
+
+// Bridge passes the voice streams received by a repeater to an Adapter, and
+// transmits the audio read from the Adapter.
+type Bridge struct {
+	Repeater  dmr.Repeater
+	Adapter   Adapter
+	ColorCode uint8
+
+	// Interval between transmitted bursts, zero disables pacing.
+	Interval time.Duration
+
+	// TalkerAlias is embedded in the transmitted streams, if set.
+	TalkerAlias string
+
+	// StreamTimeout ends a received stream that went silent without a
+	// terminator, zero disables it.
+	StreamTimeout time.Duration
+
+	mutex    sync.Mutex
+	streamID [2]uint32
+	last     [2]*dmr.Packet
+	heard    [2]time.Time
+	timer    [2]*time.Timer
+}
+
+// New sets up a Bridge handling the packets received by the repeater.
+func New(r dmr.Repeater, a Adapter, colorCode uint8) *Bridge {
+	b := &Bridge{
+		Repeater:      r,
+		Adapter:       a,
+		ColorCode:     colorCode,
+		Interval:      voice.BurstDuration,
+		StreamTimeout: StreamTimeout,
+	}
+
+	r.SetPacketFunc(b.handlePacket)
+	return b
+}
+
+// Transmit sends a voice stream from srcID to dstID with the audio read from
+// the Adapter, until it returns nil.
+func (b *Bridge) Transmit(srcID, dstID uint32, callType, timeslot uint8) error {
+	builder, err := voice.NewBuilder(srcID, dstID, callType, timeslot, b.ColorCode)
+	if err != nil {
+		return err
+	}
+	if b.TalkerAlias != "" {
+		if err := builder.TalkerAlias(b.TalkerAlias, lc.FormatUTF8); err != nil {
+			return err
+		}
+	}
+
+	p, err := builder.Header()
+	if err != nil {
+		return err
+	}
+	if err := b.send(p); err != nil {
+		return err
+	}
+	for {
+		audio := b.Adapter.ReadAudio()
+		if audio == nil {
+			break
+		}
+		if len(audio) != AMBEFrameSize {
+			return errors.New("gateway: invalid audio frame size")
+		}
+		if p, err = builder.Voice(dmr.BytesToBits(audio)); err != nil {
+			return err
+		}
+		if err := b.send(p); err != nil {
+			return err
+		}
+	}
+	if p, err = builder.Terminator(); err != nil {
+		return err
+	}
+	return b.send(p)
+}
+
+func (b *Bridge) send(p *dmr.Packet) error {
+	if err := b.Repeater.Send(p); err != nil {
+		return err
+	}
+	if b.Interval > 0 {
+		time.Sleep(b.Interval)
+	}
+	return nil
+}
+
+func (b *Bridge) handlePacket(_ dmr.Repeater, p *dmr.Packet) error {
+	if p.Timeslot > 1 {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var (
+		now    = time.Now()
+		active = b.streamID[p.Timeslot]
+	)
+	switch p.DataType {
+	case dmr.VoiceLC:
+		if active == p.StreamID {
+			return nil
+		}
+		if active != 0 {
+			log.Debugf("gateway: ignored stream %#08x on TS%d, stream %#08x active\n", p.StreamID, p.Timeslot+1, active)
+			return nil
+		}
+		b.start(p, now)
+
+	case dmr.VoiceBurstA, dmr.VoiceBurstB, dmr.VoiceBurstC, dmr.VoiceBurstD, dmr.VoiceBurstE, dmr.VoiceBurstF:
+		if active == 0 {
+			// Late entry, we missed the voice LC header.
+			b.start(p, now)
+		} else if active != p.StreamID {
+			return nil
+		}
+		b.touch(p, now)
+		b.Adapter.WriteAudio(dmr.BitsToBytes(p.VoiceBits()))
+
+	case dmr.TerminatorWithLC:
+		if active != p.StreamID {
+			return nil
+		}
+		b.end(p)
+	}
+
+	return nil
+}
+
+func (b *Bridge) start(p *dmr.Packet, now time.Time) {
+	b.streamID[p.Timeslot] = p.StreamID
+	b.touch(p, now)
+	b.Adapter.StartStream(p)
+}
+
+func (b *Bridge) touch(p *dmr.Packet, now time.Time) {
+	b.last[p.Timeslot] = p
+	b.heard[p.Timeslot] = now
+	if b.StreamTimeout <= 0 {
+		return
+	}
+	if timer := b.timer[p.Timeslot]; timer != nil {
+		timer.Reset(b.StreamTimeout)
+		return
+	}
+	var timeslot = p.Timeslot
+	b.timer[timeslot] = time.AfterFunc(b.StreamTimeout, func() { b.expire(timeslot) })
+}
+
+// expire ends the stream on the timeslot if it went silent for StreamTimeout.
+// The timer may fire while a frame resets it, so the time the stream was last
+// heard is checked again.
+func (b *Bridge) expire(timeslot uint8) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var active = b.streamID[timeslot]
+	if active == 0 || time.Since(b.heard[timeslot]) < b.StreamTimeout {
+		return
+	}
+	log.Debugf("gateway: stream %#08x on TS%d timed out\n", active, timeslot+1)
+	b.end(b.last[timeslot])
+}
+
+func (b *Bridge) end(p *dmr.Packet) {
+	b.streamID[p.Timeslot] = 0
+	b.last[p.Timeslot] = nil
+	if timer := b.timer[p.Timeslot]; timer != nil {
+		timer.Stop()
+	}
+	b.Adapter.EndStream(p)
+}
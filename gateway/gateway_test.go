@@ -0,0 +1,249 @@
+package gateway
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+// testRepeater records the packets sent.
+type testRepeater struct {
+	pf   dmr.PacketFunc
+	sent []*dmr.Packet
+}
+
+func (r *testRepeater) Active() bool                   { return true }
+func (r *testRepeater) Close() error                   { return nil }
+func (r *testRepeater) ListenAndServe() error          { return nil }
+func (r *testRepeater) GetPacketFunc() dmr.PacketFunc  { return r.pf }
+func (r *testRepeater) SetPacketFunc(f dmr.PacketFunc) { r.pf = f }
+func (r *testRepeater) Send(p *dmr.Packet) error {
+	r.sent = append(r.sent, p)
+	return nil
+}
+
+// testCodec is a stub codec recording the calls made by the bridge, and
+// returning numbered audio frames.
+type testCodec struct {
+	mutex  sync.Mutex
+	calls  []string
+	frames [][]byte
+}
+
+func (c *testCodec) call(call string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls = append(c.calls, call)
+}
+
+// called returns the calls made so far.
+func (c *testCodec) called() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return fmt.Sprint(c.calls)
+}
+
+func (c *testCodec) StartStream(p *dmr.Packet) {
+	c.call(fmt.Sprintf("start %d->%d", p.SrcID, p.DstID))
+}
+
+func (c *testCodec) WriteAudio(audio []byte) {
+	c.call(fmt.Sprintf("write %d", audio[0]))
+}
+
+func (c *testCodec) ReadAudio() []byte {
+	if len(c.frames) == 0 {
+		return nil
+	}
+	audio := c.frames[0]
+	c.frames = c.frames[1:]
+	return audio
+}
+
+func (c *testCodec) EndStream(p *dmr.Packet) {
+	c.call("end")
+}
+
+func testFrame(n uint8) []byte {
+	return bytes.Repeat([]byte{n}, AMBEFrameSize)
+}
+
+func TestBridgeLifecycle(t *testing.T) {
+	var (
+		repeater = &testRepeater{}
+		codec    = &testCodec{}
+		bridge   = New(repeater, codec, 1)
+	)
+	bridge.Interval = 0
+
+	builder, err := voice.NewBuilder(2042214, 91, dmr.CallTypeGroup, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := voice.NewBuilder(2042215, 92, dmr.CallTypeGroup, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packets []*dmr.Packet
+	add := func(p *dmr.Packet, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, p)
+	}
+	add(builder.Header())
+	add(other.Header())
+	for i := uint8(1); i <= 3; i++ {
+		add(builder.Voice(dmr.BytesToBits(testFrame(i))))
+		add(other.Voice(dmr.BytesToBits(testFrame(i + 10))))
+	}
+	add(builder.Terminator())
+	add(other.Terminator())
+
+	for _, p := range packets {
+		if err := repeater.pf(repeater, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"start 2042214->91", "write 1", "write 2", "write 3", "end"}
+	if got := codec.called(); got != fmt.Sprint(want) {
+		t.Fatalf("expected calls %v, got %v", want, got)
+	}
+}
+
+func TestBridgeTransmit(t *testing.T) {
+	var (
+		repeater = &testRepeater{}
+		codec    = &testCodec{frames: [][]byte{testFrame(1), testFrame(2), testFrame(3)}}
+		bridge   = New(repeater, codec, 1)
+	)
+	bridge.Interval = 0
+
+	if err := bridge.Transmit(2042214, 91, dmr.CallTypeGroup, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(repeater.sent) != 5 {
+		t.Fatalf("expected 5 packets, got %d", len(repeater.sent))
+	}
+
+	var dataTypes = []uint8{dmr.VoiceLC, dmr.VoiceBurstA, dmr.VoiceBurstB, dmr.VoiceBurstC, dmr.TerminatorWithLC}
+	for i, p := range repeater.sent {
+		if p.DataType != dataTypes[i] {
+			t.Fatalf("packet %d: expected %s, got %s", i, dmr.DataTypeName[dataTypes[i]], dmr.DataTypeName[p.DataType])
+		}
+		if p.Timeslot != 1 || p.SrcID != 2042214 || p.DstID != 91 {
+			t.Fatalf("packet %d: unexpected addressing %+v", i, p)
+		}
+		if i > 0 && i < 4 && !bytes.Equal(dmr.BitsToBytes(p.VoiceBits()), testFrame(uint8(i))) {
+			t.Fatalf("packet %d: audio mismatch", i)
+		}
+	}
+
+	codec.frames = [][]byte{{0x00}}
+	if err := bridge.Transmit(2042214, 91, dmr.CallTypeGroup, 1); err == nil {
+		t.Fatal("expected short audio frame to fail")
+	}
+}
+
+func TestBridgeStreamTimeout(t *testing.T) {
+	var (
+		repeater = &testRepeater{}
+		codec    = &testCodec{}
+		bridge   = New(repeater, codec, 1)
+	)
+	bridge.StreamTimeout = 50 * time.Millisecond
+
+	builder, err := voice.NewBuilder(2042214, 91, dmr.CallTypeGroup, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := voice.NewBuilder(2042215, 92, dmr.CallTypeGroup, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handle := func(p *dmr.Packet, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repeater.pf(repeater, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first stream loses its terminator.
+	handle(builder.Header())
+	handle(builder.Voice(dmr.BytesToBits(testFrame(1))))
+
+	// Another stream is ignored while the first is within the timeout.
+	handle(other.Header())
+	want := []string{"start 2042214->91", "write 1"}
+	if got := codec.called(); got != fmt.Sprint(want) {
+		t.Fatalf("expected calls %v, got %v", want, got)
+	}
+
+	// The first stream is ended without any further packet on the slot.
+	want = append(want, "end")
+	for deadline := time.Now().Add(time.Second); codec.called() != fmt.Sprint(want); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected calls %v, got %v", want, codec.called())
+		}
+	}
+
+	// And the next stream is taken.
+	handle(other.Header())
+	handle(other.Voice(dmr.BytesToBits(testFrame(11))))
+	want = append(want, "start 2042215->92", "write 11")
+	if got := codec.called(); got != fmt.Sprint(want) {
+		t.Fatalf("expected calls %v, got %v", want, got)
+	}
+}
+
+func TestBridgeStreamTimeoutReset(t *testing.T) {
+	var (
+		repeater = &testRepeater{}
+		codec    = &testCodec{}
+		bridge   = New(repeater, codec, 1)
+	)
+	bridge.StreamTimeout = 100 * time.Millisecond
+
+	builder, err := voice.NewBuilder(2042214, 91, dmr.CallTypeGroup, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := builder.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repeater.pf(repeater, p)
+
+	// Each frame restarts the timeout.
+	for i := uint8(1); i <= 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if p, err = builder.Voice(dmr.BytesToBits(testFrame(i))); err != nil {
+			t.Fatal(err)
+		}
+		repeater.pf(repeater, p)
+	}
+	if got := codec.called(); got != fmt.Sprint([]string{"start 2042214->91", "write 1", "write 2", "write 3", "write 4", "write 5"}) {
+		t.Fatalf("expected the stream to stay active, got %v", got)
+	}
+
+	// The terminator ends the stream once, the stopped timer doesn't.
+	if p, err = builder.Terminator(); err != nil {
+		t.Fatal(err)
+	}
+	repeater.pf(repeater, p)
+	time.Sleep(200 * time.Millisecond)
+	if got := codec.called(); got != fmt.Sprint([]string{"start 2042214->91", "write 1", "write 2", "write 3", "write 4", "write 5", "end"}) {
+		t.Fatalf("expected a single end, got %v", got)
+	}
+}
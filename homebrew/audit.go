@@ -0,0 +1,47 @@
+package homebrew
+
+// AuthEventType is the kind of authentication event reported to OnAuthEvent.
+type AuthEventType uint8
+
+func (t AuthEventType) String() string {
+	switch t {
+	case NonceIssued:
+		return "nonce issued"
+	case KeyAccepted:
+		return "key accepted"
+	case KeyRejected:
+		return "key rejected"
+	default:
+		return "invalid"
+	}
+}
+
+const (
+	NonceIssued AuthEventType = iota // We sent a nonce to an incoming peer
+	KeyAccepted                      // The peer sent the expected key
+	KeyRejected                      // The peer sent a wrong key
+)
+
+// AuthEvent is an authentication event of an incoming peer, for audit logging.
+type AuthEvent struct {
+	Type   AuthEventType
+	Reason string // Why the key was rejected
+	Rekey  bool   // The event is part of a rekey, see Homebrew.Rekey
+
+	// Nonce issued to the peer, only set with AuditNonce. The key itself is
+	// never reported.
+	Nonce []byte
+}
+
+// authEvent reports an authentication event to OnAuthEvent, if set.
+func (h *Homebrew) authEvent(peer *Peer, t AuthEventType, reason string) {
+	if h.OnAuthEvent == nil {
+		return
+	}
+
+	event := AuthEvent{Type: t, Reason: reason, Rekey: peer.rekey}
+	if h.AuditNonce {
+		event.Nonce = append([]byte{}, peer.Nonce...)
+	}
+	h.OnAuthEvent(peer, event)
+}
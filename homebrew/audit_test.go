@@ -0,0 +1,54 @@
+package homebrew
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net"
+	"testing"
+)
+
+func TestAuthEvents(t *testing.T) {
+	transport := newTestTransport()
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	var events []AuthEvent
+	h.OnAuthEvent = func(_ *Peer, event AuthEvent) {
+		events = append(events, event)
+	}
+
+	frame := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	login := func(addr *net.UDPAddr, id uint32, password string) {
+		repeaterID := RepeaterIDBytes(id)
+		if err := h.handle(addr, frame(RepeaterLogin, repeaterID)); err != nil {
+			t.Fatal(err)
+		}
+		reply := (<-transport.out).data
+		key := sha256.Sum256(frame(reply[6:], []byte(password)))
+		if err := h.handle(addr, frame(RepeaterKey, repeaterID, key[:])); err != nil {
+			t.Fatal(err)
+		}
+		<-transport.out
+	}
+
+	login(&net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 62031}, 1001, "passw0rd")
+	if len(events) != 2 || events[0].Type != NonceIssued || events[1].Type != KeyAccepted {
+		t.Fatalf("expected nonce issued and key accepted events, got %+v", events)
+	}
+	if events[0].Nonce != nil {
+		t.Fatalf("expected nonce to be redacted, got %x", events[0].Nonce)
+	}
+
+	h.AuditNonce = true
+	events = nil
+	login(&net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 62031}, 1002, "wrong")
+	if len(events) != 2 || events[0].Type != NonceIssued || events[1].Type != KeyRejected || events[1].Reason == "" {
+		t.Fatalf("expected nonce issued and key rejected events, got %+v", events)
+	}
+	if peer := h.getPeer(1002); len(events[0].Nonce) != 4 || !bytes.Equal(events[0].Nonce, peer.Nonce) {
+		t.Fatalf("expected nonce %x, got %x", peer.Nonce, events[0].Nonce)
+	}
+}
@@ -0,0 +1,104 @@
+package homebrew
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+// captureHeaderSize is the size of the header preceding each frame in a
+// capture: the receive time in Unix nanoseconds and the frame length.
+const captureHeaderSize = 10
+
+// capture writes the received DMRD frames, see StartCapture.
+type capture struct {
+	sync.Mutex
+	w io.Writer
+}
+
+// StartCapture writes each DMRD frame received from a logged in peer to w,
+// prefixed with the time it was received, until StopCapture is called or a
+// write fails. The capture can be replayed with ReplayCapture.
+func (h *Homebrew) StartCapture(w io.Writer) error {
+	if w == nil {
+		return errors.New("homebrew: capture writer can't be nil")
+	}
+
+	h.capture.Lock()
+	defer h.capture.Unlock()
+
+	if h.capture.w != nil {
+		return errors.New("homebrew: capture already started")
+	}
+	h.capture.w = w
+	return nil
+}
+
+// StopCapture stops writing received frames, started with StartCapture.
+func (h *Homebrew) StopCapture() {
+	h.capture.Lock()
+	h.capture.w = nil
+	h.capture.Unlock()
+}
+
+// captureFrame writes a received DMRD frame to the capture, if any.
+func (h *Homebrew) captureFrame(data []byte, now time.Time) {
+	h.capture.Lock()
+	defer h.capture.Unlock()
+
+	if h.capture.w == nil {
+		return
+	}
+
+	var record = make([]byte, captureHeaderSize, captureHeaderSize+len(data))
+	binary.BigEndian.PutUint64(record, uint64(now.UnixNano()))
+	binary.BigEndian.PutUint16(record[8:], uint16(len(data)))
+	if _, err := h.capture.w.Write(append(record, data...)); err != nil {
+		h.logger.Errorf("capture failed, stopped: %v\n", err)
+		h.capture.w = nil
+	}
+}
+
+// ReplayCapture reads the frames written by StartCapture and passes them to
+// send, as fast as possible or, with realtime set, spaced as they were
+// received.
+func ReplayCapture(r io.Reader, send func(*dmr.Packet) error, realtime bool) error {
+	var (
+		header = make([]byte, captureHeaderSize)
+		last   time.Time
+	)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var data = make([]byte, binary.BigEndian.Uint16(header[8:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		p, err := UnmarshalHomebrew(data)
+		if err != nil {
+			return err
+		}
+
+		received := time.Unix(0, int64(binary.BigEndian.Uint64(header)))
+		if realtime && !last.IsZero() && received.After(last) {
+			time.Sleep(received.Sub(last))
+		}
+		last = received
+
+		if err := send(p); err != nil {
+			return err
+		}
+	}
+}
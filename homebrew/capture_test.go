@@ -0,0 +1,73 @@
+package homebrew
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestCaptureReplay(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	var capture bytes.Buffer
+	if err := h.StartCapture(&capture); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.StartCapture(&capture); err == nil {
+		t.Fatal("expected error starting a second capture")
+	}
+
+	var sent []*dmr.Packet
+	for i := 0; i < 3; i++ {
+		p := testPacket(2001, 91, dmr.CallTypeGroup)
+		p.Sequence = uint8(i)
+		if err := h.handle(peer.Addr, testData(t, p, peer.ID)); err != nil {
+			t.Fatal(err)
+		}
+		p.RepeaterID = peer.ID
+		p.SetData(p.Data)
+		sent = append(sent, p)
+		time.Sleep(20 * time.Millisecond)
+	}
+	h.StopCapture()
+	if err := h.handle(peer.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), peer.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if n := capture.Len(); n != 3*(captureHeaderSize+55) {
+		t.Fatalf("expected 3 captured frames, got %d bytes", n)
+	}
+
+	for _, realtime := range []bool{false, true} {
+		var (
+			replayed []*dmr.Packet
+			start    = time.Now()
+		)
+		err := ReplayCapture(bytes.NewReader(capture.Bytes()), func(p *dmr.Packet) error {
+			replayed = append(replayed, p)
+			return nil
+		}, realtime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(replayed, sent) {
+			t.Fatalf("expected %v, got %v", sent, replayed)
+		}
+		if elapsed := time.Since(start); realtime && elapsed < 40*time.Millisecond {
+			t.Fatalf("expected the replay spaced as captured, took %s", elapsed)
+		}
+	}
+
+	// A truncated capture is reported.
+	err := ReplayCapture(bytes.NewReader(capture.Bytes()[:capture.Len()-1]), func(*dmr.Packet) error { return nil }, false)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package homebrew
+
+import (
+	"bytes"
+	"time"
+)
+
+// closingSize is the size of the MSTCL and RPTCL frames: the 5 byte tag and
+// the repeater ID.
+const closingSize = 9
+
+// BuildClosing builds the frame announcing a graceful disconnect: MSTCL when
+// sent by a master, RPTCL when sent by a repeater.
+func BuildClosing(id uint32, master bool) []byte {
+	var tag = RepeaterClosing
+	if master {
+		tag = MasterClosing
+	}
+	return append(append([]byte{}, tag...), RepeaterIDBytes(id)...)
+}
+
+// ParseClosing recognizes a MSTCL or RPTCL frame and returns the repeater ID it
+// carries, and whether it was sent by a master.
+func ParseClosing(data []byte) (id uint32, master bool, ok bool) {
+	if len(data) != closingSize {
+		return 0, false, false
+	}
+	switch {
+	case bytes.HasPrefix(data, MasterClosing):
+		master = true
+	case bytes.HasPrefix(data, RepeaterClosing):
+	default:
+		return 0, false, false
+	}
+	return ParseRepeaterIDBytes(data[len(MasterClosing):]), master, true
+}
+
+// handleClosing handles a peer disconnecting. An incoming peer is unlinked, an
+// outgoing peer is logged in again after Timeouts.AuthTimeout.
+func (h *Homebrew) handleClosing(peer *Peer, id uint32, master bool) error {
+	switch {
+	case peer.Incoming && !master:
+		if id != peer.ID {
+			h.warnf(peer, "peer %d@%s sent closing with repeater ID %d (ignored)\n", peer.ID, peer.Addr, id)
+			return nil
+		}
+		h.logger.Infof("peer %d@%s closed the connection\n", peer.ID, peer.Addr)
+		if err := h.Unlink(peer.ID); err != nil {
+			return err
+		}
+		if peer.done() {
+			h.peerDisconnected(peer, ErrPeerClosed)
+		}
+		return nil
+
+	case !peer.Incoming && master:
+		h.logger.Infof("peer %d@%s master closed the connection; waiting retry\n", peer.ID, peer.Addr)
+		h.ackControl(peer)
+		var connected = peer.done()
+		peer.setStatus(AuthFailed)
+		peer.Last.AuthSent = time.Now()
+		if connected {
+			h.peerDisconnected(peer, ErrPeerClosed)
+		}
+		return nil
+
+	default:
+		h.warnf(peer, "peer %d@%s sent unexpected closing (ignored)\n", peer.ID, peer.Addr)
+		return nil
+	}
+}
@@ -0,0 +1,81 @@
+package homebrew
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestClosing(t *testing.T) {
+	var tests = []struct {
+		master bool
+		tag    []byte
+	}{
+		{true, MasterClosing},
+		{false, RepeaterClosing},
+	}
+	for _, test := range tests {
+		data := BuildClosing(2042214, test.master)
+		if !bytes.Equal(data, append(append([]byte{}, test.tag...), RepeaterIDBytes(2042214)...)) {
+			t.Fatalf("unexpected closing frame %q", data)
+		}
+		id, master, ok := ParseClosing(data)
+		if !ok || id != 2042214 || master != test.master {
+			t.Fatalf("expected %q from 2042214, got %d, master %t, ok %t", test.tag, id, master, ok)
+		}
+	}
+
+	config := buildConfigData(testConfig)
+	copy(config, "RPTCL")
+	if _, _, ok := ParseClosing(config); ok {
+		t.Fatal("expected configuration frame not to parse as closing")
+	}
+}
+
+func TestClosingReceived(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	// An incoming peer closing is unlinked.
+	peer, _ := testIncomingPeer(t, h, 1001)
+	if err := h.handle(peer.Addr, BuildClosing(1002, false)); err != nil {
+		t.Fatal(err)
+	}
+	if h.getPeer(1001) == nil {
+		t.Fatal("expected closing with another repeater ID to be ignored")
+	}
+	var disconnected []error
+	h.OnPeerDisconnected = func(p *Peer, reason error) {
+		if p == peer {
+			disconnected = append(disconnected, reason)
+		}
+	}
+	if err := h.handle(peer.Addr, BuildClosing(1001, false)); err != nil {
+		t.Fatal(err)
+	}
+	if h.getPeer(1001) != nil || h.getPeerByAddr(peer.Addr) != nil {
+		t.Fatal("expected closing peer to be unlinked")
+	}
+	if len(disconnected) != 1 || disconnected[0] != ErrPeerClosed {
+		t.Fatalf("expected disconnect by closing, got %v", disconnected)
+	}
+
+	// A master closing is logged in to again later.
+	remote := testRemote(t)
+	defer remote.Close()
+	master := &Peer{
+		ID:      2001,
+		Addr:    remote.LocalAddr().(*net.UDPAddr),
+		AuthKey: []byte("passw0rd"),
+	}
+	if err := h.Link(master); err != nil {
+		t.Fatal(err)
+	}
+	master.Status = AuthDone
+	if err := h.handle(master.Addr, BuildClosing(testConfig.ID, true)); err != nil {
+		t.Fatal(err)
+	}
+	if master.Status != AuthFailed || h.getPeer(2001) == nil {
+		t.Fatalf("expected master to wait for a login retry, got %s", master.Status.String())
+	}
+}
@@ -0,0 +1,63 @@
+package homebrew
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+// DMR+ reflector commands. A command is sent as a short private call to the
+// command ID, consisting of only the voice LC header and the terminator with
+// LC, which the repeater passes on to the reflector for its timeslot.
+//
+// Supported commands are:
+//
+//	4000        disconnect the timeslot from its current reflector
+//	4001..4999  connect the timeslot to reflector 4001..4999
+//	5000        request the reflector connection status
+const (
+	CommandDisconnect uint32 = 4000
+	CommandStatus     uint32 = 5000
+)
+
+// BuildCommand builds the packets carrying a DMR+ reflector command from srcID.
+func BuildCommand(command, srcID uint32, timeslot, colorCode uint8) ([]*dmr.Packet, error) {
+	if command < CommandDisconnect || command > CommandStatus {
+		return nil, fmt.Errorf("homebrew: unsupported command %d", command)
+	}
+
+	builder, err := voice.NewBuilder(srcID, command, dmr.CallTypePrivate, timeslot, colorCode)
+	if err != nil {
+		return nil, err
+	}
+	header, err := builder.Header()
+	if err != nil {
+		return nil, err
+	}
+	terminator, err := builder.Terminator()
+	if err != nil {
+		return nil, err
+	}
+	return []*dmr.Packet{header, terminator}, nil
+}
+
+// SendCommandToPeer sends a DMR+ reflector command to a single peer, for
+// example to forcibly disconnect the timeslot from its current reflector.
+func (h *Homebrew) SendCommandToPeer(peer *Peer, command, srcID uint32, timeslot uint8) error {
+	if peer == nil {
+		return errors.New("homebrew: can't send command to nil peer")
+	}
+
+	packets, err := BuildCommand(command, srcID, timeslot, h.Config.ColorCode)
+	if err != nil {
+		return err
+	}
+	for _, p := range packets {
+		if err := h.WritePacketToPeer(p, peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
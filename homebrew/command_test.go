@@ -0,0 +1,52 @@
+package homebrew
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/bptc"
+)
+
+func TestDisconnectCommand(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, remote := testIncomingPeer(t, h, 1001)
+	if err := h.SendCommandToPeer(peer, CommandDisconnect, 2042214, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	packets := readFrames(t, remote, 50*time.Millisecond)
+	if len(packets) != 2 {
+		t.Fatalf("expected header and terminator, got %d packets", len(packets))
+	}
+
+	// Unit to unit voice channel user LC, 2042214->4000, with the masked
+	// Reed-Solomon (12,9) checksum.
+	tests := []struct {
+		dataType uint8
+		want     []byte
+	}{
+		{dmr.VoiceLC, []byte{0x03, 0x00, 0x00, 0x00, 0x0f, 0xa0, 0x1f, 0x29, 0x66, 0x1d, 0x47, 0x94}},
+		{dmr.TerminatorWithLC, []byte{0x03, 0x00, 0x00, 0x00, 0x0f, 0xa0, 0x1f, 0x29, 0x66, 0x12, 0x48, 0x9b}},
+	}
+	for i, test := range tests {
+		p := packets[i]
+		if p.DataType != test.dataType || p.CallType != dmr.CallTypePrivate || p.DstID != CommandDisconnect || p.Timeslot != 1 {
+			t.Fatalf("packet %d: unexpected packet %+v", i, p)
+		}
+		var data = make([]byte, 12)
+		if err := bptc.Decode(p.InfoBits(), data); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, test.want) {
+			t.Errorf("packet %d: got %x, want %x", i, data, test.want)
+		}
+	}
+
+	if _, err := BuildCommand(3999, 2042214, 1, 1); err == nil {
+		t.Fatal("expected unsupported command to fail")
+	}
+}
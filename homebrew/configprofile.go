@@ -0,0 +1,107 @@
+package homebrew
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ConfigProfile selects how the fixed-width fields of our configuration are
+// formatted for a master. Masters parse these fields with slightly different
+// expectations, and some silently reject a configuration that doesn't match.
+type ConfigProfile uint8
+
+const (
+	// ConfigProfileDefault formats the fields as MMDVMHost does: strings left
+	// justified and space padded, numbers zero padded.
+	ConfigProfileDefault ConfigProfile = iota
+
+	// ConfigProfileBrandMeister upper cases the callsign and formats the
+	// position with an explicit sign and four decimals.
+	ConfigProfileBrandMeister
+
+	// ConfigProfileDMRPlus formats the position with four decimals, and
+	// replaces characters outside of printable ASCII in the text fields.
+	ConfigProfileDMRPlus
+
+	// ConfigProfileHBlink is the same as ConfigProfileDefault, HBlink trims
+	// the padding of any field.
+	ConfigProfileHBlink
+)
+
+func (p ConfigProfile) String() string {
+	switch p {
+	case ConfigProfileBrandMeister:
+		return SoftwareBrandMeister
+	case ConfigProfileDMRPlus:
+		return SoftwareDMRPlus
+	case ConfigProfileHBlink:
+		return SoftwareHBlink
+	default:
+		return "default"
+	}
+}
+
+// callsign returns the callsign field.
+func (p ConfigProfile) callsign(s string) string {
+	if p == ConfigProfileBrandMeister {
+		s = strings.ToUpper(s)
+	}
+	return p.text(s, 8)
+}
+
+// position returns the latitude and longitude fields.
+func (p ConfigProfile) position(lat, lon float32) (string, string) {
+	lat, lon = clampCoordinate(lat, 90), clampCoordinate(lon, 180)
+	switch p {
+	case ConfigProfileBrandMeister:
+		return fmt.Sprintf("%+08.4f", lat), fmt.Sprintf("%+09.4f", lon)
+	case ConfigProfileDMRPlus:
+		return fmt.Sprintf("%08.4f", lat), fmt.Sprintf("%09.4f", lon)
+	}
+	return coordinate(lat, 8), coordinate(lon, 9)
+}
+
+// clampCoordinate limits a coordinate to the range of -limit to limit degrees.
+func clampCoordinate(v, limit float32) float32 {
+	switch {
+	case math.IsNaN(float64(v)):
+		return 0
+	case v > limit:
+		return limit
+	case v < -limit:
+		return -limit
+	}
+	return v
+}
+
+// coordinate formats a coordinate to a field of the given width, zero padded
+// and rounded to as many decimals as fit. Truncating the formatted value
+// instead would lose the sign's worth of precision on negative coordinates,
+// or the integer part of wide ones.
+func coordinate(v float32, width int) string {
+	// Widen by the shortest decimal representation, so the float32 rounding
+	// error doesn't show in the decimals
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'f', -1, 32), 64)
+	for decimals := width - 2; decimals > 0; decimals-- {
+		if s := fmt.Sprintf("%0*.*f", width, decimals, f); len(s) <= width {
+			return s
+		}
+	}
+	return fmt.Sprintf("%0*.0f", width, f)
+}
+
+// text returns a string field of the given width, left justified and space
+// padded.
+func (p ConfigProfile) text(s string, width int) string {
+	if p == ConfigProfileDMRPlus {
+		s = strings.Map(func(r rune) rune {
+			if r < ' ' || r > '~' {
+				return '?'
+			}
+			return r
+		}, s)
+	}
+	return fmt.Sprintf("%-*s", width, s)
+}
@@ -0,0 +1,75 @@
+package homebrew
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestConfigProfiles(t *testing.T) {
+	config := *testConfig
+	config.Callsign = "pd0mz"
+	config.Latitude = 52.3
+	config.Longitude = 4.9
+	config.Height = 12
+	config.Location = "Zoetermeer é"
+	config.SoftwareID = "go-dmr"
+	config.PackageID = "test"
+
+	var tests = []struct {
+		profile ConfigProfile
+		fields  string
+	}{
+		{ConfigProfileBrandMeister, "PD0MZ   " + "438800000" + "431200000" + "05" + "01" + "+52.3000" + "+004.9000" + "012" + "Zoetermeer é       "},
+		{ConfigProfileDMRPlus, "pd0mz   " + "438800000" + "431200000" + "05" + "01" + "052.3000" + "0004.9000" + "012" + "Zoetermeer ?        "},
+	}
+	for _, test := range tests {
+		var want = append([]byte("RPTC"), RepeaterIDBytes(config.ID)...)
+		want = append(want, test.fields...)
+		want = append(want, fmt.Sprintf("%-19s%d%-124s%-40s%-40s", "", config.Slots, "", "go-dmr", "test")...)
+		want = want[:302]
+
+		if got := buildProfileConfigData(&config, test.profile); !bytes.Equal(got, want) {
+			t.Errorf("%s: expected\n%q, got\n%q", test.profile, want, got)
+		}
+	}
+
+	// The default profile is unchanged, and parses back.
+	if c, err := parseConfigData(buildConfigData(&config)); err != nil || c.Callsign != "pd0mz" || c.Height != 12 {
+		t.Fatalf("expected default configuration to parse back, got %+v, %v", c, err)
+	}
+}
+
+func TestConfigCoordinates(t *testing.T) {
+	var tests = []struct {
+		lat, lon         float32
+		wantLat, wantLon string
+	}{
+		{52.3, 4.9, "52.30000", "4.9000000"},
+		{-33.8688, 151.2093, "-33.8688", "151.20930"},
+		{-3.2, -60.5, "-3.20000", "-60.50000"},
+		{5.5, 7.25, "5.500000", "7.2500000"},
+		{0, -123.456, "0.000000", "-123.4560"},
+		{-0.5, -0.25, "-0.50000", "-0.250000"},
+		{95, -200, "90.00000", "-180.0000"},
+	}
+	for _, test := range tests {
+		config := *testConfig
+		config.Latitude = test.lat
+		config.Longitude = test.lon
+
+		data := buildConfigData(&config)
+		if lat, lon := string(data[38:38+8]), string(data[46:46+9]); lat != test.wantLat || lon != test.wantLon {
+			t.Errorf("%v, %v: expected %q %q, got %q %q", test.lat, test.lon, test.wantLat, test.wantLon, lat, lon)
+		}
+
+		c, err := parseConfigData(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantLat, wantLon := clampCoordinate(test.lat, 90), clampCoordinate(test.lon, 180)
+		if c.Latitude != wantLat || c.Longitude != wantLon {
+			t.Errorf("%v, %v: parsed back as %v, %v", test.lat, test.lon, c.Latitude, c.Longitude)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package homebrew
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+// dedupSize caps the number of streams remembered for DedupWindow, the least
+// recently seen streams are forgotten first.
+const dedupSize = 1024
+
+// dedupKey identifies a stream, regardless of the path it arrived by.
+type dedupKey struct {
+	streamID uint32
+	srcID    uint32
+	timeslot uint8
+}
+
+type dedupEntry struct {
+	key    dedupKey
+	peerID uint32 // Peer the stream was first received from
+	seen   time.Time
+}
+
+// duplicateStream checks whether the stream p belongs to was recently
+// received from another peer, as happens in bridged topologies where the same
+// stream arrives by multiple paths. Only the first path is forwarded, the
+// others would otherwise rebroadcast the stream into a loop.
+func (h *Homebrew) duplicateStream(p *dmr.Packet, peer *Peer, now time.Time) bool {
+	if h.DedupWindow == 0 {
+		return false
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.dedupLRU == nil {
+		h.dedupLRU = list.New()
+		h.dedup = make(map[dedupKey]*list.Element)
+	}
+
+	key := dedupKey{streamID: p.StreamID, srcID: p.SrcID, timeslot: p.Timeslot & 0x01}
+	if e, ok := h.dedup[key]; ok {
+		entry := e.Value.(*dedupEntry)
+		if entry.peerID != peer.ID && now.Sub(entry.seen) <= h.DedupWindow {
+			return true
+		}
+		// Once expired, the stream is taken over by the peer sending it now
+		entry.peerID = peer.ID
+		entry.seen = now
+		h.dedupLRU.MoveToFront(e)
+		return false
+	}
+
+	h.dedup[key] = h.dedupLRU.PushFront(&dedupEntry{key: key, peerID: peer.ID, seen: now})
+	if h.dedupLRU.Len() > dedupSize {
+		h.removeDedup(h.dedupLRU.Back())
+	}
+	return false
+}
+
+// expireDedup forgets the streams not seen within DedupWindow.
+func (h *Homebrew) expireDedup(now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.dedupLRU == nil {
+		return
+	}
+	for e := h.dedupLRU.Back(); e != nil; e = h.dedupLRU.Back() {
+		if now.Sub(e.Value.(*dedupEntry).seen) <= h.DedupWindow {
+			break
+		}
+		h.removeDedup(e)
+	}
+}
+
+func (h *Homebrew) removeDedup(e *list.Element) {
+	delete(h.dedup, e.Value.(*dedupEntry).key)
+	h.dedupLRU.Remove(e)
+}
@@ -0,0 +1,51 @@
+package homebrew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestDuplicateStreamDropped(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peerA, _ := testIncomingPeer(t, h, 1001)
+	peerB, _ := testIncomingPeer(t, h, 1002)
+	other, remote := testIncomingPeer(t, h, 1003)
+	other.Subscribe(91, 0)
+
+	// The same stream arrives through two bridges.
+	for i := 0; i < 3; i++ {
+		for _, peer := range []*Peer{peerA, peerB} {
+			p := testPacket(2001, 91, dmr.CallTypeGroup)
+			p.Sequence = uint8(i)
+			if err := h.handlePacket(p, peer); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	got := readFrames(t, remote, 100*time.Millisecond)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 frames forwarded, got %d", len(got))
+	}
+	for i, p := range got {
+		if p.RepeaterID != h.Config.ID || p.Sequence != uint8(i) {
+			t.Fatalf("unexpected frame %d: %v", i, p)
+		}
+	}
+	if c := peerB.SnapshotCounters(); c.DuplicateFrames != 3 {
+		t.Fatalf("expected 3 duplicate frames from the second path, got %d", c.DuplicateFrames)
+	}
+
+	// Once the window passed, the stream is accepted from another path.
+	h.expireDedup(time.Now().Add(2 * h.DedupWindow))
+	if err := h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), peerB); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrames(t, remote, 100*time.Millisecond); len(got) != 1 {
+		t.Fatalf("expected the stream forwarded after the window, got %d", len(got))
+	}
+}
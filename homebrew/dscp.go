@@ -0,0 +1,54 @@
+package homebrew
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// DSCPExpeditedForwarding is the DSCP for expedited forwarding, suited to
+// voice, see RFC 3246.
+const DSCPExpeditedForwarding = 46
+
+// setDSCP marks the frames sent from the socket with the DSCP, in the ToS of
+// IPv4 or the Traffic Class of IPv6 depending on the address family the socket
+// is bound to. A socket bound to the unspecified IPv6 address, as ":62031"
+// is, serves both families and gets both.
+func setDSCP(conn *net.UDPConn, dscp int) error {
+	if dscp < 0 || dscp > 63 {
+		return fmt.Errorf("homebrew: DSCP %d out of range", dscp)
+	}
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return fmt.Errorf("homebrew: unexpected local address %s", conn.LocalAddr())
+	}
+	if addr.IP.To4() != nil {
+		return ipv4.NewConn(conn).SetTOS(dscp << 2)
+	}
+	if err := ipv6.NewConn(conn).SetTrafficClass(dscp << 2); err != nil {
+		return err
+	}
+	if len(addr.IP) == 0 || addr.IP.IsUnspecified() {
+		return ipv4.NewConn(conn).SetTOS(dscp << 2)
+	}
+	return nil
+}
+
+// applyDSCP sets the DSCP on the socket, if any, logging a warning if the
+// platform refuses it.
+func (h *Homebrew) applyDSCP(conn Transport) {
+	if h.DSCP == 0 {
+		return
+	}
+	udp, ok := conn.(*net.UDPConn)
+	if !ok {
+		h.logger.Warningf("DSCP %d not set, the transport isn't a UDP socket\n", h.DSCP)
+		return
+	}
+	if err := setDSCP(udp, h.DSCP); err != nil {
+		h.logger.Warningf("DSCP %d not set on %s: %v\n", h.DSCP, udp.LocalAddr(), err)
+	}
+}
@@ -0,0 +1,94 @@
+package homebrew
+
+import (
+	"bytes"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestDSCP(t *testing.T) {
+	// The socket bound to ":0" serves both address families, the frames of
+	// both are marked.
+	h, err := New(testConfig, &net.UDPAddr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.DSCP = DSCPExpeditedForwarding
+	h.applyDSCP(h.conn)
+
+	// IPv4, read back from the ToS control message of a received frame.
+	remote, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	raw, err := remote.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_RECVTOS, 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Link(&Peer{ID: 1001, Addr: remote.LocalAddr().(*net.UDPAddr), AuthKey: []byte("passw0rd")}); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		data = make([]byte, 512)
+		oob  = make([]byte, 64)
+	)
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	n, oobn, _, _, err := remote.ReadMsgUDP(data, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tos = -1
+	for _, m := range messages {
+		if m.Header.Level == syscall.IPPROTO_IP && m.Header.Type == syscall.IP_TOS && len(m.Data) > 0 {
+			tos = int(m.Data[0])
+		}
+	}
+	if !bytes.HasPrefix(data[:n], RepeaterLogin) || tos != DSCPExpeditedForwarding<<2 {
+		t.Fatalf("expected login with ToS %#02x, got %q with %#02x", DSCPExpeditedForwarding<<2, data[:n], tos)
+	}
+
+	// IPv6, read back from the traffic class control message.
+	remote6, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("no IPv6: %v", err)
+	}
+	defer remote6.Close()
+	pc := ipv6.NewPacketConn(remote6)
+	if err := pc.SetControlMessage(ipv6.FlagTrafficClass, true); err != nil {
+		t.Skipf("traffic class control message not supported: %v", err)
+	}
+
+	if err := h.Link(&Peer{ID: 1002, Addr: remote6.LocalAddr().(*net.UDPAddr), AuthKey: []byte("passw0rd")}); err != nil {
+		t.Fatal(err)
+	}
+	remote6.SetReadDeadline(time.Now().Add(time.Second))
+	n, cm, _, err := pc.ReadFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data[:n], RepeaterLogin) || cm == nil || cm.TrafficClass != DSCPExpeditedForwarding<<2 {
+		t.Fatalf("expected login with traffic class %#02x, got %q with %v", DSCPExpeditedForwarding<<2, data[:n], cm)
+	}
+
+	h.DSCP = 64
+	if err := setDSCP(h.conn.(*net.UDPConn), h.DSCP); err == nil {
+		t.Fatal("expected error for DSCP out of range")
+	}
+}
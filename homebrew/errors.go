@@ -0,0 +1,43 @@
+package homebrew
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned for a closed repeater, a full send queue and for frames that
+// can't be parsed, use errors.Is to check for them.
+var (
+	ErrClosed        = errors.New("homebrew: repeater is closed")
+	ErrSendQueueFull = errors.New("homebrew: send queue full")
+	ErrShortFrame    = errors.New("homebrew: short frame")
+	ErrBadFrameType  = errors.New("homebrew: unexpected frame type")
+	ErrWrongLength   = errors.New("homebrew: wrong frame length")
+)
+
+// AuthError is an authentication failure of a peer reported to
+// OnPeerDisconnected, such as a refused login or a failed rekey.
+type AuthError struct {
+	PeerID uint32
+	Phase  string // Authentication phase that failed: "login", "key", "config" or "rekey"
+	Err    error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("homebrew: peer %d %s failed: %v", e.PeerID, e.Phase, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// checkLength checks that a frame is of the expected length.
+func checkLength(data []byte, length int) error {
+	switch {
+	case len(data) < length:
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrShortFrame, length, len(data))
+	case len(data) > length:
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrWrongLength, length, len(data))
+	}
+	return nil
+}
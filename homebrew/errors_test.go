@@ -0,0 +1,128 @@
+package homebrew
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestTypedErrors(t *testing.T) {
+	data := testData(t, testPacket(2001, 91, dmr.CallTypeGroup), 1001)
+	if _, err := parseData(data[:54]); !errors.Is(err, ErrShortFrame) {
+		t.Fatalf("expected ErrShortFrame, got %v", err)
+	}
+	if _, err := parseData(append(data, 0)); !errors.Is(err, ErrWrongLength) {
+		t.Fatalf("expected ErrWrongLength, got %v", err)
+	}
+	data[15] |= 0x30
+	if _, err := parseData(data); !errors.Is(err, ErrBadFrameType) {
+		t.Fatalf("expected ErrBadFrameType, got %v", err)
+	}
+	if _, err := parseConfigData(buildConfigData(testConfig)[:300]); !errors.Is(err, ErrShortFrame) {
+		t.Fatalf("expected ErrShortFrame, got %v", err)
+	}
+
+	// A transport closed under us, and a closed repeater.
+	transport := newTestTransport()
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.Close()
+	if err := h.ListenAndServe(); !errors.Is(err, ErrClosed) || !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	h.Close()
+	if err := h.ListenAndServe(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+
+	// A failed rekey is reported as an AuthError.
+	h = testHomebrew(t)
+	defer h.Close()
+	peer, _ := testIncomingPeer(t, h, 1001)
+	var reason error
+	h.OnPeerDisconnected = func(_ *Peer, err error) { reason = err }
+	if err := h.failRekey(peer, "timeout"); err != nil {
+		t.Fatal(err)
+	}
+	var authErr *AuthError
+	if !errors.As(reason, &authErr) || authErr.PeerID != 1001 || authErr.Phase != "rekey" {
+		t.Fatalf("expected AuthError, got %v", reason)
+	}
+}
+
+func TestAuthErrors(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	var reason error
+	h.OnPeerDisconnected = func(_ *Peer, err error) { reason = err }
+	expect := func(id uint32, phase string, target error) {
+		t.Helper()
+
+		var authErr *AuthError
+		if !errors.As(reason, &authErr) || authErr.PeerID != id || authErr.Phase != phase {
+			t.Fatalf("expected %s AuthError of peer %d, got %v", phase, id, reason)
+		}
+		if target != nil && !errors.Is(reason, target) {
+			t.Fatalf("expected %v, got %v", target, reason)
+		}
+		reason = nil
+	}
+
+	// Our login and key are refused by a master.
+	master := testRemote(t)
+	defer master.Close()
+	outgoing := &Peer{ID: 2001, Addr: master.LocalAddr().(*net.UDPAddr), AuthKey: []byte("passw0rd")}
+	if err := h.Link(outgoing); err != nil {
+		t.Fatal(err)
+	}
+	nak := append(append([]byte{}, MasterNAK...), h.id...)
+	for _, test := range []struct {
+		status AuthStatus
+		phase  string
+	}{
+		{AuthNone, "login"},
+		{AuthBegin, "key"},
+		{AuthDone, "config"},
+	} {
+		outgoing.setStatus(test.status)
+		if err := h.handle(outgoing.Addr, nak); err != nil {
+			t.Fatal(err)
+		}
+		expect(outgoing.ID, test.phase, ErrMasterNAK)
+	}
+
+	// Once the configuration is acknowledged, a refusal is a deauthentication.
+	outgoing.setStatus(AuthDone)
+	if err := h.handle(outgoing.Addr, append(append([]byte{}, RepeaterACK...), h.id...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.handle(outgoing.Addr, nak); err != nil {
+		t.Fatal(err)
+	}
+	if reason != ErrMasterNAK {
+		t.Fatalf("expected %v, got %v", ErrMasterNAK, reason)
+	}
+
+	// An incoming peer sends a wrong key, and an invalid configuration.
+	incoming, _ := testIncomingPeer(t, h, 1001)
+	incoming.setStatus(AuthBegin)
+	key := append(append(append([]byte{}, RepeaterKey...), incoming.id...), make([]byte, 32)...)
+	if err := h.handle(incoming.Addr, key); err != nil {
+		t.Fatal(err)
+	}
+	expect(incoming.ID, "key", nil)
+
+	incoming.setStatus(AuthDone)
+	if err := h.handle(incoming.Addr, append(append([]byte{}, RepeaterConfig...), incoming.id...)); err != nil {
+		t.Fatal(err)
+	}
+	expect(incoming.ID, "config", ErrShortFrame)
+	if status, _ := incoming.session(); status != AuthNone {
+		t.Fatalf("expected the peer to log in again, got %s", status.String())
+	}
+}
@@ -0,0 +1,34 @@
+package homebrew
+
+import "errors"
+
+// Reasons passed to OnPeerDisconnected.
+var (
+	ErrPingTimeout = errors.New("homebrew: ping timeout")
+	ErrMasterNAK   = errors.New("homebrew: master refused the session")
+	ErrPeerClosed  = errors.New("homebrew: peer closed the connection")
+)
+
+// peerConnected reports a peer reaching AuthDone to OnPeerConnected. Must be
+// called without holding the mutex or rxtx lock.
+func (h *Homebrew) peerConnected(peer *Peer) {
+	if h.OnPeerConnected != nil {
+		h.OnPeerConnected(peer)
+	}
+}
+
+// authFailed reports a failed login, key or configuration of the peer to
+// OnPeerDisconnected as an AuthError. Must be called without holding the
+// mutex or rxtx lock.
+func (h *Homebrew) authFailed(peer *Peer, phase string, err error) {
+	h.peerDisconnected(peer, &AuthError{PeerID: peer.ID, Phase: phase, Err: err})
+}
+
+// peerDisconnected reports a peer dropping out of AuthDone to
+// OnPeerDisconnected. Must be called without holding the mutex or rxtx lock.
+func (h *Homebrew) peerDisconnected(peer *Peer, reason error) {
+	h.logger.Debugf("peer %d@%s disconnected: %v\n", peer.ID, peer.Addr, reason)
+	if h.OnPeerDisconnected != nil {
+		h.OnPeerDisconnected(peer, reason)
+	}
+}
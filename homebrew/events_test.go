@@ -0,0 +1,77 @@
+package homebrew
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPeerConnectionCallbacks(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	var (
+		connected    []uint32
+		disconnected []error
+	)
+	// The callbacks may call back into the API.
+	h.OnPeerConnected = func(peer *Peer) {
+		connected = append(connected, peer.ID)
+		h.Stats()
+	}
+	h.OnPeerDisconnected = func(peer *Peer, reason error) {
+		disconnected = append(disconnected, reason)
+		h.DumpRouting()
+	}
+
+	remote := testRemote(t)
+	defer remote.Close()
+	peer := &Peer{
+		ID:      1001,
+		Addr:    remote.LocalAddr().(*net.UDPAddr),
+		AuthKey: []byte("passw0rd"),
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+
+	masterID := RepeaterIDBytes(testConfig.ID)
+	login := func() {
+		t.Helper()
+		expectFrame(t, remote, RepeaterLogin, time.Second)
+		if err := h.handle(peer.Addr, append(append([]byte{}, RepeaterACK...), 1, 2, 3, 4)); err != nil {
+			t.Fatal(err)
+		}
+		expectFrame(t, remote, RepeaterKey, time.Second)
+		if err := h.handle(peer.Addr, append(append([]byte{}, MasterACK...), masterID...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	login()
+	if len(connected) != 1 || connected[0] != peer.ID || peer.Status != AuthDone {
+		t.Fatalf("expected peer to connect, got %v", connected)
+	}
+
+	// The master closes the session.
+	if err := h.handle(peer.Addr, BuildClosing(peer.ID, true)); err != nil {
+		t.Fatal(err)
+	}
+	if len(disconnected) != 1 || disconnected[0] != ErrPeerClosed {
+		t.Fatalf("expected disconnect by closing, got %v", disconnected)
+	}
+
+	// After logging in again, the master stops answering pings.
+	peer.Status = AuthNone
+	if err := h.handleAuth(peer); err != nil {
+		t.Fatal(err)
+	}
+	login()
+	if len(connected) != 2 {
+		t.Fatalf("expected peer to connect again, got %v", connected)
+	}
+	h.housekeeping(time.Now().Add(h.Timeouts.PingTimeout * 2))
+	if len(disconnected) != 2 || disconnected[1] != ErrPingTimeout {
+		t.Fatalf("expected disconnect by ping timeout, got %v", disconnected)
+	}
+}
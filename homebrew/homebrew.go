@@ -3,22 +3,25 @@ package homebrew
 
 import (
 	"bytes"
+	"container/list"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/op/go-logging"
 	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/voice"
 )
 
-var log = logging.MustGetLogger("dmr/homebrew")
-
 type AuthStatus uint8
 
 func (a *AuthStatus) String() string {
@@ -46,12 +49,14 @@ const (
 // Messages as documented by DL5DI, G4KLX and DG1HT, see "DMRplus IPSC Protocol for HB repeater (20150726).pdf".
 var (
 	DMRData         = []byte("DMRD")
+	DMRTalkerAlias  = []byte("DMRA")
 	MasterNAK       = []byte("MSTNAK")
 	MasterACK       = []byte("MSTACK")
 	RepeaterACK     = []byte("RPTACK")
 	RepeaterLogin   = []byte("RPTL")
 	RepeaterKey     = []byte("RPTK")
 	RepeaterConfig  = []byte("RPTC")
+	RepeaterOptions = []byte("RPTO")
 	MasterPing      = []byte("MSTPING")
 	MasterPong      = []byte("MSTPONG")
 	RepeaterPing    = []byte("RPTPING")
@@ -60,36 +65,286 @@ var (
 	RepeaterClosing = []byte("RPTCL")
 )
 
-// We ping the peers every minute
+// Default Timeouts, used to seed each new Homebrew.
+//
+// Deprecated: changing these doesn't affect existing instances and isn't safe
+// with instances running, set Homebrew.Timeouts instead.
 var (
 	AuthTimeout  = time.Second * 15
 	PingInterval = time.Second * 5
 	PingTimeout  = time.Second * 15
 	SendInterval = time.Millisecond * 30
 	TGTimeout    = time.Minute * 15
+
+	RouteTimeout  = time.Minute * 15
+	StreamTimeout = time.Second * 2
+
+	ResolverCacheTimeout = time.Hour
+	ControlRetryInterval = time.Second
+	WarningInterval      = time.Minute
+	ContextPollInterval  = time.Second
 )
 
+// Timeouts tunes the login, keepalive and forwarding timing of a Homebrew.
+type Timeouts struct {
+	// AuthTimeout is how long a login may take, and how long an incoming
+	// peer may stay silent before it's dropped
+	AuthTimeout time.Duration
+
+	// We ping the peers every PingInterval, and drop them after PingTimeout
+	// without a pong
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+
+	// SendInterval is the pace of the frames queued by Send
+	SendInterval time.Duration
+
+	// TGTimeout expires the dynamic talkgroup subscriptions of peers
+	TGTimeout time.Duration
+
+	// RouteTimeout expires the peer a subscriber was last heard on, used to
+	// route private calls
+	RouteTimeout time.Duration
+
+	// StreamTimeout ends a stream that went silent without a terminator, and
+	// is how long the hangtime lasts after the last idle frame
+	StreamTimeout time.Duration
+
+	// ResolverCacheTimeout is how long the answers of the IDResolver are
+	// cached
+	ResolverCacheTimeout time.Duration
+
+	// TalkerAliasTimeout expires the talker aliases of sources not heard
+	// from, see TalkerAlias
+	TalkerAliasTimeout time.Duration
+
+	// ControlRetryInterval is the pace of the control frame retries, see
+	// Homebrew.ControlRetries
+	ControlRetryInterval time.Duration
+
+	// WarningInterval is the window within which repeats of a peer warning
+	// are coalesced
+	WarningInterval time.Duration
+
+	// ContextPollInterval is how often ListenAndServeContext checks its
+	// context
+	ContextPollInterval time.Duration
+
+	// CloseTimeout is how long Close waits for the keepalive, sender, send
+	// queues and jitter buffers to stop before closing the socket
+	CloseTimeout time.Duration
+}
+
+// keepaliveInterval is the resolution of the keepalive housekeeping.
+const keepaliveInterval = time.Second
+
 // Homebrew is implements the Homebrew IPSC DMR Air Interface protocol
+//
+// The PacketFunc, the PacketReceived of the peers and the stream, frame
+// quality and position callbacks are called while a frame is handled. They
+// may call the Send methods, Peers, DumpRouting and Hangtime, but not Close,
+// which waits for the frame handling to stop.
 type Homebrew struct {
 	Config *RepeaterConfiguration
 	Peer   map[string]*Peer
 	PeerID map[uint32]*Peer
 
-	pf     dmr.PacketFunc
-	conn   *net.UDPConn
-	closed bool
-	id     []byte
-	last   time.Time   // Record last received frame time
-	mutex  *sync.Mutex // Mutex for manipulating peer list or send queue
-	rxtx   *sync.Mutex // Mutex for when receiving data or sending data
-	stop   chan bool
-	queue  []*dmr.Packet
+	// Timeouts are seeded from the package level defaults, and are to be set
+	// before ListenAndServe is called.
+	Timeouts Timeouts
+
+	// InlineKeepalive runs the keepalive housekeeping from the ListenAndServe
+	// read loop, using a read deadline to wake up, instead of from a separate
+	// goroutine, so it doesn't contend with the frame handling. The sender,
+	// the per-peer send queues, the jitter buffer release and the sockets of
+	// peers with a LocalAddr still run goroutines of their own. The trade-off
+	// is that a slow PacketFunc delays the housekeeping (pings, timeouts and
+	// login retries) until it returns.
+	InlineKeepalive bool
+
+	// OnPeerReady is called when an incoming peer has completed the login and
+	// sent its configuration, for example to greet it with SendMessageToPeer.
+	OnPeerReady func(*Peer)
+
+	// MaxStreamDuration caps the length of a transmission, to prevent a stuck
+	// PTT from hogging a talkgroup. When a stream exceeds it, the rest of the
+	// stream is dropped, a terminator is sent downstream in its place and
+	// OnStreamCutOff is called. Zero means no limit.
+	MaxStreamDuration time.Duration
+	OnStreamCutOff    func(*Peer, *dmr.Packet)
+
+	// LastHeardSize is the number of transmissions kept for LastHeard, none
+	// when negative.
+	LastHeardSize int
+
+	// OnStreamStart is called with the first frame of each stream received
+	// from a peer, and OnStreamEnd with the last one and the stream duration
+	// once a terminator arrives, or the stream went silent for
+	// Timeouts.StreamTimeout.
+	OnStreamStart func(*dmr.Packet)
+	OnStreamEnd   func(*dmr.Packet, time.Duration)
+
+	// StreamCorrelationWindow is the gap within which a new stream from the
+	// same source to the same destination, without a terminator in between,
+	// is taken as the same keyup, split by a reconnect. It's not counted again
+	// in the last heard list and talkgroup statistics. Zero disables this.
+	StreamCorrelationWindow time.Duration
+
+	// DedupWindow is how long a stream, identified by its stream ID, source
+	// and timeslot, is remembered. The same stream received from another peer
+	// within the window is dropped, as in bridged topologies it arrives by
+	// multiple paths. Defaults to 3 seconds, zero disables this.
+	DedupWindow time.Duration
+
+	// OnFrameQuality is called for every accepted voice frame, with the
+	// BER and RSSI reported by the repeater, see dmr.Packet.BERPercent and
+	// dmr.Packet.RSSIdBm.
+	OnFrameQuality func(peer *Peer, p *dmr.Packet)
+
+	// OnPosition is called with the position of a source, when a GPS info LC
+	// is embedded in its voice stream.
+	OnPosition func(srcID uint32, lat, lon float64, t time.Time)
+
+	// OnColorCodeChange is called when the color code of the voice bursts
+	// changes while reassembling an embedded LC, which hints at interference
+	// or an overlapping transmission.
+	OnColorCodeChange func(peer *Peer, p *dmr.Packet, from, to uint8)
+
+	// JitterBuffer is the number of frames per stream held back to restore
+	// their order. Once the buffer is filled, they're forwarded at the voice
+	// frame rate instead of as they arrive. Zero disables the buffer.
+	JitterBuffer int
+
+	// OnPeerConnected is called when a peer completes its login, and
+	// OnPeerDisconnected when an authenticated peer drops or a login fails,
+	// with the reason: ErrPingTimeout, ErrMasterNAK, ErrPeerClosed or an
+	// *AuthError for the failed phase. They're called without holding any
+	// locks.
+	OnPeerConnected    func(peer *Peer)
+	OnPeerDisconnected func(peer *Peer, reason error)
+
+	// OnAuthEvent is called when we issue a nonce to an incoming peer, and
+	// when we accept or reject its key. The nonce is only included in the
+	// event with AuditNonce set.
+	OnAuthEvent func(peer *Peer, event AuthEvent)
+	AuditNonce  bool
+
+	// UnknownDataPolicy selects what to do with DMR data from peers that never
+	// logged in, it's ignored by default. OnUnknownData is called for each of
+	// these frames regardless of the policy.
+	UnknownDataPolicy uint8
+	OnUnknownData     func(remote *net.UDPAddr, data []byte)
+
+	// SuppressIdle stops forwarding the idle frames repeaters send during
+	// hangtime, they're still used to track the hangtime, see Hangtime.
+	SuppressIdle bool
+
+	// VerifyFEC checks the FEC and CRC of received DMR data frames, frames
+	// failing the checks are counted in CorruptFrames. With DropCorrupt they
+	// are dropped as well, instead of being forwarded.
+	VerifyFEC   bool
+	DropCorrupt bool
+
+	// InvalidFramePolicy selects what to do with DMR data failing
+	// dmr.Packet.Validate, such as a group call to TG 0. Such frames are
+	// dropped by default.
+	InvalidFramePolicy uint8
+
+	// DropSilentIncoming closes and unlinks the incoming peers that haven't
+	// pinged us within Timeouts.PingTimeout, OnPeerDisconnected is called with
+	// ErrPingTimeout.
+	DropSilentIncoming bool
+
+	// DSCP marks the frames we send for QoS, for example with
+	// DSCPExpeditedForwarding. It's set on the socket by ListenAndServe,
+	// platforms that don't support it log a warning.
+	DSCP int
+
+	// Observer only receives, for passive monitoring of a master: we log in
+	// and keep the link alive, but never send DMR data. Received frames are
+	// passed to the packet handlers, Send and friends return an error.
+	Observer bool
+
+	// ReadBufferSize is the size of the buffer frames are read into, larger
+	// frames are truncated. Defaults to 512 bytes, which leaves room for
+	// extended frames such as the DMR+ options.
+	ReadBufferSize int
+
+	// MaxQueueDepth is the number of packets Send queues, defaults to 1000. It
+	// is to be set before the first Send.
+	MaxQueueDepth int
+
+	// PeerQueueSize is the number of DMR data frames queued per peer, each
+	// queue is written to its peer independently so a slow peer can't hold up
+	// the others. Frames are dropped when the queue is full. Zero writes the
+	// frames directly.
+	PeerQueueSize int
+
+	// IncomingAuthFunc looks up the password of a repeater logging in, a
+	// repeater it doesn't know is refused. Without it, all repeaters log in
+	// with DefaultPassword, or "passw0rd" if that's empty too.
+	IncomingAuthFunc func(repeaterID uint32) ([]byte, bool)
+	DefaultPassword  []byte
+
+	// StrictRepeaterID refuses the login of incoming peers sending a repeater
+	// ID that doesn't match the one they logged in with, instead of only
+	// logging a warning.
+	StrictRepeaterID bool
+
+	// EnforceRepeaterID drops the DMR data of incoming peers carrying a
+	// repeater ID other than the one they logged in with, so a peer can't
+	// inject frames on behalf of another repeater. It's set by default.
+	EnforceRepeaterID bool
+
+	// ControlRetries is the number of times a login, key or configuration
+	// frame is sent again to an outgoing peer that doesn't reply within
+	// Timeouts.ControlRetryInterval. Zero leaves the retries to the
+	// keepalive.
+	ControlRetries int
+
+	// Options are sent to outgoing peers after our configuration, in a RPTO
+	// frame, for example to have BrandMeister subscribe us to static
+	// talkgroups with "TS1_1=9990".
+	Options map[string]string
+
+	// IDResolver is consulted for the callsign and name of the source of a
+	// transmission, for the last heard list and logging.
+	IDResolver IDResolver
+
+	pf         dmr.PacketFunc
+	logger     Logger
+	conn       Transport
+	closed     bool
+	paused     bool // Forwarding paused, see Pause
+	id         []byte
+	last       time.Time   // Record last received frame time
+	mutex      *sync.Mutex // Mutex for manipulating peer list or send queue
+	rxtx       *sync.Mutex // Mutex for when receiving data or sending data
+	control    *sync.Mutex // Mutex for the pending control frames of peers
+	stop       chan bool
+	done       chan struct{}       // Closed by Close
+	peerFrames chan peerFrame      // Frames read from the sockets of the peers, see readPeer
+	workers    sync.WaitGroup      // Keepalive, sender, send queue and jitter buffer goroutines
+	queue      chan *dmr.Packet    // Packets queued by Send, see sendQueue
+	routes     map[uint32]*route   // Subscriber ID to the peer it was last heard on
+	heard      []*HeardEntry       // Last heard transmissions, most recent first
+	resolved   map[uint32]resolved // IDResolver cache
+	tgStats    map[uint32]*tgStat  // Activity per talkgroup
+
+	tgRoutes map[tgRoute][]uint32 // Talkgroup and timeslot to the peers it's routed to
+
+	dedup    map[dedupKey]*list.Element // Recently seen streams, see DedupWindow
+	dedupLRU *list.List                 // Recently seen streams, most recent first
+
+	capture     capture                 // Received frames capture, see StartCapture
+	talkerAlias map[uint32]*talkerAlias // Talker alias per source ID
+	unknownData uint64                  // DMR data frames from unknown peers
+
+	jitterInterval time.Duration // Release pace of the jitter buffers
 }
 
 // New creates a new Homebrew repeater
 func New(config *RepeaterConfiguration, addr *net.UDPAddr) (*Homebrew, error) {
-	var err error
-
 	if config == nil {
 		return nil, errors.New("homebrew: RepeaterConfiguration can't be nil")
 	}
@@ -97,59 +352,157 @@ func New(config *RepeaterConfiguration, addr *net.UDPAddr) (*Homebrew, error) {
 		return nil, errors.New("homebrew: addr can't be nil")
 	}
 
-	h := &Homebrew{
-		Config: config,
-		Peer:   make(map[string]*Peer),
-		PeerID: make(map[uint32]*Peer),
-		id:     packRepeaterID(config.ID),
-		mutex:  &sync.Mutex{},
-		rxtx:   &sync.Mutex{},
-		queue:  make([]*dmr.Packet, 0),
-	}
-	if h.conn, err = net.ListenUDP("udp", addr); err != nil {
+	var network = config.Network
+	if network == "" {
+		network = "udp"
+	}
+	conn, err := net.ListenUDP(network, addr)
+	if err != nil {
 		return nil, errors.New("homebrew: " + err.Error())
 	}
+	h, err := NewWithTransport(config, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// NewWithTransport creates a new Homebrew repeater using the given transport.
+// A configuration with fields that don't fit the configuration frame is
+// refused, see RepeaterConfiguration.Validate.
+func NewWithTransport(config *RepeaterConfiguration, conn Transport) (*Homebrew, error) {
+	if config == nil {
+		return nil, errors.New("homebrew: RepeaterConfiguration can't be nil")
+	}
+	if conn == nil {
+		return nil, errors.New("homebrew: transport can't be nil")
+	}
+
+	h := &Homebrew{
+		Config:     config,
+		Peer:       make(map[string]*Peer),
+		PeerID:     make(map[uint32]*Peer),
+		id:         RepeaterIDBytes(config.ID),
+		mutex:      &sync.Mutex{},
+		rxtx:       &sync.Mutex{},
+		control:    &sync.Mutex{},
+		routes:     make(map[uint32]*route),
+		tgRoutes:   make(map[tgRoute][]uint32),
+		resolved:   make(map[uint32]resolved),
+		tgStats:    make(map[uint32]*tgStat),
+		conn:       conn,
+		logger:     log,
+		done:       make(chan struct{}),
+		peerFrames: make(chan peerFrame, 64),
+
+		jitterInterval: voice.BurstDuration,
+
+		talkerAlias: make(map[uint32]*talkerAlias),
+
+		Timeouts: Timeouts{
+			AuthTimeout:  AuthTimeout,
+			PingInterval: PingInterval,
+			PingTimeout:  PingTimeout,
+			SendInterval: SendInterval,
+			TGTimeout:    TGTimeout,
+			CloseTimeout: time.Second,
+
+			RouteTimeout:         RouteTimeout,
+			StreamTimeout:        StreamTimeout,
+			ResolverCacheTimeout: ResolverCacheTimeout,
+			ControlRetryInterval: ControlRetryInterval,
+			WarningInterval:      WarningInterval,
+			ContextPollInterval:  ContextPollInterval,
+			TalkerAliasTimeout:   time.Minute * 15,
+		},
+		MaxQueueDepth:      1000,
+		DedupWindow:        time.Second * 3,
+		ReadBufferSize:     512,
+		LastHeardSize:      lastHeardSize,
+		InvalidFramePolicy: InvalidFrameDrop,
+		EnforceRepeaterID:  true,
+	}
+	if err := h.validateConfig(config); err != nil {
+		return nil, err
+	}
 
 	return h, nil
 }
 
 func (h *Homebrew) Active() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.active()
+}
+
+func (h *Homebrew) active() bool {
 	return !h.closed && h.conn != nil
 }
 
-// Close stops the active listeners
+// Close stops the active listeners. It's safe to call concurrently with
+// ListenAndServe, and more than once. The peers are told we're closing, best
+// effort: the errors of the peers that couldn't be told are returned joined,
+// after closing the socket. The control frame retries are stopped, and the
+// keepalive, sender, send queues and jitter buffers are given
+// Timeouts.CloseTimeout to stop.
 func (h *Homebrew) Close() error {
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
-
-	if !h.Active() {
+	if !h.active() {
+		h.mutex.Unlock()
 		return nil
 	}
 
-	log.Info("closing")
+	h.logger.Infof("closing\n")
 
 	// Tell peers we're closing
-closing:
+	var errs []error
 	for _, peer := range h.Peer {
-		if peer.Status == AuthDone {
-			if err := h.WriteToPeer(append(RepeaterClosing, h.id...), peer); err != nil {
-				break closing
+		if peer.done() {
+			if err := h.WriteToPeer(BuildClosing(h.Config.ID, peer.Incoming), peer); err != nil {
+				h.logger.Errorf("peer %d@%s close failed: %v\n", peer.ID, peer.Addr, err)
+				errs = append(errs, fmt.Errorf("homebrew: peer %d close failed: %w", peer.ID, err))
 			}
 		}
 	}
 
-	// Kill keepalive goroutine
+	for _, peer := range h.Peer {
+		h.ackControl(peer)
+		h.stopQueue(peer)
+		h.closePeer(peer)
+	}
+
+	// Stop keepalive and sender goroutines
 	if h.stop != nil {
 		close(h.stop)
 		h.stop = nil
 	}
+	h.closed = true
+	close(h.done)
+	h.mutex.Unlock()
+
+	// They may need the mutex to get there
+	var stopped = make(chan struct{})
+	go func() {
+		h.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(h.Timeouts.CloseTimeout):
+		h.logger.Warningf("background goroutines didn't stop within %s\n", h.Timeouts.CloseTimeout)
+	}
 
 	// Kill listening socket
-	h.closed = true
-	return h.conn.Close()
+	if err := h.conn.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
-// Link establishes a new link with a peer
+// Link establishes a new link with a peer. An outgoing peer with a LocalAddr
+// gets a socket of its own, bound to that address.
 func (h *Homebrew) Link(peer *Peer) error {
 	if peer == nil {
 		return errors.New("homebrew: peer can't be nil")
@@ -161,6 +514,14 @@ func (h *Homebrew) Link(peer *Peer) error {
 		return errors.New("homebrew: peer AuthKey can't be nil")
 	}
 
+	var conn *net.UDPConn
+	if !peer.Incoming && peer.LocalAddr != nil {
+		var err error
+		if conn, err = h.dialPeer(peer); err != nil {
+			return err
+		}
+	}
+
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
@@ -171,13 +532,16 @@ func (h *Homebrew) Link(peer *Peer) error {
 	peer.Last.PongReceived = time.Time{}
 
 	// Register our peer
-	peer.id = packRepeaterID(peer.ID)
-	h.Peer[peer.Addr.String()] = peer
+	peer.id = RepeaterIDBytes(peer.ID)
+	h.Peer[addrKey(peer.Addr)] = peer
 	h.PeerID[peer.ID] = peer
 
 	if peer.Incoming {
 		return nil
 	}
+	if conn != nil {
+		h.usePeer(peer, conn)
+	}
 
 	return h.handleAuth(peer)
 }
@@ -191,46 +555,245 @@ func (h *Homebrew) Unlink(id uint32) error {
 		return fmt.Errorf("homebrew: peer %d not linked", id)
 	}
 
-	delete(h.Peer, peer.Addr.String())
+	h.ackControl(peer)
+	h.stopQueue(peer)
+	h.closePeer(peer)
+	delete(h.Peer, addrKey(peer.Addr))
 	delete(h.PeerID, id)
 	return nil
 }
 
+// UpdateConfig replaces our repeater configuration and sends it to the
+// authenticated outgoing peers. Peers with ReloginOnConfigChange set are
+// logged in again from scratch instead.
+func (h *Homebrew) UpdateConfig(config *RepeaterConfiguration) error {
+	if config == nil {
+		return errors.New("homebrew: RepeaterConfiguration can't be nil")
+	}
+	if config.ID != h.Config.ID {
+		return errors.New("homebrew: can't change the repeater ID of a running repeater")
+	}
+	if err := h.validateConfig(config); err != nil {
+		return err
+	}
+
+	h.Config = config
+	for _, peer := range h.getPeers() {
+		if peer.Incoming || !peer.done() {
+			continue
+		}
+
+		if peer.ReloginOnConfigChange {
+			h.logger.Infof("peer %d@%s config changed; logging in again\n", peer.ID, peer.Addr)
+			if err := h.WriteToPeer(BuildClosing(h.Config.ID, false), peer); err != nil {
+				return err
+			}
+			peer.setStatus(AuthNone)
+			if err := h.handleAuth(peer); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := h.writeControl(buildProfileConfigData(h.Config, peer.ConfigProfile), peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (h *Homebrew) ListenAndServe() error {
-	var data = make([]byte, 302)
+	return h.ListenAndServeContext(context.Background())
+}
+
+// ListenAndServeContext is ListenAndServe, returning ctx.Err() once the
+// context is done. Cancellation is noticed within
+// Timeouts.ContextPollInterval; the keepalive and sender stop, the socket
+// stays open until Close.
+func (h *Homebrew) ListenAndServeContext(ctx context.Context) error {
+	var size = h.ReadBufferSize
+	if size <= 0 {
+		size = 512
+	}
+	var data = make([]byte, size)
 
+	h.mutex.Lock()
+	if !h.active() {
+		h.mutex.Unlock()
+		return ErrClosed
+	}
+	h.applyDSCP(h.conn)
 	h.stop = make(chan bool)
-	go h.keepalive(h.stop)
+	if !h.InlineKeepalive {
+		h.workers.Add(1)
+		go h.keepalive(h.stop)
+	}
+	h.workers.Add(1)
+	go h.sender(h.stop, h.sendQueue(), h.Timeouts.SendInterval)
+	h.mutex.Unlock()
+
+	var (
+		next = time.Now().Add(keepaliveInterval)
+		poll = ctx.Done() != nil
+	)
+	for h.Active() {
+		if err := ctx.Err(); err != nil {
+			h.stopBackground()
+			h.logger.Infof("listener canceled\n")
+			return err
+		}
+
+		var deadline time.Time
+		if h.InlineKeepalive || poll {
+			var now = time.Now()
+			if h.InlineKeepalive && !now.Before(next) {
+				h.housekeeping(now)
+				next = now.Add(keepaliveInterval)
+			}
+			deadline = now.Add(h.Timeouts.ContextPollInterval)
+			if h.InlineKeepalive && next.Before(deadline) || !poll {
+				deadline = next
+			}
+		}
+		// The deadline is set before taking the frames of the peer sockets, so
+		// readPeer passing one meanwhile interrupts the read below.
+		if err := h.conn.SetReadDeadline(deadline); err != nil {
+			h.logger.Errorf("%s", err.Error())
+			return err
+		}
+		h.handlePeerFrames()
 
-	h.closed = false
-	for !h.closed {
 		n, peer, err := h.conn.ReadFromUDP(data)
 		if err != nil {
-			log.Errorf("%s", err.Error())
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				continue
+			}
+			if !h.Active() {
+				break
+			}
+			if errors.Is(err, net.ErrClosed) {
+				// Closed under us, not by Close
+				err = fmt.Errorf("%w: %w", ErrClosed, err)
+			}
+			h.logger.Errorf("%s\n", err.Error())
 			return err
 		}
+		if n == len(data) {
+			h.logger.Warningf("%s sent a frame filling the %d byte read buffer, it may be truncated\n", peer, n)
+		}
 		if err := h.handle(peer, data[:n]); err != nil {
-			log.Errorf("%s", err.Error())
-
-			if h.closed && strings.HasSuffix(err.Error(), "use of closed network connection") {
+			if !h.Active() {
 				break
 			}
+			h.logger.Errorf("%s", err.Error())
 			return err
 		}
 	}
 
-	log.Info("listener closed")
+	h.logger.Infof("listener closed\n")
 	return nil
 }
 
-// Send a packet to the peers. Will block until the packet is sent.
+// stopBackground stops the keepalive and sender goroutines, if running.
+func (h *Homebrew) stopBackground() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.stop != nil {
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+// Send queues a packet for the peers and returns immediately. The queue is a
+// bounded channel, sent in order at Timeouts.SendInterval pace by a writer
+// goroutine while ListenAndServe runs. It's safe to call from multiple
+// goroutines, and never waits for the writes or the received traffic.
+// ErrSendQueueFull is returned if MaxQueueDepth packets are already waiting.
 func (h *Homebrew) Send(p *dmr.Packet) error {
-	h.rxtx.Lock()
-	defer h.rxtx.Unlock()
+	if err := h.canTransmit(); err != nil {
+		return err
+	}
+	if h.Paused() {
+		return nil
+	}
+	if _, err := buildData(p, h.Config.ID); err != nil {
+		return err
+	}
 
-	data := buildData(p, h.Config.ID)
+	h.mutex.Lock()
+	queue := h.sendQueue()
+	h.mutex.Unlock()
+
+	select {
+	case queue <- p:
+		return nil
+	default:
+		return fmt.Errorf("%w, %d packets waiting", ErrSendQueueFull, len(queue))
+	}
+}
+
+// sendQueue returns the channel feeding the sender, made with room for
+// MaxQueueDepth packets on first use. Must be called with the mutex held.
+func (h *Homebrew) sendQueue() chan *dmr.Packet {
+	if h.queue == nil {
+		var size = h.MaxQueueDepth
+		if size < 0 {
+			size = 0
+		}
+		h.queue = make(chan *dmr.Packet, size)
+	}
+	return h.queue
+}
+
+// sender is the writer sending the queued packets, one every interval, until
+// stopped.
+func (h *Homebrew) sender(stop <-chan bool, queue <-chan *dmr.Packet, interval time.Duration) {
+	defer h.workers.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		select {
+		case p := <-queue:
+			if err := h.SendSync(p); err != nil {
+				h.logger.Errorf("send of stream %#08x failed: %v\n", p.StreamID, err)
+			}
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// SendSync sends a packet to the peers. Will block until the packet is sent,
+// but not the received traffic, which is handled meanwhile.
+func (h *Homebrew) SendSync(p *dmr.Packet) error {
+	data, err := buildData(p, h.Config.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.canTransmit(); err != nil {
+		return err
+	}
+	if h.Paused() {
+		return nil
+	}
 	for _, peer := range h.getPeers() {
-		if err := h.WriteToPeer(data, peer); err != nil {
+		if status, _ := peer.session(); status != AuthDone { // skip peers still logging in
+			continue
+		}
+		if !peer.Accepts(p) {
+			continue
+		}
+		if err := h.writeData(data, peer); err != nil {
 			return err
 		}
 	}
@@ -238,18 +801,36 @@ func (h *Homebrew) Send(p *dmr.Packet) error {
 	return nil
 }
 
-// Send a packet to other peers
+// Send a packet to other peers subscribed to the talkgroup, or that the
+// talkgroup on the timeslot is routed to, see AddTGRoute.
 func (h *Homebrew) SendTG(p *dmr.Packet, peer *Peer) error {
-	data := buildData(p, h.Config.ID)
+	if err := h.canTransmit(); err != nil {
+		return err
+	}
+	if h.Paused() {
+		return nil
+	}
+
+	data, err := buildData(p, h.Config.ID)
+	if err != nil {
+		return err
+	}
+	routed := h.routedPeers(p.DstID, p.Timeslot)
 	for _, toPeer := range h.getPeers() {
 		if toPeer.ID == peer.ID { // skip self
 			continue
 		}
+		if !toPeer.done() { // skip peers still logging in
+			continue
+		}
+		if !toPeer.Accepts(p) {
+			continue
+		}
 
-		if toPeer.TGID == p.DstID {
-			log.Debugf("write to peer %d bytes@%s\n", toPeer.ID, toPeer.Addr)
+		if toPeer.Subscribed(p.DstID, p.Timeslot) || routed[toPeer.ID] {
+			h.logger.Debugf("write to peer %d bytes@%s\n", toPeer.ID, toPeer.Addr)
 
-			if err := h.WriteToPeer(data, toPeer); err != nil {
+			if err := h.writeData(data, toPeer); err != nil {
 				return err
 			}
 		}
@@ -258,6 +839,46 @@ func (h *Homebrew) SendTG(p *dmr.Packet, peer *Peer) error {
 	return nil
 }
 
+// SendPrivate sends a private call to the peer its destination was last heard
+// on. Calls to a destination that wasn't heard are sent to all peers, like
+// Send does.
+func (h *Homebrew) SendPrivate(p *dmr.Packet) error {
+	return h.sendPrivate(p, nil)
+}
+
+// sendPrivate routes a private call received from peer, or originated by us if
+// peer is nil.
+func (h *Homebrew) sendPrivate(p *dmr.Packet, peer *Peer) error {
+	if err := h.canTransmit(); err != nil {
+		return err
+	}
+	if h.Paused() {
+		return nil
+	}
+
+	// Route to the repeater the subscriber was last heard on
+	if toPeer := h.lookupRoute(p.DstID, time.Now()); toPeer != nil {
+		if toPeer == peer || !toPeer.done() || !toPeer.Accepts(p) {
+			return nil
+		}
+		return h.WritePacketToPeer(p, toPeer)
+	}
+
+	data, err := buildData(p, h.Config.ID)
+	if err != nil {
+		return err
+	}
+	for _, toPeer := range h.getPeers() {
+		if toPeer == peer || !toPeer.done() || !toPeer.Accepts(p) {
+			continue
+		}
+		if err := h.writeData(data, toPeer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (h *Homebrew) GetPacketFunc() dmr.PacketFunc {
 	return h.pf
 }
@@ -267,7 +888,15 @@ func (h *Homebrew) SetPacketFunc(f dmr.PacketFunc) {
 }
 
 func (h *Homebrew) WritePacketToPeer(p *dmr.Packet, peer *Peer) error {
-	return h.WriteToPeer(buildData(p, h.Config.ID), peer)
+	if err := h.canTransmit(); err != nil {
+		return err
+	}
+
+	data, err := buildData(p, h.Config.ID)
+	if err != nil {
+		return err
+	}
+	return h.writeData(data, peer)
 }
 
 func (h *Homebrew) WriteToPeer(b []byte, peer *Peer) error {
@@ -275,12 +904,17 @@ func (h *Homebrew) WriteToPeer(b []byte, peer *Peer) error {
 		return errors.New("homebrew: can't write to nil peer")
 	}
 
+	peer.counters.Lock()
 	peer.Last.PacketSent = time.Now()
-	_, err := h.conn.WriteTo(b, peer.Addr)
+	peer.counters.Unlock()
+
+	_, err := h.writeTo(b, peer)
 	if err != nil {
-		log.Debugf("WriteToPeer err %s\n", err.Error())
+		h.logger.Debugf("WriteToPeer err %s\n", err.Error())
+		return err
 	}
-	return err
+	peer.sent(len(b))
+	return nil
 }
 
 func (h *Homebrew) WriteToPeerWithID(b []byte, id uint32) error {
@@ -288,8 +922,7 @@ func (h *Homebrew) WriteToPeerWithID(b []byte, id uint32) error {
 }
 
 func (h *Homebrew) checkRepeaterID(id []byte) bool {
-	// BrandMeister release 20190421-185653 switched from upper case to lower case hex digits
-	return id != nil && bytes.EqualFold(id, h.id)
+	return id != nil && ParseRepeaterIDBytes(id) == h.Config.ID
 }
 
 func (h *Homebrew) getPeer(id uint32) *Peer {
@@ -307,13 +940,21 @@ func (h *Homebrew) getPeerByAddr(addr *net.UDPAddr) *Peer {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	if peer, ok := h.Peer[addr.String()]; ok {
+	if peer, ok := h.Peer[addrKey(addr)]; ok {
 		return peer
 	}
 
 	return nil
 }
 
+// addrKey returns the key of an address in the Peer map. IPv4-mapped IPv6
+// addresses are unmapped, so a peer is found regardless of the socket family
+// its frames arrive on.
+func addrKey(addr *net.UDPAddr) string {
+	ap := addr.AddrPort()
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port()).String()
+}
+
 func (h *Homebrew) getPeers() []*Peer {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
@@ -326,27 +967,55 @@ func (h *Homebrew) getPeers() []*Peer {
 	return peers
 }
 
+// incomingAuthKey returns the password of an incoming repeater, see
+// IncomingAuthFunc.
+func (h *Homebrew) incomingAuthKey(repeaterID uint32) ([]byte, bool) {
+	if h.IncomingAuthFunc != nil {
+		return h.IncomingAuthFunc(repeaterID)
+	}
+	if len(h.DefaultPassword) > 0 {
+		return h.DefaultPassword, true
+	}
+	return []byte("passw0rd"), true
+}
+
 func (h *Homebrew) handle(remote *net.UDPAddr, data []byte) error {
+	// Ignore frames too short to carry a tag
+	if len(data) < 4 {
+		return nil
+	}
+
 	peer := h.getPeerByAddr(remote)
 	if peer == nil {
 		if bytes.Equal(data[:4], RepeaterLogin) {
-			repeaterID := unpackRepeaterID(data[4:])
-			log.Debugf("login packet from unknown peer %s, repeater ID %d\n", remote, repeaterID)
+			repeaterID := ParseRepeaterIDBytes(data[4:8])
+			h.logger.Debugf("login packet from unknown peer %s, repeater ID %d\n", remote, repeaterID)
+
+			authKey, ok := h.incomingAuthKey(repeaterID)
+			if !ok || len(authKey) == 0 {
+				h.logger.Warningf("login from unknown repeater ID %d@%s refused\n", repeaterID, remote)
+				_, err := h.conn.WriteTo(append(MasterNAK, h.id...), remote)
+				return err
+			}
 
 			newPeer := &Peer{
 				ID:       repeaterID,
 				Addr:     remote,
 				Config:   nil,
-				AuthKey:  []byte("passw0rd"),
+				AuthKey:  authKey,
 				Incoming: true,
-				Status:   AuthNone,
-				TGID:     446}
+				Status:   AuthNone}
+			newPeer.Subscribe(446, 0)
+			newPeer.Subscribe(446, 1)
 
 			h.Link(newPeer)
-			log.Debugf("added peer %s, repeater ID %d\n", remote, repeaterID)
+			h.logger.Debugf("added peer %s, repeater ID %d\n", remote, repeaterID)
 			peer = h.getPeerByAddr(remote)
+		} else if bytes.HasPrefix(data, DMRData) {
+			h.handleUnknownData(remote, data)
+			return nil
 		} else {
-			log.Debugf("unknown packet from unknown peer %s\n", remote)
+			h.logger.Debugf("unknown packet from unknown peer %s\n", remote)
 			return nil
 		}
 	}
@@ -358,31 +1027,47 @@ func (h *Homebrew) handle(remote *net.UDPAddr, data []byte) error {
 
 	peer.Last.PacketReceived = time.Now()
 
-	if peer.Status != AuthDone {
+	// Any reply to a login, key or configuration frame stops its retries
+	if !peer.Incoming && (bytes.HasPrefix(data, MasterACK) || bytes.HasPrefix(data, RepeaterACK) || bytes.HasPrefix(data, MasterNAK)) {
+		h.ackControl(peer)
+	}
+
+	if id, master, ok := ParseClosing(data); ok {
+		return h.handleClosing(peer, id, master)
+	}
+
+	status, _ := peer.session()
+	if status != AuthDone {
 		// Ignore DMR data at this stage
-		if bytes.Equal(data[:4], DMRData) {
+		if bytes.Equal(data[:4], DMRData) || bytes.Equal(data[:4], DMRTalkerAlias) {
 			return nil
 		}
 
 		if peer.Incoming {
-			switch peer.Status {
+			switch status {
 			case AuthNone:
 				switch {
 				case bytes.Equal(data[:4], RepeaterLogin):
 					if !peer.CheckRepeaterID(data[4:8]) {
-						log.Warningf("peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[4:8]))
-						//return h.WriteToPeer(append(MasterNAK, h.id...), peer)
+						if h.StrictRepeaterID {
+							h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (refused)\n", peer.ID, remote, hex.EncodeToString(data[4:8]))
+							h.authFailed(peer, "login", errors.New("invalid repeater ID"))
+							return h.WriteToPeer(append(MasterNAK, h.id...), peer)
+						}
+						h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[4:8]))
 					}
 
 					// Peer is verified, generate a nonce
 					nonce := make([]byte, 4)
 					if _, err := rand.Read(nonce); err != nil {
-						log.Errorf("peer %d@%s nonce generation failed: %v\n", peer.ID, remote, err)
+						h.logger.Errorf("peer %d@%s nonce generation failed: %v\n", peer.ID, remote, err)
+						h.authFailed(peer, "login", err)
 						return h.WriteToPeer(append(MasterNAK, h.id...), peer)
 					}
 
 					peer.UpdateToken(nonce)
-					peer.Status = AuthBegin
+					peer.setStatus(AuthBegin)
+					h.authEvent(peer, NonceIssued, "")
 					return h.WriteToPeer(append(RepeaterACK, nonce...), peer)
 
 				default:
@@ -400,84 +1085,126 @@ func (h *Homebrew) handle(remote *net.UDPAddr, data []byte) error {
 					//repeaterID := uint32(data[4])<<24 | uint32(data[5])<<16 | uint32(data[6])<<8 | uint32(data[7])
 
 					if !peer.CheckRepeaterID(data[4:8]) {
-						log.Warningf("peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[4:8]))
-						//return h.WriteToPeer(append(MasterNAK, h.id...), peer)
+						if h.StrictRepeaterID {
+							h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (refused)\n", peer.ID, remote, hex.EncodeToString(data[4:8]))
+							h.authEvent(peer, KeyRejected, "invalid repeater ID")
+							if peer.rekey {
+								return h.failRekey(peer, "invalid repeater ID")
+							}
+							peer.setStatus(AuthNone)
+							h.authFailed(peer, "key", errors.New("invalid repeater ID"))
+							return h.WriteToPeer(append(MasterNAK, h.id...), peer)
+						}
+						h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[4:8]))
 					}
 
 					if len(data) != 40 {
-						log.Errorf("peer %d@%s sent wrong data length %d\n", peer.ID, remote, len(data))
-						peer.Status = AuthNone
+						h.logger.Errorf("peer %d@%s sent wrong data length %d\n", peer.ID, remote, len(data))
+						h.authEvent(peer, KeyRejected, "wrong data length")
+						if peer.rekey {
+							return h.failRekey(peer, "wrong data length")
+						}
+						peer.setStatus(AuthNone)
+						h.authFailed(peer, "key", errors.New("wrong data length"))
 						return h.WriteToPeer(append(MasterNAK, h.id...), peer)
 					}
 
-					if !bytes.Equal(data[8:], peer.Token) {
-						log.Errorf("peer %d@%s sent invalid key challenge token\n", peer.ID, remote)
-						peer.Status = AuthNone
+					// The key is SHA256(nonce + password)
+					key := sha256.Sum256(append(append([]byte{}, peer.Nonce...), peer.AuthKey...))
+					if subtle.ConstantTimeCompare(data[8:40], key[:]) != 1 {
+						h.logger.Errorf("peer %d@%s sent invalid key challenge token\n", peer.ID, remote)
+						h.authEvent(peer, KeyRejected, "invalid key challenge token")
+						if peer.rekey {
+							return h.failRekey(peer, "invalid key challenge token")
+						}
+						peer.setStatus(AuthNone)
+						h.authFailed(peer, "key", errors.New("invalid key challenge token"))
 						return h.WriteToPeer(append(MasterNAK, h.id...), peer)
 					}
 
-					log.Debugf("peer %d@%s auth done\n", peer.ID, remote)
-					peer.Status = AuthDone
+					h.logger.Debugf("peer %d@%s auth done\n", peer.ID, remote)
+					h.authEvent(peer, KeyAccepted, "")
+					var connected = !peer.rekey
+					if connected {
+						peer.connect(time.Now())
+					} else {
+						peer.setStatus(AuthDone)
+					}
+					peer.rekey = false
 					peer.Last.PingReceived = time.Now()
 					peer.Last.PongReceived = time.Now()
-					return h.WriteToPeer(append(RepeaterACK, h.id...), peer)
+					if err := h.WriteToPeer(append(RepeaterACK, h.id...), peer); err != nil {
+						return err
+					}
+					if connected {
+						h.peerConnected(peer)
+					}
+					return nil
 				}
 			}
 		} else { // peer.Outgoning
 			// Verify we have a matching peer ID
 			if !h.checkRepeaterID(data[6:10]) {
-				log.Warningf("peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[6:10]))
+				h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[6:10]))
 				//return nil
 			}
 
-			switch peer.Status {
+			switch status {
 			case AuthNone:
 				switch {
 				case bytes.Equal(data[:6], RepeaterACK):
-					log.Debugf("peer %d@%s sent nonce\n%s", peer.ID, remote, hex.EncodeToString(data[6:10]))
-					peer.Status = AuthBegin
+					h.logger.Debugf("peer %d@%s sent nonce\n%s", peer.ID, remote, hex.EncodeToString(data[6:10]))
+					peer.setStatus(AuthBegin)
 					peer.UpdateToken(data[6:10])
 					return h.handleAuth(peer)
 
 				case bytes.Equal(data[:6], MasterNAK):
-					log.Errorf("peer %d@%s refused login\n", peer.ID, remote)
-					peer.Status = AuthFailed
+					h.logger.Errorf("peer %d@%s refused login\n", peer.ID, remote)
+					peer.setStatus(AuthFailed)
 					if peer.UnlinkOnAuthFailure {
 						h.Unlink(peer.ID)
 					}
+					h.authFailed(peer, "login", ErrMasterNAK)
 					break
 
 				default:
-					log.Warningf("AuthNone peer %d@%s sent unexpected login reply (ignored)\n%s", peer.ID, remote, hex.Dump(data[:4]))
+					h.warnf(peer, "AuthNone peer %d@%s sent unexpected login reply (ignored)\n%s", peer.ID, remote, hex.Dump(data[:4]))
 					break
 				}
 
 			case AuthBegin:
 				switch {
 				case bytes.Equal(data[:6], MasterACK):
-					log.Infof("peer %d@%s accepted login\n", peer.ID, remote)
-					peer.Status = AuthDone
+					peer.RemoteSoftware = detectSoftware(data)
+					h.logger.Infof("peer %d@%s accepted login, software %q\n", peer.ID, remote, peer.RemoteSoftware)
+					peer.configured = false
+					peer.connect(time.Now())
 					peer.Last.PingSent = time.Now()
 					peer.Last.PongReceived = time.Now()
-					return h.WriteToPeer(buildConfigData(h.Config), peer)
+					h.peerConnected(peer)
+					return h.writeConfig(peer)
 
 				case bytes.Equal(data[:6], MasterNAK):
-					log.Errorf("peer %d@%s refused login\n", peer.ID, remote)
-					peer.Status = AuthFailed
+					h.logger.Errorf("peer %d@%s refused login\n", peer.ID, remote)
+					peer.setStatus(AuthFailed)
 					if peer.UnlinkOnAuthFailure {
 						h.Unlink(peer.ID)
 					}
+					h.authFailed(peer, "key", ErrMasterNAK)
 					break
 
 				case bytes.Equal(data[:6], RepeaterACK):
-					log.Infof("peer %d@%s accepted login\n", peer.ID, remote)
-					peer.Status = AuthDone
+					peer.RemoteSoftware = detectSoftware(data)
+					h.logger.Infof("peer %d@%s accepted login, software %q\n", peer.ID, remote, peer.RemoteSoftware)
+					peer.configured = false
+					peer.connect(time.Now())
 					peer.Last.PingSent = time.Now()
 					peer.Last.PongReceived = time.Now()
-					return h.WriteToPeer(buildConfigData(h.Config), peer)
+					h.peerConnected(peer)
+					return h.writeConfig(peer)
 
 				default:
-					log.Warningf("AuthBegin peer %d@%s sent unexpected login reply (ignored)\n%s", peer.ID, remote, hex.Dump(data[:4]))
+					h.warnf(peer, "AuthBegin peer %d@%s sent unexpected login reply (ignored)\n%s", peer.ID, remote, hex.Dump(data[:4]))
 					break
 				}
 			}
@@ -491,30 +1218,52 @@ func (h *Homebrew) handle(remote *net.UDPAddr, data []byte) error {
 				if err != nil {
 					return err
 				}
+				peer.received(len(data))
+				h.captureFrame(data, time.Now())
 				return h.handlePacket(p, peer)
 
+			case bytes.Equal(data[:4], DMRTalkerAlias):
+				return h.handleTalkerAlias(data, peer)
+
 			case len(data) == 10 && bytes.Equal(data[:6], MasterACK):
 				break
 
 			case len(data) == 11 && bytes.Equal(data[:7], MasterPing):
-				log.Debugf("peer %d@%s received master ping\n", peer.ID, remote)
+				h.logger.Debugf("peer %d@%s received master ping\n", peer.ID, remote)
 				peer.Last.PingReceived = time.Now()
 				return h.WriteToPeer(append(RepeaterPong, data[7:]...), peer)
 
 			case len(data) == 11 && bytes.Equal(data[:7], RepeaterPing):
-				log.Debugf("peer %d@%s received repeater ping\n", peer.ID, remote)
+				h.logger.Debugf("peer %d@%s received repeater ping\n", peer.ID, remote)
 				peer.Last.PingReceived = time.Now()
 				return h.WriteToPeer(append(MasterPong, data[7:]...), peer)
 
 			case bytes.Equal(data[:4], RepeaterConfig):
-				log.Debugf("peer %d@%s sent config\n", peer.ID, remote)
-				peer.Config, _ = parseConfigData(data)
-				printConfig(peer.Config)
-				return h.WriteToPeer(append(RepeaterACK, h.id...), peer)
+				h.logger.Debugf("peer %d@%s sent config\n", peer.ID, remote)
+				config, err := parseConfigData(data)
+				if err != nil {
+					h.warnf(peer, "peer %d@%s sent invalid config: %v (refused)\n", peer.ID, remote, err)
+					peer.setStatus(AuthNone)
+					h.authFailed(peer, "config", err)
+					return h.WriteToPeer(append(MasterNAK, h.id...), peer)
+				}
+				ready := peer.Config == nil
+				peer.Config = config
+				h.printConfig(peer.Config)
+				if err := h.WriteToPeer(append(RepeaterACK, h.id...), peer); err != nil {
+					return err
+				}
+				if ready && h.OnPeerReady != nil {
+					h.OnPeerReady(peer)
+				}
+				return nil
+
+			case bytes.Equal(data[:4], RepeaterOptions):
+				return h.handleOptions(data, peer)
 
 			default:
-				log.Warningf("peer %d@%s sent unexpected packet (incoming, status=%s):\n", peer.ID, remote, peer.Status.String())
-				log.Debug(hex.Dump(data))
+				h.warnf(peer, "peer %d@%s sent unexpected packet (incoming, status=%s):\n", peer.ID, remote, status.String())
+				h.logger.Debugf("%s", hex.Dump(data))
 				break
 			}
 		} else { // peer.Outgoning
@@ -524,53 +1273,65 @@ func (h *Homebrew) handle(remote *net.UDPAddr, data []byte) error {
 				if err != nil {
 					return err
 				}
+				peer.received(len(data))
+				h.captureFrame(data, time.Now())
 				return h.handlePacket(p, peer)
 
+			case bytes.Equal(data[:4], DMRTalkerAlias):
+				return h.handleTalkerAlias(data, peer)
+
 			case len(data) == 10 && bytes.Equal(data[:6], MasterACK):
 				if !h.checkRepeaterID(data[6:10]) {
-					log.Warningf("peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[6:10]))
+					h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[6:10]))
 					return nil
 				}
+				peer.configured = true
 				peer.Last.PingSent = time.Now()
 				return h.WriteToPeer(append(MasterPing, h.id...), peer)
 
 			case len(data) == 10 && bytes.Equal(data[:6], MasterNAK):
 				if !h.checkRepeaterID(data[6:10]) {
-					log.Warningf("peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[6:10]))
+					h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[6:10]))
 					return nil
 				}
 
-				log.Errorf("peer %d@%s deauthenticated us; re-authenticating\n", peer.ID, remote)
-				peer.Status = AuthFailed
+				h.logger.Errorf("peer %d@%s deauthenticated us; re-authenticating\n", peer.ID, remote)
+				peer.setStatus(AuthFailed)
+				if peer.configured {
+					h.peerDisconnected(peer, ErrMasterNAK)
+				} else {
+					h.authFailed(peer, "config", ErrMasterNAK)
+				}
 				return h.handleAuth(peer)
 
 			case len(data) == 10 && bytes.Equal(data[:6], RepeaterACK):
 				if !h.checkRepeaterID(data[6:10]) {
-					log.Warningf("peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[6:10]))
+					h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[6:10]))
 					return nil
 				}
+				peer.configured = true
 				peer.Last.PingSent = time.Now()
 				return h.WriteToPeer(append(MasterPing, h.id...), peer)
 
 			case len(data) == 11 && bytes.Equal(data[:7], MasterPong):
 				if !h.checkRepeaterID(data[7:11]) {
-					log.Warningf("peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[7:11]))
+					h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[7:11]))
 					return nil
 				}
-				peer.Last.PongReceived = time.Now()
+				peer.pong(time.Now())
 				break
 
 			case len(data) == 11 && bytes.Equal(data[:7], RepeaterPong):
 				if !h.checkRepeaterID(data[7:11]) {
-					log.Warningf("peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[7:11]))
+					h.warnf(peer, "peer %d@%s sent invalid repeater ID %q (ignored)\n", peer.ID, remote, hex.EncodeToString(data[7:11]))
 					return nil
 				}
-				peer.Last.PongReceived = time.Now()
+				peer.pong(time.Now())
 				break
 
 			default:
-				log.Warningf("peer %d@%s sent unexpected packet (outgoing, status=%s):\n", peer.ID, remote, peer.Status.String())
-				log.Debug(hex.Dump(data))
+				h.warnf(peer, "peer %d@%s sent unexpected packet (outgoing, status=%s):\n", peer.ID, remote, status.String())
+				h.logger.Debugf("%s", hex.Dump(data))
 				break
 			}
 		}
@@ -583,15 +1344,16 @@ func (h *Homebrew) handleAuth(peer *Peer) error {
 	if !peer.Incoming {
 		peer.Last.PacketReceived = time.Now()
 
-		switch peer.Status {
+		status, _ := peer.session()
+		switch status {
 		case AuthNone:
 			// Send login packet
 			peer.Last.AuthSent = time.Now()
-			return h.WriteToPeer(append(RepeaterLogin, h.id...), peer)
+			return h.writeControl(append(RepeaterLogin, h.id...), peer)
 
 		case AuthBegin:
 			// Send repeater key exchange packet
-			return h.WriteToPeer(append(append(RepeaterKey, h.id...), peer.Token...), peer)
+			return h.writeControl(append(append(RepeaterKey, h.id...), peer.Token...), peer)
 		}
 	}
 	return nil
@@ -604,18 +1366,102 @@ func (h *Homebrew) handlePacket(p *dmr.Packet, peer *Peer) error {
 	// Record last received time
 	h.last = time.Now()
 
+	// Drop our own frames, looped back to us through a reflector mesh
+	if p.RepeaterID == h.Config.ID {
+		peer.count(&peer.Counters.LoopedFrames)
+		h.logger.Debugf("peer %d@%s sent our own frame, stream %#08x (dropped)\n", peer.ID, peer.Addr, p.StreamID)
+		return nil
+	}
+
+	// Drop frames of incoming peers claiming another repeater's ID
+	if h.EnforceRepeaterID && peer.Incoming && p.RepeaterID != peer.ID {
+		peer.count(&peer.Counters.SpoofedFrames)
+		h.warnf(peer, "peer %d@%s sent frame with repeater ID %d (dropped)\n", peer.ID, peer.Addr, p.RepeaterID)
+		return nil
+	}
+
+	// Drop frames with addressing no radio sends
+	if h.invalidFrame(p, peer) {
+		return nil
+	}
+
+	// Drop frames failing the FEC and CRC checks
+	if h.corruptFrame(p, peer) {
+		return nil
+	}
+
+	// Idle frames only keep the hangtime state, and don't start a stream
+	if p.DataType == dmr.Idle {
+		if !h.idleFrame(p, peer, h.last) || h.Paused() {
+			return nil
+		}
+		return h.forward(p, peer)
+	}
+
+	// Drop sources and destinations the peer isn't allowed to use
+	if !peer.Permits(p) {
+		peer.count(&peer.Counters.DeniedFrames)
+		h.warnf(peer, "peer %d@%s sent denied call from %d to %s%d (dropped)\n", peer.ID, peer.Addr, p.SrcID, dmr.CallTypeShortName[p.CallType], p.DstID)
+		return nil
+	}
+
+	// Drop streams already received by another path
+	if h.duplicateStream(p, peer, h.last) {
+		peer.count(&peer.Counters.DuplicateFrames)
+		return nil
+	}
+
+	// Only a single stream per timeslot is allowed
+	if !h.acceptStream(p, peer, h.last) {
+		return nil
+	}
+	h.printPacket(p)
+
+	// Report the quality of voice frames
+	if p.DataType >= dmr.VoiceBurstA && p.DataType <= dmr.VoiceBurstF {
+		peer.quality(p)
+		if h.OnFrameQuality != nil {
+			h.OnFrameQuality(peer, p)
+		}
+	}
+
+	// Cut off transmissions exceeding the maximum duration
+	if h.streamExpired(p, peer, h.last) {
+		return nil
+	}
+
+	// Learn where the subscriber is, for routing private calls
+	h.learnRoute(p.SrcID, peer, h.last)
+
+	// Track transmissions for the last heard list
+	h.updateLastHeard(p, peer, h.last)
+
+	// Report positions embedded in the stream
+	h.embeddedLC(p, peer, h.last)
+
+	if h.Paused() {
+		return nil
+	}
+	return h.dejitter(p, peer)
+}
+
+// forward passes a packet received from peer on to the packet handlers, or
+// routes it to the other peers.
+func (h *Homebrew) forward(p *dmr.Packet, peer *Peer) error {
 	// Offload packet to handle callback
 	if peer.PacketReceived != nil {
 		return peer.PacketReceived(h, p)
 	}
 	if h.pf == nil {
+		if h.Observer {
+			return nil
+		}
 		if p.CallType == dmr.CallTypePrivate {
-			// process PC
+			return h.sendPrivate(p, peer)
 		}
 
 		if p.CallType == dmr.CallTypeGroup {
-			peer.TGID = p.DstID
-			peer.Last.TGSubscribed = time.Now()
+			peer.Subscribe(p.DstID, p.Timeslot)
 
 			return h.SendTG(p, peer)
 		}
@@ -627,70 +1473,11 @@ func (h *Homebrew) handlePacket(p *dmr.Packet, peer *Peer) error {
 }
 
 func (h *Homebrew) keepalive(stop <-chan bool) {
+	defer h.workers.Done()
 	for {
 		select {
-		case <-time.After(time.Second):
-			now := time.Now()
-
-			for _, peer := range h.getPeers() {
-				// Ping protocol only applies to outgoing links, and also the auth retries
-				// are entirely up to the peer.
-				if peer.Incoming {
-					/*switch peer.Status {
-					case AuthDone:
-						switch {
-						case now.Sub(peer.Last.PingReceived) > PingTimeout:
-							peer.Status = AuthNone
-							log.Errorf("peer %d@%s not requesting to ping; dropping connection", peer.ID, peer.Addr)
-							if err := h.WriteToPeer(append(MasterClosing, h.id...), peer); err != nil {
-								log.Errorf("peer %d@%s close failed: %v\n", peer.ID, peer.Addr, err)
-							}
-							break
-						}
-						break
-					}*/
-				} else {
-					switch peer.Status {
-					case AuthFailed:
-						switch {
-						case now.Sub(peer.Last.AuthSent) > AuthTimeout:
-							peer.Status = AuthNone
-							log.Errorf("peer %d@%s login retrying\n", peer.ID, peer.Addr)
-							if err := h.handleAuth(peer); err != nil {
-								log.Errorf("peer %d@%s retry failed: %v\n", peer.ID, peer.Addr, err)
-							}
-							break
-						}
-					case AuthNone, AuthBegin:
-						switch {
-						case now.Sub(peer.Last.PacketReceived) > AuthTimeout:
-							peer.Status = AuthFailed
-							log.Errorf("peer %d@%s not responding to login; waiting retry\n", peer.ID, peer.Addr)
-							break
-						}
-					case AuthDone:
-						switch {
-						case now.Sub(peer.Last.PongReceived) > PingTimeout:
-							peer.Status = AuthNone
-							log.Errorf("peer %d@%s not responding to ping; trying to re-establish connection", peer.ID, peer.Addr)
-							if err := h.WriteToPeer(append(RepeaterClosing, h.id...), peer); err != nil {
-								log.Errorf("peer %d@%s close failed: %v\n", peer.ID, peer.Addr, err)
-							}
-							if err := h.handleAuth(peer); err != nil {
-								log.Errorf("peer %d@%s retry failed: %v\n", peer.ID, peer.Addr, err)
-							}
-							break
-
-						case now.Sub(peer.Last.PingSent) > PingInterval:
-							peer.Last.PingSent = now
-							if err := h.WriteToPeer(append(RepeaterPing, h.id...), peer); err != nil {
-								log.Errorf("peer %d@%s ping failed: %v\n", peer.ID, peer.Addr, err)
-							}
-							break
-						}
-					}
-				}
-			}
+		case <-time.After(keepaliveInterval):
+			h.housekeeping(time.Now())
 
 		case <-stop:
 			return
@@ -698,30 +1485,121 @@ func (h *Homebrew) keepalive(stop <-chan bool) {
 	}
 }
 
-func (h *Homebrew) parseRepeaterID(data []byte) (uint32, error) {
-	id, err := strconv.ParseUint(string(data), 16, 32)
-	if err != nil {
-		return 0, err
+// housekeeping runs the periodic ping, timeout and login retry checks.
+func (h *Homebrew) housekeeping(now time.Time) {
+	h.expireRoutes(now)
+	h.expireWarnings(now)
+	h.expireDedup(now)
+	h.expireSubscriptions(now)
+	h.expireStreams(now)
+	h.expireTalkerAliases(now)
+	h.expireResolved(now)
+
+	for _, peer := range h.getPeers() {
+		// Incoming peers do the pinging, and also the auth retries are entirely
+		// up to them, see DropSilentIncoming.
+		if peer.Incoming {
+			if peer.rekey && now.Sub(peer.Last.AuthSent) > h.Timeouts.AuthTimeout {
+				if err := h.failRekey(peer, "timeout"); err != nil {
+					h.logger.Errorf("peer %d@%s close failed: %v\n", peer.ID, peer.Addr, err)
+				}
+			}
+			if h.DropSilentIncoming && peer.done() && now.Sub(peer.Last.PingReceived) > h.Timeouts.PingTimeout {
+				peer.setStatus(AuthNone)
+				h.logger.Errorf("peer %d@%s not pinging; dropping connection\n", peer.ID, peer.Addr)
+				if err := h.WriteToPeer(BuildClosing(h.Config.ID, true), peer); err != nil {
+					h.logger.Errorf("peer %d@%s close failed: %v\n", peer.ID, peer.Addr, err)
+				}
+				if err := h.Unlink(peer.ID); err != nil {
+					h.logger.Errorf("peer %d@%s unlink failed: %v\n", peer.ID, peer.Addr, err)
+				}
+				h.peerDisconnected(peer, ErrPingTimeout)
+			}
+		} else {
+			status, _ := peer.session()
+			switch status {
+			case AuthFailed:
+				switch {
+				case now.Sub(peer.Last.AuthSent) > h.Timeouts.AuthTimeout:
+					peer.setStatus(AuthNone)
+					h.logger.Errorf("peer %d@%s login retrying\n", peer.ID, peer.Addr)
+					if err := h.handleAuth(peer); err != nil {
+						h.logger.Errorf("peer %d@%s retry failed: %v\n", peer.ID, peer.Addr, err)
+					}
+					break
+				}
+			case AuthNone, AuthBegin:
+				switch {
+				case now.Sub(peer.Last.PacketReceived) > h.Timeouts.AuthTimeout:
+					peer.setStatus(AuthFailed)
+					h.logger.Errorf("peer %d@%s not responding to login; waiting retry\n", peer.ID, peer.Addr)
+					break
+				}
+			case AuthDone:
+				switch {
+				case now.Sub(peer.Last.PongReceived) > h.Timeouts.PingTimeout:
+					peer.setStatus(AuthNone)
+					h.logger.Errorf("peer %d@%s not responding to ping; trying to re-establish connection", peer.ID, peer.Addr)
+					if err := h.WriteToPeer(BuildClosing(h.Config.ID, false), peer); err != nil {
+						h.logger.Errorf("peer %d@%s close failed: %v\n", peer.ID, peer.Addr, err)
+					}
+					h.peerDisconnected(peer, ErrPingTimeout)
+					if err := h.handleAuth(peer); err != nil {
+						h.logger.Errorf("peer %d@%s retry failed: %v\n", peer.ID, peer.Addr, err)
+					}
+					break
+
+				case now.Sub(peer.Last.PingSent) > h.Timeouts.PingInterval:
+					peer.Last.PingSent = now
+					if err := h.WriteToPeer(append(RepeaterPing, h.id...), peer); err != nil {
+						h.logger.Errorf("peer %d@%s ping failed: %v\n", peer.ID, peer.Addr, err)
+					}
+					break
+				}
+			}
+		}
 	}
-	return uint32(id), nil
 }
 
 // Interface compliance check
 var _ dmr.Repeater = (*Homebrew)(nil)
 
-func printPacket(p *dmr.Packet) {
-	log.Debugf("packet from ID %d to %s%d, TS%d, %s, stream %d, %s\n", p.SrcID, dmr.CallTypeShortName[p.CallType], p.DstID, p.Timeslot+1, dmr.CallTypeName[p.CallType], p.StreamID, dmr.DataTypeName[p.DataType])
+// printPacket logs a packet, with the callsigns of the source and private call
+// destination if the IDResolver knows them.
+func (h *Homebrew) printPacket(p *dmr.Packet) {
+	if !h.debugEnabled() {
+		return
+	}
+
+	var dst = fmt.Sprintf("TG %d", p.DstID)
+	if p.CallType != dmr.CallTypeGroup {
+		dst = h.displayID(p.DstID)
+	}
+	h.logger.Debugf("packet from %s to %s, TS%d, %s, stream %d, %s\n", h.displayID(p.SrcID), dst, p.Timeslot+1, p.CallTypeString(), p.StreamID, p.DataTypeString())
+}
+
+// DescribePacket describes the packet like dmr.Packet.String, with the source
+// and private call destination shown with their callsign, see IDResolver.
+func (h *Homebrew) DescribePacket(p *dmr.Packet) string {
+	var dst = fmt.Sprintf("TG %d", p.DstID)
+	if p.CallType != dmr.CallTypeGroup {
+		dst = h.displayID(p.DstID)
+	}
+	return fmt.Sprintf("%s → %s TS%d %s %s stream %#08x",
+		h.displayID(p.SrcID), dst, p.Timeslot+1, p.CallTypeString(), p.DataTypeString(), p.StreamID)
 }
 
-func printConfig(c *RepeaterConfiguration) {
-	log.Debugf("config id: %d, cs: %s\n", c.ID, c.Callsign)
-	log.Debugf("config rx: %d, tx: %d, pw: %d, cc: %d, slots: %d\n", c.RXFreq, c.TXFreq, c.TXPower, c.ColorCode, c.Slots)
-	log.Debugf("config lat: %f, lon: %f, loc: %s, h: %d\n", c.Latitude, c.Longitude, c.Location, c.Height)
-	log.Debugf("config desc: %s, url: %s\n", c.Description, c.URL)
-	log.Debugf("config sw: %s, hw: %s\n", c.SoftwareID, c.PackageID)
+func (h *Homebrew) printConfig(c *RepeaterConfiguration) {
+	h.logger.Debugf("config id: %d, cs: %s\n", c.ID, c.Callsign)
+	h.logger.Debugf("config rx: %d, tx: %d, pw: %d, cc: %d, slots: %d\n", c.RXFreq, c.TXFreq, c.TXPower, c.ColorCode, c.Slots)
+	h.logger.Debugf("config lat: %f, lon: %f, loc: %s, h: %d\n", c.Latitude, c.Longitude, c.Location, c.Height)
+	h.logger.Debugf("config desc: %s, url: %s\n", c.Description, c.URL)
+	h.logger.Debugf("config sw: %s, hw: %s\n", c.SoftwareID, c.PackageID)
 }
 
-func packRepeaterID(id uint32) []byte {
+// RepeaterIDBytes packs a repeater ID to the 4 bytes used in the Homebrew
+// frames, such as the trailing ID in MSTACK and RPTACK.
+func RepeaterIDBytes(id uint32) []byte {
 	var repeaterID = make([]byte, 4)
 
 	repeaterID[0] = byte(id >> 24)
@@ -732,12 +1610,51 @@ func packRepeaterID(id uint32) []byte {
 	return repeaterID
 }
 
-func unpackRepeaterID(data []byte) uint32 {
-	return (uint32(data[0]) << 24) | (uint32(data[1]) << 16) | (uint32(data[2]) << 8) | uint32(data[3])
+// ParseRepeaterIDBytes unpacks a repeater ID from 4 binary bytes. Some masters
+// send the ID as 8 hex digits instead, BrandMeister release 20190421-185653
+// switched from upper case to lower case digits, so those are accepted in
+// either case. Returns 0 if the data can't be parsed.
+func ParseRepeaterIDBytes(data []byte) uint32 {
+	switch len(data) {
+	case 4:
+		return (uint32(data[0]) << 24) | (uint32(data[1]) << 16) | (uint32(data[2]) << 8) | uint32(data[3])
+	case 8:
+		id, err := strconv.ParseUint(string(data), 16, 32)
+		if err != nil {
+			return 0
+		}
+		return uint32(id)
+	default:
+		return 0
+	}
 }
 
 // buildData converts DMR packet format to Homebrew packet format.
-func buildData(p *dmr.Packet, repeaterID uint32) []byte {
+func buildData(p *dmr.Packet, repeaterID uint32) ([]byte, error) {
+	return MarshalHomebrew(p, repeaterID)
+}
+
+// MarshalHomebrew packs the packet to a 55 byte Homebrew DMRD frame sent by
+// the given repeater ID, for example to store frames or pass them over another
+// transport. See UnmarshalHomebrew.
+func MarshalHomebrew(p *dmr.Packet, repeaterID uint32) ([]byte, error) {
+	if len(p.Data) > 33 {
+		return nil, fmt.Errorf("homebrew: expected at most 33 bytes of DMR data, got %d", len(p.Data))
+	}
+	if p.DataType > dmr.VoiceBurstF {
+		return nil, fmt.Errorf("homebrew: data type %d can't be sent", p.DataType)
+	}
+
+	var callType uint8
+	switch p.CallType {
+	case dmr.CallTypeGroup:
+		callType = 0x00
+	case dmr.CallTypePrivate:
+		callType = 0x01
+	default:
+		return nil, fmt.Errorf("homebrew: unexpected call type %d", p.CallType)
+	}
+
 	var data = make([]byte, 55)
 	copy(data[:4], DMRData)
 	data[4] = p.Sequence
@@ -751,7 +1668,7 @@ func buildData(p *dmr.Packet, repeaterID uint32) []byte {
 	data[12] = uint8(repeaterID >> 16)
 	data[13] = uint8(repeaterID >> 8)
 	data[14] = uint8(repeaterID)
-	data[15] = ((p.Timeslot & 0x01) << 7) | ((p.CallType & 0x01) << 6)
+	data[15] = ((p.Timeslot & 0x01) << 7) | (callType << 6)
 	data[16] = uint8(p.StreamID >> 24)
 	data[17] = uint8(p.StreamID >> 16)
 	data[18] = uint8(p.StreamID >> 8)
@@ -774,13 +1691,27 @@ func buildData(p *dmr.Packet, repeaterID uint32) []byte {
 		data[15] |= (p.DataType)
 	}
 
-	return data
+	return data, nil
 }
 
 // parseData converts Homebrew packet format to DMR packet format
 func parseData(data []byte) (*dmr.Packet, error) {
-	if len(data) != 55 {
-		return nil, fmt.Errorf("homebrew: expected 55 data bytes, got %d", len(data))
+	return UnmarshalHomebrew(data)
+}
+
+// UnmarshalHomebrew parses a 55 byte Homebrew DMRD frame, as packed by
+// MarshalHomebrew. The RepeaterID of the packet is the repeater that sent it.
+func UnmarshalHomebrew(data []byte) (*dmr.Packet, error) {
+	if err := checkLength(data, 55); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(data[:4], DMRData) {
+		return nil, fmt.Errorf("homebrew: expected %s frame, got %q", DMRData, data[:4])
+	}
+
+	var callType = dmr.CallTypeGroup
+	if (data[15]>>6)&0x01 == 0x01 {
+		callType = dmr.CallTypePrivate
 	}
 
 	var dataType uint8
@@ -793,7 +1724,7 @@ func parseData(data []byte) (*dmr.Packet, error) {
 		dataType = (data[15] & 0x0f)
 		break
 	default: // unknown/unused
-		return nil, errors.New("homebrew: unexpected frame type 0b11")
+		return nil, fmt.Errorf("%w 0b11", ErrBadFrameType)
 	}
 
 	var p = &dmr.Packet{
@@ -802,7 +1733,7 @@ func parseData(data []byte) (*dmr.Packet, error) {
 		DstID:      uint32(data[8])<<16 | uint32(data[9])<<8 | uint32(data[10]),
 		RepeaterID: uint32(data[11])<<24 | uint32(data[12])<<16 | uint32(data[13])<<8 | uint32(data[14]),
 		Timeslot:   (data[15] >> 7) & 0x01,
-		CallType:   (data[15] >> 6) & 0x01,
+		CallType:   callType,
 		StreamID:   uint32(data[16])<<24 | uint32(data[17])<<16 | uint32(data[18])<<8 | uint32(data[19]),
 		DataType:   dataType,
 		BER:        data[53],
@@ -817,8 +1748,8 @@ func parseData(data []byte) (*dmr.Packet, error) {
 }
 
 func parseConfigData(data []byte) (*RepeaterConfiguration, error) {
-	if len(data) != 302 {
-		return nil, fmt.Errorf("homebrew: expected 302 data bytes, got %d", len(data))
+	if err := checkLength(data, 302); err != nil {
+		return nil, err
 	}
 
 	var config = make([]byte, 302) // copy DMR config data
@@ -837,7 +1768,7 @@ func parseConfigData(data []byte) (*RepeaterConfiguration, error) {
 
 	var c = &RepeaterConfiguration{
 		ID:          uint32(config[4])<<24 | uint32(config[5])<<16 | uint32(config[6])<<8 | uint32(config[7]),
-		Callsign:    strings.Trim(string(config[8:8+8]), " "),
+		Callsign:    configText(config[8 : 8+8]),
 		RXFreq:      uint32(rx),
 		TXFreq:      uint32(tx),
 		TXPower:     uint8(power),
@@ -845,17 +1776,29 @@ func parseConfigData(data []byte) (*RepeaterConfiguration, error) {
 		Latitude:    float32(lat),
 		Longitude:   float32(lon),
 		Height:      uint16(height),
-		Location:    strings.Trim(string(config[58:58+20]), " "),
-		Description: strings.Trim(string(config[78:78+19]), " "),
+		Location:    configText(config[58 : 58+20]),
+		Description: configText(config[78 : 78+19]),
 		Slots:       uint8(slots),
-		URL:         strings.Trim(string(config[98:98+124]), " "),
-		SoftwareID:  strings.Trim(string(config[222:222+40]), " "),
-		PackageID:   strings.Trim(string(config[262:262+40]), " ")}
+		URL:         configText(config[98 : 98+124]),
+		SoftwareID:  configText(config[222 : 222+40]),
+		PackageID:   configText(config[262 : 262+40])}
 
 	return c, nil
 }
 
+// configText returns a string field of the configuration without its
+// padding. Leading spaces are part of the value, and are kept.
+func configText(field []byte) string {
+	return strings.TrimRight(string(field), " \x00")
+}
+
 func buildConfigData(c *RepeaterConfiguration) []byte {
+	return buildProfileConfigData(c, ConfigProfileDefault)
+}
+
+// buildProfileConfigData builds our configuration frame, with the fields
+// formatted as expected by the master profile.
+func buildProfileConfigData(c *RepeaterConfiguration, profile ConfigProfile) []byte {
 	var data = make([]byte, 302) // copy DMR config data
 
 	if c.ColorCode < 1 {
@@ -870,6 +1813,9 @@ func buildConfigData(c *RepeaterConfiguration) []byte {
 	if c.Slots > 4 {
 		c.Slots = 4
 	}
+	if c.Height > 999 {
+		c.Height = 999
+	}
 	if c.SoftwareID == "" {
 		c.SoftwareID = dmr.SoftwareID
 	}
@@ -877,14 +1823,7 @@ func buildConfigData(c *RepeaterConfiguration) []byte {
 		c.PackageID = dmr.PackageID
 	}
 
-	var lat = fmt.Sprintf("%-08f", c.Latitude)
-	if len(lat) > 8 {
-		lat = lat[:8]
-	}
-	var lon = fmt.Sprintf("%-09f", c.Longitude)
-	if len(lon) > 9 {
-		lon = lon[:9]
-	}
+	lat, lon := profile.position(c.Latitude, c.Longitude)
 
 	copy(data[:4], RepeaterConfig)
 	data[4] = uint8(c.ID >> 24)
@@ -892,7 +1831,7 @@ func buildConfigData(c *RepeaterConfiguration) []byte {
 	data[6] = uint8(c.ID >> 8)
 	data[7] = uint8(c.ID)
 
-	copy(data[8:8+8], []byte(fmt.Sprintf("%-8s", c.Callsign)))
+	copy(data[8:8+8], []byte(profile.callsign(c.Callsign)))
 	copy(data[16:16+9], []byte(fmt.Sprintf("%09d", c.RXFreq)))
 	copy(data[25:25+9], []byte(fmt.Sprintf("%09d", c.TXFreq)))
 	copy(data[34:34+2], []byte(fmt.Sprintf("%02d", c.TXPower)))
@@ -900,12 +1839,12 @@ func buildConfigData(c *RepeaterConfiguration) []byte {
 	copy(data[38:38+8], []byte(lat))
 	copy(data[46:46+9], []byte(lon))
 	copy(data[55:58], []byte(fmt.Sprintf("%03d", c.Height)))
-	copy(data[58:58+20], []byte(fmt.Sprintf("%-20s", c.Location)))
-	copy(data[78:78+19], []byte(fmt.Sprintf("%-19s", c.Description)))
+	copy(data[58:58+20], []byte(profile.text(c.Location, 20)))
+	copy(data[78:78+19], []byte(profile.text(c.Description, 19)))
 	copy(data[97:97+1], []byte(fmt.Sprintf("%01d", c.Slots)))
-	copy(data[98:98+124], []byte(fmt.Sprintf("%-124s", c.URL)))
-	copy(data[222:222+40], []byte(fmt.Sprintf("%-40s", c.SoftwareID)))
-	copy(data[262:262+40], []byte(fmt.Sprintf("%-40s", c.PackageID)))
+	copy(data[98:98+124], []byte(profile.text(c.URL, 124)))
+	copy(data[222:222+40], []byte(profile.text(c.SoftwareID, 40)))
+	copy(data[262:262+40], []byte(profile.text(c.PackageID, 40)))
 
 	return data
 }
@@ -0,0 +1,1237 @@
+package homebrew
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	stdlog "log"
+	"net"
+	"net/netip"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/polkabana/go-dmr"
+)
+
+var testConfig = &RepeaterConfiguration{
+	Callsign:  "PD0MZ",
+	ID:        2042214,
+	RXFreq:    438800000,
+	TXFreq:    431200000,
+	TXPower:   5,
+	ColorCode: 1,
+	Slots:     3,
+}
+
+func testHomebrew(t *testing.T) *Homebrew {
+	t.Helper()
+
+	h, err := New(testConfig, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Most tests pass frames without a repeater ID, see TestEnforceRepeaterID.
+	h.EnforceRepeaterID = false
+	return h
+}
+
+func testRemote(t *testing.T) *net.UDPConn {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// expectFrame reads frames from conn until one with the given prefix arrives.
+func expectFrame(t *testing.T, conn *net.UDPConn, prefix []byte, timeout time.Duration) []byte {
+	t.Helper()
+
+	var data = make([]byte, 512)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		n, _, err := conn.ReadFromUDP(data)
+		if err != nil {
+			t.Fatalf("expected %q frame: %v", prefix, err)
+		}
+		if bytes.HasPrefix(data[:n], prefix) {
+			return data[:n]
+		}
+	}
+}
+
+// testIncomingPeer links an authenticated incoming peer backed by a local socket.
+func testIncomingPeer(t *testing.T, h *Homebrew, id uint32) (*Peer, *net.UDPConn) {
+	t.Helper()
+
+	remote := testRemote(t)
+	t.Cleanup(func() { remote.Close() })
+
+	peer := &Peer{
+		ID:       id,
+		Addr:     remote.LocalAddr().(*net.UDPAddr),
+		AuthKey:  []byte("passw0rd"),
+		Incoming: true,
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+	peer.Status = AuthDone
+	return peer, remote
+}
+
+// readFrames returns all DMRD frames received on conn within timeout.
+func readFrames(t *testing.T, conn *net.UDPConn, timeout time.Duration) []*dmr.Packet {
+	t.Helper()
+
+	var (
+		data    = make([]byte, 512)
+		packets []*dmr.Packet
+	)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		n, _, err := conn.ReadFromUDP(data)
+		if err != nil {
+			return packets
+		}
+		if bytes.HasPrefix(data[:n], DMRData) {
+			p, err := parseData(data[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			packets = append(packets, p)
+		}
+	}
+}
+
+// testData builds the DMRD frame of a packet sent by the repeater ID.
+func testData(t *testing.T, p *dmr.Packet, repeaterID uint32) []byte {
+	t.Helper()
+
+	data, err := buildData(p, repeaterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func testPacket(src, dst uint32, callType uint8) *dmr.Packet {
+	return &dmr.Packet{
+		SrcID:    src,
+		DstID:    dst,
+		CallType: callType,
+		StreamID: src<<8 | dst,
+		DataType: dmr.VoiceBurstA,
+		Data:     make([]byte, 33),
+	}
+}
+
+func TestInlineKeepaliveTimeout(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.InlineKeepalive = true
+
+	remote := testRemote(t)
+	defer remote.Close()
+
+	peer := &Peer{
+		ID:      1234,
+		Addr:    remote.LocalAddr().(*net.UDPAddr),
+		AuthKey: []byte("passw0rd"),
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, remote, RepeaterLogin, time.Second)
+
+	// Pretend the link was up, but the master stopped answering pings.
+	peer.Status = AuthDone
+	peer.Last.PingSent = time.Now()
+	peer.Last.PongReceived = time.Now().Add(-2 * h.Timeouts.PingTimeout)
+
+	go h.ListenAndServe()
+
+	expectFrame(t, remote, RepeaterClosing, 3*keepaliveInterval)
+	expectFrame(t, remote, RepeaterLogin, time.Second)
+}
+
+func TestRepeaterIDBytes(t *testing.T) {
+	var id uint32 = 0x2a4b6c61
+	if got := RepeaterIDBytes(id); !bytes.Equal(got, []byte{0x2a, 0x4b, 0x6c, 0x61}) {
+		t.Fatalf("unexpected bytes %x", got)
+	}
+	if got := append(MasterACK, RepeaterIDBytes(id)...); len(got) != 10 {
+		t.Fatalf("unexpected MSTACK length %d", len(got))
+	}
+
+	tests := map[string]uint32{
+		"\x2a\x4b\x6c\x61": id,
+		"2a4b6c61":         id,
+		"2A4B6C61":         id,
+		"\x2a\x4b\x6c\x41": 0x2a4b6c41, // binary IDs are not case folded
+		"2a4b6c6g":         0,
+		"2a4b6c":           0,
+	}
+	for data, want := range tests {
+		if got := ParseRepeaterIDBytes([]byte(data)); got != want {
+			t.Errorf("ParseRepeaterIDBytes(%q): got %#08x, want %#08x", data, got, want)
+		}
+	}
+
+	h := testHomebrew(t)
+	defer h.Close()
+	if !h.checkRepeaterID(RepeaterIDBytes(testConfig.ID)) {
+		t.Fatal("expected own binary ID to match")
+	}
+	if h.checkRepeaterID(RepeaterIDBytes(testConfig.ID ^ 0x20)) {
+		t.Fatal("expected case folded binary ID not to match")
+	}
+}
+
+func TestSendSkipsUnauthenticatedPeers(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+	done, doneRemote := testIncomingPeer(t, h, 1002)
+	begin, beginRemote := testIncomingPeer(t, h, 1003)
+	begin.Status = AuthBegin
+	failed, failedRemote := testIncomingPeer(t, h, 1004)
+	failed.Status = AuthFailed
+	for _, peer := range []*Peer{done, begin, failed} {
+		peer.Subscribe(91, 0)
+	}
+
+	if err := h.SendSync(testPacket(2001, 91, dmr.CallTypeGroup)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SendTG(testPacket(2002, 91, dmr.CallTypeGroup), origin); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFrames(t, doneRemote, 50*time.Millisecond); len(got) != 2 {
+		t.Fatalf("expected 2 frames on authenticated peer, got %d", len(got))
+	}
+	if got := readFrames(t, beginRemote, 50*time.Millisecond); len(got) != 0 {
+		t.Fatalf("expected no frames on authenticating peer, got %d", len(got))
+	}
+	if got := readFrames(t, failedRemote, 50*time.Millisecond); len(got) != 0 {
+		t.Fatalf("expected no frames on failed peer, got %d", len(got))
+	}
+}
+
+func TestIncomingHandshake(t *testing.T) {
+	transport := newTestTransport()
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- h.ListenAndServe() }()
+	defer func() {
+		h.Close()
+		<-done
+	}()
+
+	var (
+		masterID = RepeaterIDBytes(testConfig.ID)
+		frame    = func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	)
+	login := func(addr *net.UDPAddr, id uint32, password string) []byte {
+		repeaterID := RepeaterIDBytes(id)
+		reply := transport.exchange(t, addr, frame(RepeaterLogin, repeaterID))
+		if !bytes.HasPrefix(reply, RepeaterACK) || len(reply) != 10 {
+			t.Fatalf("expected RPTACK with nonce, got %q", reply)
+		}
+		key := sha256.Sum256(frame(reply[6:], []byte(password)))
+		return transport.exchange(t, addr, frame(RepeaterKey, repeaterID, key[:]))
+	}
+
+	// Repeater with the right password completes the handshake.
+	addr := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 62031}
+	if reply := login(addr, 1001, "passw0rd"); !bytes.Equal(reply, frame(RepeaterACK, masterID)) {
+		t.Fatalf("expected RPTACK after key, got %q", reply)
+	}
+	config := *testConfig
+	config.ID = 1001
+	if reply := transport.exchange(t, addr, buildConfigData(&config)); !bytes.Equal(reply, frame(RepeaterACK, masterID)) {
+		t.Fatalf("expected RPTACK after config, got %q", reply)
+	}
+	if reply := transport.exchange(t, addr, frame(RepeaterPing, RepeaterIDBytes(1001))); !bytes.Equal(reply, frame(MasterPong, RepeaterIDBytes(1001))) {
+		t.Fatalf("expected MSTPONG, got %q", reply)
+	}
+	peer := h.getPeer(1001)
+	if peer == nil || peer.Status != AuthDone || peer.Config == nil || peer.Config.ID != 1001 {
+		t.Fatalf("expected authenticated and configured peer, got %+v", peer)
+	}
+
+	// Repeater with the wrong password is refused.
+	addr = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 62031}
+	if reply := login(addr, 1002, "wrong"); !bytes.Equal(reply, frame(MasterNAK, masterID)) {
+		t.Fatalf("expected MSTNAK after wrong key, got %q", reply)
+	}
+	if peer := h.getPeer(1002); peer == nil || peer.Status != AuthNone {
+		t.Fatalf("expected refused peer in AuthNone, got %+v", peer)
+	}
+}
+
+func TestLoopedFramesDropped(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	var received []*dmr.Packet
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received = append(received, p)
+		return nil
+	})
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	if err := h.handle(peer.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), testConfig.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 0 {
+		t.Fatalf("expected looped frame to be dropped, got %d frames", len(received))
+	}
+	if peer.Counters.LoopedFrames != 1 {
+		t.Fatalf("expected 1 looped frame, got %d", peer.Counters.LoopedFrames)
+	}
+
+	if err := h.handle(peer.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), peer.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("expected frame from the peer to pass, got %d frames", len(received))
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	link := func(id uint32, relogin bool) (*Peer, *net.UDPConn) {
+		remote := testRemote(t)
+		t.Cleanup(func() { remote.Close() })
+		peer := &Peer{
+			ID:                    id,
+			Addr:                  remote.LocalAddr().(*net.UDPAddr),
+			AuthKey:               []byte("passw0rd"),
+			ReloginOnConfigChange: relogin,
+		}
+		if err := h.Link(peer); err != nil {
+			t.Fatal(err)
+		}
+		expectFrame(t, remote, RepeaterLogin, time.Second)
+		peer.Status = AuthDone
+		return peer, remote
+	}
+	peer, remote := link(1001, false)
+	relogin, reloginRemote := link(1002, true)
+
+	config := *testConfig
+	config.Callsign = "PD0ZRY"
+	config.TXFreq = 431300000
+	if err := h.UpdateConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	data := expectFrame(t, remote, RepeaterConfig, time.Second)
+	if c, _ := parseConfigData(data); c.Callsign != "PD0ZRY" || c.TXFreq != 431300000 {
+		t.Fatalf("expected updated config, got %+v", c)
+	}
+	if peer.Status != AuthDone {
+		t.Fatalf("expected peer to stay logged in, got %s", peer.Status.String())
+	}
+
+	expectFrame(t, reloginRemote, RepeaterClosing, time.Second)
+	expectFrame(t, reloginRemote, RepeaterLogin, time.Second)
+	if relogin.Status != AuthNone {
+		t.Fatalf("expected peer to log in again, got %s", relogin.Status.String())
+	}
+
+	other := config
+	other.ID++
+	if err := h.UpdateConfig(&other); err == nil {
+		t.Fatal("expected repeater ID change to fail")
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		h := testHomebrew(t)
+		done := make(chan error)
+		go func() { done <- h.ListenAndServe() }()
+
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := h.Close(); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		select {
+		case err := <-done:
+			if err != nil && err.Error() != "homebrew: repeater is closed" {
+				t.Fatalf("expected listener to stop cleanly, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("listener didn't stop after Close")
+		}
+		if err := h.Close(); err != nil {
+			t.Fatalf("expected second Close to be a no-op, got %v", err)
+		}
+		if h.Active() {
+			t.Fatal("expected repeater to be inactive after Close")
+		}
+	}
+}
+
+func TestDataCallType(t *testing.T) {
+	for _, callType := range []uint8{dmr.CallTypeGroup, dmr.CallTypePrivate} {
+		p, err := parseData(testData(t, testPacket(2001, 91, callType), 1001))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.CallType != callType {
+			t.Fatalf("expected call type %s, got %s", dmr.CallTypeName[callType], dmr.CallTypeName[p.CallType])
+		}
+	}
+
+	if _, err := buildData(testPacket(2001, 91, 2), 1001); err == nil {
+		t.Fatal("expected error for invalid call type")
+	}
+
+	h := testHomebrew(t)
+	defer h.Close()
+	testIncomingPeer(t, h, 1001)
+	if err := h.Send(testPacket(2001, 91, 0x80)); err == nil {
+		t.Fatal("expected error sending invalid call type")
+	}
+}
+
+func TestStrictRepeaterID(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, remote := testIncomingPeer(t, h, 1001)
+	login := append(append([]byte{}, RepeaterLogin...), RepeaterIDBytes(1002)...)
+
+	// Lenient by default, the login continues.
+	peer.Status = AuthNone
+	if err := h.handle(peer.Addr, login); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, remote, RepeaterACK, time.Second)
+	if peer.Status != AuthBegin {
+		t.Fatalf("expected login to continue, got status %s", peer.Status.String())
+	}
+
+	// Strict mode refuses the login.
+	h.StrictRepeaterID = true
+	peer.Status = AuthNone
+	if err := h.handle(peer.Addr, login); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, remote, MasterNAK, time.Second)
+	if peer.Status != AuthNone {
+		t.Fatalf("expected login to be refused, got status %s", peer.Status.String())
+	}
+
+	// And the key, even with the right token.
+	peer.Status = AuthBegin
+	peer.UpdateToken([]byte{0x01, 0x02, 0x03, 0x04})
+	key := append(append(append([]byte{}, RepeaterKey...), RepeaterIDBytes(1002)...), peer.Token...)
+	if err := h.handle(peer.Addr, key); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, remote, MasterNAK, time.Second)
+	if peer.Status != AuthNone {
+		t.Fatalf("expected key to be refused, got status %s", peer.Status.String())
+	}
+}
+
+func TestOutgoingKey(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	remote := testRemote(t)
+	defer remote.Close()
+
+	peer := &Peer{
+		ID:      1001,
+		Addr:    remote.LocalAddr().(*net.UDPAddr),
+		AuthKey: []byte("s3cret"),
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, remote, RepeaterLogin, time.Second)
+
+	nonce := []byte{0x01, 0x02, 0x03, 0x04}
+	if err := h.handle(peer.Addr, append(append([]byte{}, RepeaterACK...), nonce...)); err != nil {
+		t.Fatal(err)
+	}
+	key := expectFrame(t, remote, RepeaterKey, time.Second)
+	want := sha256.Sum256(append(nonce, "s3cret"...))
+	if len(key) != 40 || !bytes.Equal(key[4:8], RepeaterIDBytes(testConfig.ID)) || !bytes.Equal(key[8:], want[:]) {
+		t.Fatalf("expected RPTK with SHA256(nonce + password), got %x", key)
+	}
+}
+
+func TestIncomingAuthFunc(t *testing.T) {
+	transport := newTestTransport()
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- h.ListenAndServe() }()
+	defer func() {
+		h.Close()
+		<-done
+	}()
+
+	var (
+		masterID = RepeaterIDBytes(testConfig.ID)
+		frame    = func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	)
+	h.IncomingAuthFunc = func(id uint32) ([]byte, bool) {
+		if id == 1001 {
+			return []byte("s3cret"), true
+		}
+		return nil, false
+	}
+
+	// A known repeater logs in with its own password.
+	addr := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 62031}
+	reply := transport.exchange(t, addr, frame(RepeaterLogin, RepeaterIDBytes(1001)))
+	if !bytes.HasPrefix(reply, RepeaterACK) || len(reply) != 10 {
+		t.Fatalf("expected RPTACK with nonce, got %q", reply)
+	}
+	key := sha256.Sum256(frame(reply[6:], []byte("s3cret")))
+	if reply := transport.exchange(t, addr, frame(RepeaterKey, RepeaterIDBytes(1001), key[:])); !bytes.Equal(reply, frame(RepeaterACK, masterID)) {
+		t.Fatalf("expected RPTACK after key, got %q", reply)
+	}
+
+	// An unknown repeater is refused, and not linked.
+	addr = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 62031}
+	if reply := transport.exchange(t, addr, frame(RepeaterLogin, RepeaterIDBytes(1002))); !bytes.Equal(reply, frame(MasterNAK, masterID)) {
+		t.Fatalf("expected MSTNAK for unknown repeater, got %q", reply)
+	}
+	if peer := h.getPeer(1002); peer != nil {
+		t.Fatalf("expected unknown repeater not to be linked, got %+v", peer)
+	}
+}
+
+func TestDefaultPassword(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	if key, ok := h.incomingAuthKey(1001); !ok || string(key) != "passw0rd" {
+		t.Fatalf("expected fallback password, got %q", key)
+	}
+	h.DefaultPassword = []byte("s3cret")
+	if key, ok := h.incomingAuthKey(1001); !ok || string(key) != "s3cret" {
+		t.Fatalf("expected default password, got %q", key)
+	}
+}
+
+func TestListenAndServeContext(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, remote := testIncomingPeer(t, h, 1001)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- h.ListenAndServeContext(ctx) }()
+
+	// Traffic is still handled while the context is live.
+	ping := append(append([]byte{}, RepeaterPing...), RepeaterIDBytes(peer.ID)...)
+	if _, err := remote.WriteToUDP(ping, h.conn.(*net.UDPConn).LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, remote, MasterPong, time.Second)
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("expected %v, got %v", context.Canceled, err)
+		}
+	case <-time.After(h.Timeouts.ContextPollInterval * 2):
+		t.Fatal("expected ListenAndServeContext to return after cancel")
+	}
+
+	h.mutex.Lock()
+	stopped := h.stop == nil
+	h.mutex.Unlock()
+	if !stopped {
+		t.Fatal("expected keepalive to be stopped")
+	}
+	if !h.Active() {
+		t.Fatal("expected repeater to stay open until Close")
+	}
+}
+
+func TestSendQueue(t *testing.T) {
+	h := testHomebrew(t)
+	_, remote := testIncomingPeer(t, h, 1001)
+	h.MaxQueueDepth = 4
+
+	for i := 0; i < h.MaxQueueDepth; i++ {
+		p := testPacket(2001, 91, dmr.CallTypeGroup)
+		p.Sequence = uint8(i)
+		if err := h.Send(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.Send(testPacket(2001, 91, dmr.CallTypeGroup)); err == nil {
+		t.Fatal("expected error when the send queue is full")
+	}
+	if got := readFrames(t, remote, time.Millisecond*50); len(got) != 0 {
+		t.Fatalf("expected packets to wait in the queue, got %v", got)
+	}
+
+	// The queue is sent in order once serving.
+	done := make(chan error)
+	go func() { done <- h.ListenAndServe() }()
+	defer func() {
+		h.Close()
+		<-done
+	}()
+
+	got := readFrames(t, remote, h.Timeouts.SendInterval*time.Duration(h.MaxQueueDepth+4))
+	if len(got) != h.MaxQueueDepth {
+		t.Fatalf("expected %d packets, got %d", h.MaxQueueDepth, len(got))
+	}
+	for i, p := range got {
+		if p.Sequence != uint8(i) {
+			t.Fatalf("expected packet %d in order, got sequence %d", i, p.Sequence)
+		}
+	}
+}
+
+func TestPeerAddrV4Mapped(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer := &Peer{
+		ID:       1001,
+		Addr:     net.UDPAddrFromAddrPort(netip.MustParseAddrPort("[::ffff:1.2.3.4]:62031")),
+		AuthKey:  []byte("passw0rd"),
+		Incoming: true,
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, addr := range []*net.UDPAddr{
+		{IP: net.IPv4(1, 2, 3, 4).To4(), Port: 62031},
+		{IP: net.ParseIP("::ffff:1.2.3.4"), Port: 62031},
+		net.UDPAddrFromAddrPort(netip.MustParseAddrPort("1.2.3.4:62031")),
+	} {
+		if h.getPeerByAddr(addr) != peer {
+			t.Fatalf("expected peer for %s", addr)
+		}
+	}
+	if h.getPeerByAddr(&net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 62032}) != nil {
+		t.Fatal("expected no peer on another port")
+	}
+
+	if err := h.Unlink(peer.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Peer) != 0 {
+		t.Fatalf("expected peer map to be empty, got %v", h.Peer)
+	}
+}
+
+func TestPeerAddrV6(t *testing.T) {
+	config := *testConfig
+	config.Network = "udp6"
+	h, err := New(&config, &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("no IPv6: %v", err)
+	}
+	defer h.Close()
+
+	remote, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	// Link with an expanded representation of the same address.
+	addr := remote.LocalAddr().(*net.UDPAddr)
+	peer := &Peer{
+		ID:       1001,
+		Addr:     &net.UDPAddr{IP: net.ParseIP("0:0:0:0:0:0:0:1"), Port: addr.Port},
+		AuthKey:  []byte("passw0rd"),
+		Incoming: true,
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+	peer.Status = AuthDone
+	done := make(chan error, 1)
+	go func() { done <- h.ListenAndServe() }()
+
+	if _, err := remote.WriteTo(append(RepeaterPing, RepeaterIDBytes(peer.ID)...), h.conn.(*net.UDPConn).LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, remote, MasterPong, time.Second)
+	h.Close()
+	<-done
+}
+
+func TestNetworkUDP4(t *testing.T) {
+	config := *testConfig
+	config.Network = "udp4"
+	if h, err := New(&config, &net.UDPAddr{IP: net.IPv6loopback}); err == nil {
+		h.Close()
+		t.Fatal("expected udp4 to refuse an IPv6 address")
+	}
+}
+
+func TestReadBufferTruncation(t *testing.T) {
+	backend := logging.NewMemoryBackend(64)
+	log.SetBackend(logging.AddModuleLevel(backend))
+	defer log.SetBackend(logging.AddModuleLevel(logging.NewLogBackend(os.Stderr, "", stdlog.LstdFlags)))
+
+	h := testHomebrew(t)
+	h.ReadBufferSize = 64
+
+	peer, remote := testIncomingPeer(t, h, 1001)
+	done := make(chan error, 1)
+	go func() { done <- h.ListenAndServe() }()
+	defer h.Close()
+
+	// An oversized frame is truncated, but the listener keeps serving.
+	oversized := append(append([]byte{}, RepeaterPing...), make([]byte, 100)...)
+	for _, frame := range [][]byte{oversized, append(RepeaterPing, peer.id...)} {
+		if _, err := remote.WriteTo(frame, h.conn.(*net.UDPConn).LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectFrame(t, remote, MasterPong, time.Second)
+	h.Close()
+	<-done
+
+	var found bool
+	for n := backend.Head(); n != nil; n = n.Next() {
+		if n.Record.Level == logging.WARNING && strings.Contains(n.Record.Message(), "64 byte read buffer") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected a truncation warning")
+	}
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	var configs = []RepeaterConfiguration{
+		{
+			Callsign:    "PD0MZ",
+			ID:          2042214,
+			RXFreq:      438800000,
+			TXFreq:      431200000,
+			TXPower:     5,
+			ColorCode:   1,
+			Slots:       4,
+			Latitude:    52.3,
+			Longitude:   4.9,
+			Height:      12,
+			Location:    "Zoetermeer",
+			Description: "go-dmr",
+			URL:         "https://github.com/polkabana/go-dmr",
+			SoftwareID:  "go-dmr",
+			PackageID:   "test",
+		},
+		{
+			// Every field at its full width, with leading spaces
+			Callsign:    "ABCDEFGH",
+			ID:          0xffffffff,
+			RXFreq:      999999999,
+			TXFreq:      100000000,
+			TXPower:     99,
+			ColorCode:   15,
+			Slots:       3,
+			Latitude:    -33.8688,
+			Longitude:   -123.456,
+			Height:      999,
+			Location:    "  Location 20 chars",
+			Description: " Description 19 ch",
+			URL:         " " + strings.Repeat("u", 123),
+			SoftwareID:  strings.Repeat("s", 40),
+			PackageID:   "  " + strings.Repeat("p", 38),
+		},
+	}
+	for _, config := range configs {
+		data := buildConfigData(&config)
+		c, err := parseConfigData(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *c != config {
+			t.Errorf("expected\n%+v, got\n%+v", config, *c)
+		}
+		if again := buildConfigData(c); !bytes.Equal(again, data) {
+			t.Errorf("expected identical configuration data\n%q, got\n%q", data, again)
+		}
+	}
+
+	// Fields too wide are truncated, and parse back as such.
+	config := *testConfig
+	config.Location = strings.Repeat("l", 30)
+	config.Height = 1234
+	c, err := parseConfigData(buildConfigData(&config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Location != strings.Repeat("l", 20) || c.Height != 999 {
+		t.Fatalf("expected truncated location and clamped height, got %q and %d", c.Location, c.Height)
+	}
+}
+
+func TestDescribePacket(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.IDResolver = func(id uint32) (string, string, bool) {
+		return "PD0MZ", "Wijnand", id == 2042214
+	}
+
+	p := testPacket(2042214, 91, dmr.CallTypeGroup)
+	p.Timeslot = 1
+	p.DataType = dmr.VoiceBurstA
+	if s := h.DescribePacket(p); s != fmt.Sprintf("PD0MZ (2042214) → TG 91 TS2 group voice (burst A) stream %#08x", p.StreamID) {
+		t.Fatalf("unexpected %q", s)
+	}
+
+	p = testPacket(2001, 2042214, dmr.CallTypePrivate)
+	p.DataType = 0x42
+	if s := h.DescribePacket(p); s != fmt.Sprintf("2001 → PD0MZ (2042214) TS1 private unknown data type 66 stream %#08x", p.StreamID) {
+		t.Fatalf("unexpected %q", s)
+	}
+}
+
+func TestMarshalHomebrew(t *testing.T) {
+	var payload = make([]byte, 33)
+	for i := range payload {
+		payload[i] = uint8(i)
+	}
+	p := &dmr.Packet{
+		Timeslot: 1,
+		Sequence: 7,
+		SrcID:    2042214,
+		DstID:    4000,
+		StreamID: 0x12345678,
+		DataType: dmr.VoiceBurstC,
+		CallType: dmr.CallTypePrivate,
+		BER:      3,
+		RSSI:     47,
+	}
+	p.SetData(payload)
+
+	data, err := MarshalHomebrew(p, 204201)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte("DMRD\x07\x1f\x29\x66\x00\x0f\xa0\x00\x03\x1d\xa9\xc2\x12\x34\x56\x78"), payload...)
+	want = append(want, 3, 47)
+	if !bytes.Equal(data, want) {
+		t.Fatalf("expected\n%x, got\n%x", want, data)
+	}
+	if built, _ := buildData(p, 204201); !bytes.Equal(built, data) {
+		t.Fatalf("expected buildData to match, got %x", built)
+	}
+
+	parsed, err := UnmarshalHomebrew(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RepeaterID = 204201
+	if !reflect.DeepEqual(parsed, p) {
+		t.Fatalf("expected %+v, got %+v", p, parsed)
+	}
+
+	// Data sync bursts carry their data type.
+	p.DataType = dmr.CSBK
+	p.CallType = dmr.CallTypeGroup
+	p.Timeslot = 0
+	if data, err = MarshalHomebrew(p, 204201); err != nil || data[15] != 0x23 {
+		t.Fatalf("unexpected frame type %#02x, %v", data[15], err)
+	}
+
+	p.DataType = dmr.IPSCSync
+	if _, err := MarshalHomebrew(p, 204201); err == nil {
+		t.Fatal("expected error for data type without Homebrew encoding")
+	}
+	p.DataType = dmr.VoiceBurstA
+	p.Data = make([]byte, 34)
+	if _, err := MarshalHomebrew(p, 204201); err == nil {
+		t.Fatal("expected error for too much DMR data")
+	}
+	if _, err := UnmarshalHomebrew(append([]byte("RPTL"), want[4:]...)); err == nil {
+		t.Fatal("expected error for a frame other than DMRD")
+	}
+	if _, err := UnmarshalHomebrew(want[:54]); !errors.Is(err, ErrShortFrame) {
+		t.Fatalf("expected ErrShortFrame, got %v", err)
+	}
+}
+
+func TestTimeoutsPerInstance(t *testing.T) {
+	var remotes []*net.UDPConn
+	for _, interval := range []time.Duration{time.Second, time.Minute} {
+		h := testHomebrew(t)
+		defer h.Close()
+		h.Timeouts.PingInterval = interval
+
+		remote := testRemote(t)
+		defer remote.Close()
+		remotes = append(remotes, remote)
+
+		peer := &Peer{
+			ID:      1001,
+			Addr:    remote.LocalAddr().(*net.UDPAddr),
+			AuthKey: []byte("passw0rd"),
+		}
+		if err := h.Link(peer); err != nil {
+			t.Fatal(err)
+		}
+		expectFrame(t, remote, RepeaterLogin, time.Second)
+		peer.Status = AuthDone
+		peer.Last.PingSent = time.Now().Add(-10 * time.Second)
+		peer.Last.PongReceived = peer.Last.PingSent
+		h.housekeeping(time.Now())
+	}
+
+	// Only the instance with the short interval pings.
+	expectFrame(t, remotes[0], RepeaterPing, time.Second)
+	remotes[1].SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := remotes[1].Read(make([]byte, 512)); err == nil {
+		t.Fatalf("expected no ping with the long interval, got %d bytes", n)
+	}
+
+	// The package level defaults only seed new instances.
+	h := testHomebrew(t)
+	defer h.Close()
+	if h.Timeouts.PingInterval != PingInterval || h.Timeouts.SendInterval != SendInterval ||
+		h.Timeouts.StreamTimeout != StreamTimeout || h.Timeouts.ControlRetryInterval != ControlRetryInterval {
+		t.Fatalf("unexpected default timeouts %+v", h.Timeouts)
+	}
+
+	h.Timeouts.StreamTimeout = time.Minute
+	peer, _ := testIncomingPeer(t, h, 1001)
+	now := time.Now()
+	if !h.acceptStream(testPacket(2001, 91, dmr.CallTypeGroup), peer, now) {
+		t.Fatal("expected stream to be accepted")
+	}
+	if h.acceptStream(testPacket(2002, 91, dmr.CallTypeGroup), peer, now.Add(StreamTimeout*2)) {
+		t.Fatal("expected the stream to stay active within the StreamTimeout of the instance")
+	}
+}
+
+func TestDropSilentIncoming(t *testing.T) {
+	h := testHomebrew(t)
+	peer, remote := testIncomingPeer(t, h, 1001)
+	var now = time.Now()
+	peer.Last.PingReceived = now
+
+	var reasons []error
+	h.OnPeerDisconnected = func(p *Peer, reason error) {
+		if p != peer {
+			t.Errorf("expected peer %d, got %d", peer.ID, p.ID)
+		}
+		reasons = append(reasons, reason)
+	}
+
+	// Kept by default
+	h.housekeeping(now.Add(h.Timeouts.PingTimeout + time.Second))
+	if _, ok := h.PeerID[peer.ID]; !ok {
+		t.Fatal("expected silent peer to be kept without DropSilentIncoming")
+	}
+
+	h.DropSilentIncoming = true
+	h.housekeeping(now.Add(h.Timeouts.PingTimeout - time.Second))
+	if _, ok := h.PeerID[peer.ID]; !ok {
+		t.Fatal("expected peer pinging within PingTimeout to be kept")
+	}
+
+	h.housekeeping(now.Add(h.Timeouts.PingTimeout + time.Second))
+	expectFrame(t, remote, MasterClosing, time.Second)
+	if _, ok := h.PeerID[peer.ID]; ok {
+		t.Fatal("expected silent peer to be unlinked")
+	}
+	if peer.Status != AuthNone {
+		t.Fatalf("expected status %d, got %d", AuthNone, peer.Status)
+	}
+	if len(reasons) != 1 || reasons[0] != ErrPingTimeout {
+		t.Fatalf("expected one disconnect with %v, got %v", ErrPingTimeout, reasons)
+	}
+}
+
+func TestEnforceRepeaterID(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.EnforceRepeaterID = true
+
+	var received []*dmr.Packet
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received = append(received, p)
+		return nil
+	})
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	// Frame claiming to come from another repeater
+	if err := h.handle(peer.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), 1002)); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 0 {
+		t.Fatalf("expected spoofed frame to be dropped, got %d frames", len(received))
+	}
+	if peer.Counters.SpoofedFrames != 1 {
+		t.Fatalf("expected 1 spoofed frame, got %d", peer.Counters.SpoofedFrames)
+	}
+
+	if err := h.handle(peer.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), peer.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].RepeaterID != peer.ID {
+		t.Fatalf("expected frame with the peer's repeater ID to pass, got %d frames", len(received))
+	}
+
+	// Masters forward frames of all their repeaters
+	remote := testRemote(t)
+	defer remote.Close()
+	master := &Peer{
+		ID:      2001,
+		Addr:    remote.LocalAddr().(*net.UDPAddr),
+		AuthKey: []byte("passw0rd"),
+	}
+	if err := h.Link(master); err != nil {
+		t.Fatal(err)
+	}
+	master.Status = AuthDone
+	if err := h.handlePacket(testPacket(2002, 91, dmr.CallTypeGroup), master); err != nil {
+		t.Fatal(err)
+	}
+	if master.Counters.SpoofedFrames != 0 {
+		t.Fatal("expected frames of outgoing peers to pass")
+	}
+}
+
+func TestSendConcurrent(t *testing.T) {
+	h := testHomebrew(t)
+	_, remote := testIncomingPeer(t, h, 1001)
+	h.Timeouts.SendInterval = time.Millisecond
+
+	const (
+		senders = 16
+		packets = 10
+	)
+
+	// Saturated queue
+	h.MaxQueueDepth = senders * packets / 2
+	var (
+		wg   sync.WaitGroup
+		full = make(chan error, senders*packets)
+	)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(src uint32) {
+			defer wg.Done()
+			for j := 0; j < packets; j++ {
+				if err := h.Send(testPacket(src, 90, dmr.CallTypeGroup)); err != nil {
+					full <- err
+				}
+			}
+		}(uint32(2001 + i))
+	}
+	wg.Wait()
+	close(full)
+	if len(full) != senders*packets-h.MaxQueueDepth {
+		t.Fatalf("expected %d rejected packets, got %d", senders*packets-h.MaxQueueDepth, len(full))
+	}
+	for err := range full {
+		if !errors.Is(err, ErrSendQueueFull) {
+			t.Fatalf("expected %v, got %v", ErrSendQueueFull, err)
+		}
+	}
+
+	// Each sender's packets arrive in order while serving, and while frames
+	// are received, after the ones queued above
+	done := make(chan error)
+	go func() { done <- h.ListenAndServe() }()
+	defer func() {
+		h.Close()
+		<-done
+	}()
+
+	other, _ := testIncomingPeer(t, h, 1002)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(src uint32) {
+			defer wg.Done()
+			for j := 0; j < packets; j++ {
+				p := testPacket(src, 91, dmr.CallTypeGroup)
+				p.Sequence = uint8(j)
+				err := h.Send(p)
+				for errors.Is(err, ErrSendQueueFull) {
+					time.Sleep(h.Timeouts.SendInterval)
+					err = h.Send(p)
+				}
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}(uint32(2001 + i))
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < packets; j++ {
+			h.handlePacket(testPacket(3001, 92, dmr.CallTypeGroup), other)
+		}
+	}()
+	wg.Wait()
+
+	var (
+		got  []*dmr.Packet
+		next = make(map[uint32]uint8)
+	)
+	for len(got) < h.MaxQueueDepth+senders*packets {
+		frames := readFrames(t, remote, time.Second)
+		if len(frames) == 0 {
+			break
+		}
+		got = append(got, frames...)
+	}
+	for _, p := range got {
+		if p.SrcID == 3001 || p.DstID != 91 {
+			continue
+		}
+		if p.Sequence != next[p.SrcID] {
+			t.Fatalf("expected packet %d from %d, got %d", next[p.SrcID], p.SrcID, p.Sequence)
+		}
+		next[p.SrcID]++
+	}
+	for i := 0; i < senders; i++ {
+		if next[uint32(2001+i)] != packets {
+			t.Fatalf("expected %d packets from %d, got %d", packets, 2001+i, next[uint32(2001+i)])
+		}
+	}
+}
+
+func TestSendSyncStalled(t *testing.T) {
+	var stalled = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 62031}
+	transport := &stallTransport{testTransport: newTestTransport(), stalled: stalled, release: make(chan struct{})}
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.EnforceRepeaterID = false
+	defer h.Close()
+	var release sync.Once
+	defer release.Do(func() { close(transport.release) })
+
+	slow := &Peer{ID: 1001, Addr: stalled, AuthKey: []byte("passw0rd"), Incoming: true}
+	other := &Peer{ID: 1002, Addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 62032}, AuthKey: []byte("passw0rd"), Incoming: true}
+	for _, peer := range []*Peer{slow, other} {
+		if err := h.Link(peer); err != nil {
+			t.Fatal(err)
+		}
+		peer.setStatus(AuthDone)
+	}
+
+	sent := make(chan error)
+	go func() { sent <- h.SendSync(testPacket(2001, 91, dmr.CallTypeGroup)) }()
+	time.Sleep(10 * time.Millisecond)
+
+	// Received traffic is handled while the send waits on the slow peer.
+	received := make(chan *dmr.Packet, 1)
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received <- p
+		return nil
+	})
+	go h.handlePacket(testPacket(3001, 92, dmr.CallTypeGroup), other)
+	select {
+	case p := <-received:
+		if p.SrcID != 3001 {
+			t.Fatalf("expected frame from 3001, got %d", p.SrcID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the received frame to be handled during the send")
+	}
+
+	release.Do(func() { close(transport.release) })
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseBestEffort(t *testing.T) {
+	var failing = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 62031}
+	transport := &failTransport{testTransport: newTestTransport(), failing: failing}
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- h.ListenAndServe() }()
+	for serving := false; !serving; time.Sleep(time.Millisecond) {
+		h.mutex.Lock()
+		serving = h.stop != nil
+		h.mutex.Unlock()
+	}
+
+	var addrs = map[string]bool{}
+	for i := 0; i < 4; i++ {
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 62031 + i}
+		peer := &Peer{ID: uint32(1001 + i), Addr: addr, AuthKey: []byte("passw0rd"), Incoming: true}
+		if err := h.Link(peer); err != nil {
+			t.Fatal(err)
+		}
+		peer.Status = AuthDone
+		if i > 0 {
+			addrs[addr.String()] = true
+		}
+	}
+
+	err = h.Close()
+	if err == nil || !strings.Contains(err.Error(), "peer 1001 close failed: write failed") {
+		t.Fatalf("expected the failed peer reported, got %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("expected ListenAndServe to return nil after Close, got %v", err)
+	}
+	h.workers.Wait()
+
+	for len(addrs) > 0 {
+		select {
+		case d := <-transport.out:
+			if !bytes.HasPrefix(d.data, MasterClosing) {
+				t.Fatalf("expected closing frame, got %q", d.data)
+			}
+			delete(addrs, d.addr.String())
+		default:
+			t.Fatalf("expected closing frames for %v", addrs)
+		}
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("expected second Close to succeed, got %v", err)
+	}
+}
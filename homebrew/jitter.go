@@ -0,0 +1,155 @@
+package homebrew
+
+import (
+	"sort"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+// jitterBuffer holds the frames of a stream, to forward them in sequence order
+// on a steady cadence.
+type jitterBuffer struct {
+	streamID uint32
+	next     uint8 // Next sequence number to release
+	released bool  // Frames of the stream were released
+	filled   bool  // The buffer was filled, frames are released on the cadence
+	frames   []*dmr.Packet
+	last     time.Time     // Last frame added
+	done     bool          // Terminator received or stream replaced, buffer flushed
+	stop     chan struct{} // Closed when done, stops releaseJitter
+}
+
+// add inserts a frame by its sequence number relative to the next frame to
+// release, so the order survives the wrap around of the sequence number. It
+// returns false for a frame arriving after later frames were released.
+func (j *jitterBuffer) add(p *dmr.Packet) bool {
+	var distance = int8(p.Sequence - j.next)
+	if distance < 0 && j.released {
+		return false
+	}
+	i := sort.Search(len(j.frames), func(i int) bool {
+		return int8(j.frames[i].Sequence-j.next) > distance
+	})
+	j.frames = append(j.frames, nil)
+	copy(j.frames[i+1:], j.frames[i:])
+	j.frames[i] = p
+	return true
+}
+
+// pop removes the first frame in sequence order.
+func (j *jitterBuffer) pop() *dmr.Packet {
+	if len(j.frames) == 0 {
+		return nil
+	}
+	p := j.frames[0]
+	j.frames = j.frames[1:]
+	j.next = p.Sequence + 1
+	j.released = true
+	return p
+}
+
+// finish marks the buffer done, which stops releaseJitter.
+func (j *jitterBuffer) finish() {
+	if !j.done {
+		j.done = true
+		close(j.stop)
+	}
+}
+
+// dejitter buffers the frame when JitterBuffer is enabled, otherwise it's
+// forwarded straight away. Once JitterBuffer frames are held, they're released
+// one per voice burst, or immediately when more frames arrive than the buffer
+// holds; a terminator flushes the buffer. Must be called with rxtx held.
+func (h *Homebrew) dejitter(p *dmr.Packet, peer *Peer) error {
+	if h.JitterBuffer <= 0 {
+		return h.forward(p, peer)
+	}
+
+	s := &peer.slot[p.Timeslot&0x01]
+	if s.jitter == nil || s.jitter.streamID != p.StreamID {
+		if s.jitter != nil {
+			// Replaced without a terminator
+			if err := h.flushJitter(s.jitter, peer); err != nil {
+				return err
+			}
+		}
+
+		h.mutex.Lock()
+		if h.closed {
+			h.mutex.Unlock()
+			return nil
+		}
+		s.jitter = &jitterBuffer{streamID: p.StreamID, next: p.Sequence, stop: make(chan struct{})}
+		h.workers.Add(1)
+		go h.releaseJitter(peer, s.jitter, h.jitterInterval)
+		h.mutex.Unlock()
+	}
+
+	j := s.jitter
+	j.last = time.Now()
+	if !j.add(p) {
+		h.logger.Debugf("peer %d@%s stream %#08x frame %d arrived too late (dropped)\n", peer.ID, peer.Addr, p.StreamID, p.Sequence)
+		return nil
+	}
+	if len(j.frames) >= h.JitterBuffer {
+		j.filled = true
+	}
+
+	if p.DataType == dmr.TerminatorWithLC {
+		return h.flushJitter(j, peer)
+	}
+	for release := len(j.frames) - h.JitterBuffer; release > 0; release-- {
+		if err := h.forward(j.pop(), peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushJitter forwards all frames held by the buffer, and stops its release.
+func (h *Homebrew) flushJitter(j *jitterBuffer, peer *Peer) error {
+	j.finish()
+	for p := j.pop(); p != nil; p = j.pop() {
+		if err := h.forward(p, peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseJitter forwards a frame from the jitter buffer every interval, once
+// the buffer was filled, until the buffer is flushed, the stream is lost or
+// Close is called. A stream stalling before the buffer is filled is released
+// as well.
+func (h *Homebrew) releaseJitter(peer *Peer, j *jitterBuffer, interval time.Duration) {
+	defer h.workers.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var stall = interval * time.Duration(h.JitterBuffer)
+	for {
+		select {
+		case <-ticker.C:
+		case <-j.stop:
+			return
+		case <-h.done:
+			return
+		}
+
+		h.rxtx.Lock()
+		var silence = time.Since(j.last)
+		if len(j.frames) == 0 && silence > h.Timeouts.StreamTimeout {
+			h.rxtx.Unlock()
+			return
+		}
+		if j.filled || silence > stall {
+			if p := j.pop(); p != nil {
+				if err := h.forward(p, peer); err != nil {
+					h.logger.Errorf("peer %d@%s stream %#08x forward failed: %v\n", peer.ID, peer.Addr, p.StreamID, err)
+				}
+			}
+		}
+		h.rxtx.Unlock()
+	}
+}
@@ -0,0 +1,152 @@
+package homebrew
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestJitterBuffer(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.jitterInterval = time.Hour
+	h.JitterBuffer = 3
+
+	var received []uint8
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received = append(received, p.Sequence)
+		return nil
+	})
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	var frames []*dmr.Packet
+	for _, seq := range []uint8{254, 0, 255, 1, 3, 2} {
+		p := testPacket(2001, 91, dmr.CallTypeGroup)
+		p.Sequence = seq
+		frames = append(frames, p)
+	}
+	for _, p := range frames[:3] {
+		h.handlePacket(p, peer)
+	}
+	if len(received) != 0 {
+		t.Fatalf("expected frames to be held, got %v", received)
+	}
+
+	// The buffer is bounded, a full buffer releases the first frame.
+	h.handlePacket(frames[3], peer)
+	if len(received) != 1 || received[0] != 254 {
+		t.Fatalf("expected frame 254 to be released, got %v", received)
+	}
+
+	for _, p := range frames[4:] {
+		h.handlePacket(p, peer)
+	}
+	terminator := testPacket(2001, 91, dmr.CallTypeGroup)
+	terminator.DataType = dmr.TerminatorWithLC
+	terminator.Sequence = 4
+	h.handlePacket(terminator, peer)
+
+	var expect = []uint8{254, 255, 0, 1, 2, 3, 4}
+	if !bytes.Equal(received, expect) {
+		t.Fatalf("expected frames %v, got %v", expect, received)
+	}
+}
+
+func TestJitterBufferCadence(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.jitterInterval = 50 * time.Millisecond
+	h.JitterBuffer = 3
+
+	var received = make(chan uint8, 5)
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received <- p.Sequence
+		return nil
+	})
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	// Frames are held until the buffer is filled.
+	for _, seq := range []uint8{1, 0} {
+		p := testPacket(2001, 91, dmr.CallTypeGroup)
+		p.Sequence = seq
+		h.handlePacket(p, peer)
+	}
+	select {
+	case seq := <-received:
+		t.Fatalf("expected frames to be held, got %d", seq)
+	case <-time.After(2 * h.jitterInterval):
+	}
+
+	// And then released in order, one per interval.
+	p := testPacket(2001, 91, dmr.CallTypeGroup)
+	p.Sequence = 2
+	h.handlePacket(p, peer)
+	var start time.Time
+	for i := uint8(0); i < 3; i++ {
+		select {
+		case seq := <-received:
+			if seq != i {
+				t.Fatalf("expected frame %d, got %d", i, seq)
+			}
+			if i == 0 {
+				start = time.Now()
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for frame %d", i)
+		}
+	}
+	// Measured from the first frame, a late tick may shorten one interval.
+	if elapsed := time.Since(start); elapsed < h.jitterInterval {
+		t.Fatalf("expected frames released one per %s, got 3 in %s", h.jitterInterval, elapsed)
+	}
+}
+
+func TestJitterBufferStop(t *testing.T) {
+	h := testHomebrew(t)
+	h.jitterInterval = time.Hour
+	h.JitterBuffer = 3
+
+	var received []uint32
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received = append(received, p.StreamID)
+		return nil
+	})
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	// A stream replaced without a terminator is flushed, and its release
+	// stops.
+	h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), peer)
+	first := peer.slot[0].jitter
+	now := time.Now()
+	first.last = now.Add(-2 * h.Timeouts.StreamTimeout)
+	peer.slot[0].last = first.last
+	h.handlePacket(testPacket(2002, 91, dmr.CallTypeGroup), peer)
+	if len(received) != 1 || received[0] != 2001<<8|91 {
+		t.Fatalf("expected the first stream to be flushed, got %v", received)
+	}
+	select {
+	case <-first.stop:
+	default:
+		t.Fatal("expected the release of the first stream to stop")
+	}
+
+	// Close stops the release of the active stream.
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var stopped = make(chan struct{})
+	go func() {
+		h.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected the jitter buffer release to stop on Close")
+	}
+}
@@ -0,0 +1,210 @@
+package homebrew
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+// lastHeardSize is the default LastHeardSize.
+const lastHeardSize = 100
+
+// IDResolver looks up the callsign and name for a DMR ID, for example in an
+// external DMR ID database.
+type IDResolver func(dmrID uint32) (callsign, name string, ok bool)
+
+// HeardEntry is a transmission in the last heard list.
+type HeardEntry struct {
+	SrcID    uint32
+	DstID    uint32
+	CallType uint8
+	Timeslot uint8
+	StreamID uint32
+	PeerID   uint32
+	Callsign string // Resolved source callsign, if any
+	Name     string // Resolved source name, if any
+	Start    time.Time
+	Last     time.Time
+
+	// Duration from Start to the terminator, or to Last while active
+	Duration time.Duration
+
+	// Best effort vocoder guess, see voice.Classify
+	Codec voice.Codec
+
+	voiceBursts   int  // Voice bursts classified
+	invalidBursts int  // Voice bursts that don't look like AMBE+2
+	terminated    bool // Ended with a terminator
+}
+
+// classify updates the codec guess of the stream with a voice burst. The
+// stream is flagged CodecInvalid when most of its bursts don't look like
+// AMBE+2.
+func (e *HeardEntry) classify(p *dmr.Packet) {
+	if p.DataType < dmr.VoiceBurstA || p.DataType > dmr.VoiceBurstF {
+		return
+	}
+
+	e.voiceBursts++
+	if voice.Classify(p.Data) != voice.CodecAMBE2 {
+		e.invalidBursts++
+	}
+	if e.invalidBursts*2 > e.voiceBursts {
+		e.Codec = voice.CodecInvalid
+	} else {
+		e.Codec = voice.CodecAMBE2
+	}
+}
+
+// resolved is a cached IDResolver result.
+type resolved struct {
+	callsign, name string
+	ok             bool
+	expires        time.Time
+}
+
+// LastHeard returns up to n of the most recent transmissions, most recent
+// first.
+func (h *Homebrew) LastHeard(n int) []HeardEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if n > len(h.heard) {
+		n = len(h.heard)
+	}
+	if n < 0 {
+		n = 0
+	}
+	var entries = make([]HeardEntry, n)
+	for i := range entries {
+		entries[i] = *h.heard[i]
+	}
+	return entries
+}
+
+// updateLastHeard adds an entry for each new stream, and updates the last
+// seen time of the active one.
+func (h *Homebrew) updateLastHeard(p *dmr.Packet, peer *Peer, now time.Time) {
+	s := &peer.slot[p.Timeslot&0x01]
+	if s.heard != nil && s.heard.StreamID == p.StreamID {
+		h.mutex.Lock()
+		h.updateTGStats(p, false, now.Sub(s.heard.Last), now)
+		s.heard.Last = now
+		s.heard.Duration = now.Sub(s.heard.Start)
+		s.heard.terminated = p.DataType == dmr.TerminatorWithLC
+		s.heard.classify(p)
+		h.mutex.Unlock()
+		return
+	}
+
+	// A keyup split by a reconnect continues its last heard entry
+	h.mutex.Lock()
+	e := h.correlateStream(p, now)
+	if e != nil {
+		h.logger.Debugf("peer %d@%s stream %#08x continues stream %#08x from %d\n", peer.ID, peer.Addr, p.StreamID, e.StreamID, p.SrcID)
+		h.updateTGStats(p, false, now.Sub(e.Last), now)
+		e.StreamID = p.StreamID
+		e.PeerID = peer.ID
+		e.Last = now
+		e.Duration = now.Sub(e.Start)
+		e.terminated = p.DataType == dmr.TerminatorWithLC
+		e.classify(p)
+		s.heard = e
+	}
+	h.mutex.Unlock()
+	if e != nil {
+		return
+	}
+
+	s.heard = &HeardEntry{
+		SrcID:    p.SrcID,
+		DstID:    p.DstID,
+		CallType: p.CallType,
+		Timeslot: p.Timeslot,
+		StreamID: p.StreamID,
+		PeerID:   peer.ID,
+		Start:    now,
+		Last:     now,
+
+		terminated: p.DataType == dmr.TerminatorWithLC,
+	}
+	s.heard.Callsign, s.heard.Name, _ = h.resolveID(p.SrcID, now)
+	s.heard.classify(p)
+	h.logger.Debugf("peer %d@%s stream %#08x from %s to %s%d on TS%d\n", peer.ID, peer.Addr, p.StreamID,
+		h.displayID(p.SrcID), dmr.CallTypeShortName[p.CallType], p.DstID, p.Timeslot+1)
+
+	h.mutex.Lock()
+	h.updateTGStats(p, true, 0, now)
+	h.heard = append([]*HeardEntry{s.heard}, h.heard...)
+	var size = h.LastHeardSize
+	if size < 0 {
+		size = 0
+	}
+	if len(h.heard) > size {
+		h.heard = h.heard[:size]
+	}
+	h.mutex.Unlock()
+}
+
+// correlateStream finds the last heard entry of a transmission that p, although
+// in a new stream, continues: a keyup split in two by a reconnect of the peer
+// carrying it. The entry must be from the same source to the same destination
+// on the same timeslot, not terminated and last heard within
+// StreamCorrelationWindow. Must be called with the mutex held.
+func (h *Homebrew) correlateStream(p *dmr.Packet, now time.Time) *HeardEntry {
+	if h.StreamCorrelationWindow <= 0 {
+		return nil
+	}
+	for _, e := range h.heard {
+		if now.Sub(e.Last) > h.StreamCorrelationWindow {
+			continue
+		}
+		if e.SrcID == p.SrcID && e.DstID == p.DstID && e.CallType == p.CallType && e.Timeslot == p.Timeslot && !e.terminated {
+			return e
+		}
+	}
+	return nil
+}
+
+// resolveID looks up a DMR ID with the IDResolver, if any. Results are cached
+// for Timeouts.ResolverCacheTimeout.
+func (h *Homebrew) resolveID(id uint32, now time.Time) (callsign, name string, ok bool) {
+	if h.IDResolver == nil {
+		return "", "", false
+	}
+
+	h.mutex.Lock()
+	r, cached := h.resolved[id]
+	h.mutex.Unlock()
+	if !cached || now.After(r.expires) {
+		r.callsign, r.name, r.ok = h.IDResolver(id)
+		r.expires = now.Add(h.Timeouts.ResolverCacheTimeout)
+
+		h.mutex.Lock()
+		h.resolved[id] = r
+		h.mutex.Unlock()
+	}
+	return r.callsign, r.name, r.ok
+}
+
+// expireResolved removes the expired IDResolver results from the cache.
+func (h *Homebrew) expireResolved(now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for id, r := range h.resolved {
+		if now.After(r.expires) {
+			delete(h.resolved, id)
+		}
+	}
+}
+
+// displayID formats a DMR ID for logging, with the callsign if it resolves.
+func (h *Homebrew) displayID(id uint32) string {
+	if callsign, _, ok := h.resolveID(id, time.Now()); ok {
+		return fmt.Sprintf("%s (%d)", callsign, id)
+	}
+	return fmt.Sprintf("%d", id)
+}
@@ -0,0 +1,229 @@
+package homebrew
+
+import (
+	stdlog "log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+func TestIDResolverLastHeard(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	var lookups int
+	h.IDResolver = func(id uint32) (string, string, bool) {
+		lookups++
+		if id == 3101234 {
+			return "W1ABC", "Alice", true
+		}
+		return "", "", false
+	}
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	for i := 0; i < 3; i++ {
+		h.handlePacket(testPacket(3101234, 91, dmr.CallTypeGroup), peer)
+	}
+	terminator := testPacket(3101234, 91, dmr.CallTypeGroup)
+	terminator.DataType = dmr.TerminatorWithLC
+	h.handlePacket(terminator, peer)
+	h.handlePacket(testPacket(3101235, 91, dmr.CallTypeGroup), peer)
+	other := testPacket(3101234, 92, dmr.CallTypeGroup)
+	other.Timeslot = 1
+	h.handlePacket(other, peer)
+
+	heard := h.LastHeard(10)
+	if len(heard) != 3 {
+		t.Fatalf("expected 3 last heard entries, got %d", len(heard))
+	}
+	if e := heard[2]; e.SrcID != 3101234 || e.DstID != 91 || e.PeerID != peer.ID || e.Callsign != "W1ABC" || e.Name != "Alice" {
+		t.Fatalf("unexpected last heard entry %+v", e)
+	}
+	if e := heard[1]; e.SrcID != 3101235 || e.Callsign != "" {
+		t.Fatalf("unexpected last heard entry %+v", e)
+	}
+	if e := heard[0]; e.DstID != 92 || e.Callsign != "W1ABC" {
+		t.Fatalf("unexpected last heard entry %+v", e)
+	}
+	if e := heard[2]; e.Codec != voice.CodecInvalid {
+		t.Fatalf("expected all zero stream to be flagged %s, got %s", voice.CodecInvalid, e.Codec)
+	}
+	if lookups != 2 {
+		t.Fatalf("expected resolver results to be cached, got %d lookups", lookups)
+	}
+
+	// Expired results are removed from the cache
+	h.expireResolved(time.Now().Add(h.Timeouts.ResolverCacheTimeout + time.Second))
+	h.mutex.Lock()
+	cached := len(h.resolved)
+	h.mutex.Unlock()
+	if cached != 0 {
+		t.Fatalf("expected expired resolver results to be removed, got %d", cached)
+	}
+	if got := h.displayID(3101234); got != "W1ABC (3101234)" {
+		t.Fatalf("unexpected display ID %q", got)
+	}
+	if got := len(h.LastHeard(1)); got != 1 {
+		t.Fatalf("expected 1 last heard entry, got %d", got)
+	}
+}
+
+func TestPacketLogCallsigns(t *testing.T) {
+	backend := logging.NewMemoryBackend(64)
+	log.SetBackend(logging.AddModuleLevel(backend))
+	defer log.SetBackend(logging.AddModuleLevel(logging.NewLogBackend(os.Stderr, "", stdlog.LstdFlags)))
+
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	h.handlePacket(testPacket(3101234, 91, dmr.CallTypeGroup), peer)
+
+	h.IDResolver = func(id uint32) (string, string, bool) {
+		switch id {
+		case 3101234:
+			return "W1ABC", "Alice", true
+		case 3101235:
+			return "W1XYZ", "Bob", true
+		}
+		return "", "", false
+	}
+	p := testPacket(3101234, 91, dmr.CallTypeGroup)
+	p.Timeslot = 1
+	h.handlePacket(p, peer)
+	other, _ := testIncomingPeer(t, h, 1002)
+	h.handlePacket(testPacket(3101235, 3101234, dmr.CallTypePrivate), other)
+
+	var lines []string
+	for n := backend.Head(); n != nil; n = n.Next() {
+		if msg := n.Record.Message(); strings.HasPrefix(msg, "packet from") {
+			lines = append(lines, msg)
+		}
+	}
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 packet log lines, got %q", lines)
+	}
+	for i, want := range []string{
+		"packet from 3101234 to TG 91, TS1",
+		"packet from W1ABC (3101234) to TG 91, TS2",
+		"packet from W1XYZ (3101235) to W1ABC (3101234), TS1",
+	} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("expected log line starting with %q, got %q", want, lines[i])
+		}
+	}
+}
+
+func TestStreamCorrelation(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.StreamCorrelationWindow = time.Second * 5
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	first := testPacket(2001, 91, dmr.CallTypeGroup)
+	h.handlePacket(first, peer)
+	h.handlePacket(first, peer)
+
+	// The peer reconnects halfway through the keyup, the repeater starts a new
+	// stream for the rest of it.
+	if err := h.Unlink(peer.ID); err != nil {
+		t.Fatal(err)
+	}
+	peer, _ = testIncomingPeer(t, h, 1001)
+	second := testPacket(2001, 91, dmr.CallTypeGroup)
+	second.StreamID++
+	h.handlePacket(second, peer)
+	terminator := *second
+	terminator.DataType = dmr.TerminatorWithLC
+	h.handlePacket(&terminator, peer)
+
+	if heard := h.LastHeard(10); len(heard) != 1 || heard[0].StreamID != second.StreamID {
+		t.Fatalf("expected the keyup to be heard once, got %+v", heard)
+	}
+	if stats := h.TalkgroupStats(); len(stats) != 1 || stats[0].Transmissions != 1 {
+		t.Fatalf("expected the keyup to be counted once, got %+v", stats)
+	}
+
+	// After a terminator, a new stream is a new keyup.
+	third := testPacket(2001, 91, dmr.CallTypeGroup)
+	third.StreamID += 2
+	h.handlePacket(third, peer)
+	if heard := h.LastHeard(10); len(heard) != 2 {
+		t.Fatalf("expected a new keyup after the terminator, got %+v", heard)
+	}
+
+	// Without correlation, a split keyup is counted twice.
+	h.StreamCorrelationWindow = 0
+	other, _ := testIncomingPeer(t, h, 1002)
+	fourth := testPacket(2001, 91, dmr.CallTypeGroup)
+	fourth.StreamID += 3
+	h.handlePacket(fourth, other)
+	if stats := h.TalkgroupStats(); stats[0].Transmissions != 3 {
+		t.Fatalf("expected 3 transmissions without correlation, got %+v", stats)
+	}
+}
+
+func TestLastHeardDuration(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.LastHeardSize = 2
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	b, err := voice.NewBuilder(2042214, 91, dmr.CallTypeGroup, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		start = time.Now()
+		now   = start
+	)
+	p, err := b.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.updateLastHeard(p, peer, now)
+	for i := 0; i < 18; i++ {
+		if p, err = b.Voice(make([]byte, dmr.VoiceBits)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(voice.BurstDuration)
+		h.updateLastHeard(p, peer, now)
+	}
+	if p, err = b.Terminator(); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(voice.BurstDuration)
+	h.updateLastHeard(p, peer, now)
+
+	heard := h.LastHeard(10)
+	if len(heard) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(heard))
+	}
+	e := heard[0]
+	if e.SrcID != 2042214 || e.DstID != 91 || e.CallType != dmr.CallTypeGroup || e.Timeslot != 1 || e.PeerID != peer.ID {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if !e.Start.Equal(start) || e.Duration != 19*voice.BurstDuration {
+		t.Fatalf("expected %s from %s, got %s from %s", 19*voice.BurstDuration, start, e.Duration, e.Start)
+	}
+
+	// The list is capped at LastHeardSize
+	for i := uint32(0); i < 3; i++ {
+		h.updateLastHeard(testPacket(2001+i, 91, dmr.CallTypeGroup), peer, now)
+	}
+	if heard := h.LastHeard(10); len(heard) != 2 || heard[0].SrcID != 2003 {
+		t.Fatalf("expected the 2 most recent entries, got %d", len(heard))
+	}
+
+	// None are kept with a negative size
+	h.LastHeardSize = -1
+	h.updateLastHeard(testPacket(2004, 91, dmr.CallTypeGroup), peer, now)
+	if heard := h.LastHeard(10); len(heard) != 0 {
+		t.Fatalf("expected no entries, got %d", len(heard))
+	}
+}
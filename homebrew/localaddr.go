@@ -0,0 +1,115 @@
+package homebrew
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// LocalAddr returns the address the socket is bound to, with the port picked
+// by the system when bound to port 0. It's nil for a Transport that isn't a
+// UDP socket.
+func (h *Homebrew) LocalAddr() *net.UDPAddr {
+	conn, ok := h.conn.(interface{ LocalAddr() net.Addr })
+	if !ok {
+		return nil
+	}
+	addr, _ := conn.LocalAddr().(*net.UDPAddr)
+	return addr
+}
+
+// peerFrame is a frame read from the socket of an outgoing peer, see readPeer.
+type peerFrame struct {
+	peer *Peer
+	data []byte
+}
+
+// dialPeer opens the socket of an outgoing peer with a LocalAddr: bound to that
+// address and connected to the peer, see usePeer.
+func (h *Homebrew) dialPeer(peer *Peer) (*net.UDPConn, error) {
+	var network = h.Config.Network
+	if network == "" {
+		network = "udp"
+	}
+	conn, err := net.DialUDP(network, peer.LocalAddr, peer.Addr)
+	if err != nil {
+		return nil, errors.New("homebrew: " + err.Error())
+	}
+	return conn, nil
+}
+
+// usePeer makes conn the socket of the peer. The frames read from it are
+// handled by ListenAndServe like those of the shared socket, until it's closed
+// by Unlink or Close. Must be called with the mutex held.
+func (h *Homebrew) usePeer(peer *Peer, conn *net.UDPConn) {
+	if peer.conn != nil {
+		peer.conn.Close()
+	}
+	peer.conn = conn
+	h.applyDSCP(conn)
+
+	go h.readPeer(peer, conn)
+}
+
+// readPeer reads the frames from the socket of an outgoing peer, and passes
+// them to ListenAndServe.
+func (h *Homebrew) readPeer(peer *Peer, conn *net.UDPConn) {
+	var size = h.ReadBufferSize
+	if size <= 0 {
+		size = 512
+	}
+	var data = make([]byte, size)
+
+	for {
+		n, err := conn.Read(data)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Such as the ICMP port unreachable of a master that's down
+			h.logger.Debugf("peer %d@%s read from %s failed: %v\n", peer.ID, peer.Addr, conn.LocalAddr(), err)
+			continue
+		}
+		if n == len(data) {
+			h.logger.Warningf("%s sent a frame filling the %d byte read buffer, it may be truncated\n", peer.Addr, n)
+		}
+		select {
+		case h.peerFrames <- peerFrame{peer: peer, data: append([]byte(nil), data[:n]...)}:
+			// Wake up ListenAndServe, waiting on the shared socket
+			h.conn.SetReadDeadline(time.Now())
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// handlePeerFrames handles the frames passed by readPeer, without waiting for
+// more.
+func (h *Homebrew) handlePeerFrames() {
+	for {
+		select {
+		case f := <-h.peerFrames:
+			if err := h.handle(f.peer.Addr, f.data); err != nil {
+				h.logger.Errorf("peer %d@%s: %v\n", f.peer.ID, f.peer.Addr, err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// writeTo writes the frame to the peer, through its own socket if it has a
+// LocalAddr.
+func (h *Homebrew) writeTo(b []byte, peer *Peer) (int, error) {
+	if peer.conn != nil {
+		return peer.conn.Write(b)
+	}
+	return h.conn.WriteTo(b, peer.Addr)
+}
+
+// closePeer closes the socket of an outgoing peer with a LocalAddr, if any.
+func (h *Homebrew) closePeer(peer *Peer) {
+	if peer.conn != nil {
+		peer.conn.Close()
+	}
+}
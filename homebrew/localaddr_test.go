@@ -0,0 +1,100 @@
+package homebrew
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPeerLocalAddr(t *testing.T) {
+	h := testHomebrew(t)
+	done := make(chan error)
+	go func() { done <- h.ListenAndServe() }()
+	defer func() {
+		h.Close()
+		<-done
+	}()
+
+	remote := testRemote(t)
+	defer remote.Close()
+
+	peer := &Peer{
+		ID:        1001,
+		Addr:      remote.LocalAddr().(*net.UDPAddr),
+		AuthKey:   []byte("passw0rd"),
+		LocalAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)},
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+	local := peer.conn.LocalAddr().(*net.UDPAddr)
+	if local.Port == h.conn.(*net.UDPConn).LocalAddr().(*net.UDPAddr).Port {
+		t.Fatalf("expected a socket of its own, got %s", local)
+	}
+
+	expect := func(prefix []byte) {
+		t.Helper()
+
+		var data = make([]byte, 512)
+		remote.SetReadDeadline(time.Now().Add(time.Second))
+		n, addr, err := remote.ReadFromUDP(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data[:n], prefix) || addr.String() != local.String() {
+			t.Fatalf("expected %q frame from %s, got %q from %s", prefix, local, data[:n], addr)
+		}
+	}
+	expect(RepeaterLogin)
+
+	// Replies to the bound address are read from the peer socket.
+	if _, err := remote.WriteToUDP(append(append([]byte{}, RepeaterACK...), 0x01, 0x02, 0x03, 0x04), local); err != nil {
+		t.Fatal(err)
+	}
+	expect(RepeaterKey)
+
+	// Unlink closes the socket.
+	if err := h.Unlink(peer.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := peer.conn.Write([]byte("RPTCL")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected closed socket, got %v", err)
+	}
+}
+
+func TestLocalAddr(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	addr := h.LocalAddr()
+	if addr == nil || addr.Port == 0 || !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("expected bound address with a port, got %v", addr)
+	}
+
+	// Reachable at the reported address
+	remote := testRemote(t)
+	defer remote.Close()
+	peer := &Peer{ID: 1001, Addr: remote.LocalAddr().(*net.UDPAddr), AuthKey: []byte("passw0rd")}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+	var data = make([]byte, 512)
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	_, from, err := remote.ReadFromUDP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from.Port != addr.Port {
+		t.Fatalf("expected login from port %d, got %d", addr.Port, from.Port)
+	}
+
+	other, err := NewWithTransport(testConfig, newTestTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := other.LocalAddr(); addr != nil {
+		t.Fatalf("expected no address for a test transport, got %v", addr)
+	}
+}
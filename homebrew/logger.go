@@ -0,0 +1,33 @@
+package homebrew
+
+import "github.com/op/go-logging"
+
+var log = logging.MustGetLogger("dmr/homebrew")
+
+// Logger receives the log messages of a repeater. The go-logging logger of
+// the package is used by default, see SetLogger to route the messages into
+// another logging library.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// SetLogger replaces the logger, nil restores the default. It's to be called
+// before ListenAndServe.
+func (h *Homebrew) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = log
+	}
+	h.logger = logger
+}
+
+// debugEnabled checks whether debug messages are logged, when the logger can
+// tell, so they aren't formatted in vain.
+func (h *Homebrew) debugEnabled() bool {
+	if l, ok := h.logger.(interface{ IsEnabledFor(logging.Level) bool }); ok {
+		return l.IsEnabledFor(logging.DEBUG)
+	}
+	return true
+}
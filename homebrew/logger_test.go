@@ -0,0 +1,86 @@
+package homebrew
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLogger captures the log messages per level.
+type testLogger struct {
+	sync.Mutex
+	messages map[string][]string
+}
+
+func (l *testLogger) log(level, format string, args ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	if l.messages == nil {
+		l.messages = make(map[string][]string)
+	}
+	l.messages[level] = append(l.messages[level], fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{})   { l.log("debug", format, args...) }
+func (l *testLogger) Infof(format string, args ...interface{})    { l.log("info", format, args...) }
+func (l *testLogger) Warningf(format string, args ...interface{}) { l.log("warning", format, args...) }
+func (l *testLogger) Errorf(format string, args ...interface{})   { l.log("error", format, args...) }
+
+func (l *testLogger) contains(level, substr string) bool {
+	l.Lock()
+	defer l.Unlock()
+	for _, msg := range l.messages[level] {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSetLogger(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	logger := &testLogger{}
+	h.SetLogger(logger)
+	h.IncomingAuthFunc = func(uint32) ([]byte, bool) { return nil, false }
+
+	// An incoming login is refused.
+	remote := testRemote(t)
+	defer remote.Close()
+	if err := h.handle(remote.LocalAddr().(*net.UDPAddr), append(append([]byte{}, RepeaterLogin...), RepeaterIDBytes(1001)...)); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, remote, MasterNAK, time.Second)
+	if !logger.contains("warning", "login from unknown repeater ID 1001") {
+		t.Fatalf("expected refused login to be logged, got %v", logger.messages)
+	}
+
+	// Our login is refused by a master.
+	master := testRemote(t)
+	defer master.Close()
+	peer := &Peer{
+		ID:      2001,
+		Addr:    master.LocalAddr().(*net.UDPAddr),
+		AuthKey: []byte("passw0rd"),
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+	peer.Status = AuthBegin
+	if err := h.handle(peer.Addr, append(append([]byte{}, MasterNAK...), h.id...)); err != nil {
+		t.Fatal(err)
+	}
+	if !logger.contains("error", "peer 2001@"+peer.Addr.String()+" refused login") {
+		t.Fatalf("expected refused login to be logged, got %v", logger.messages)
+	}
+
+	// The default logger is restored with nil.
+	h.SetLogger(nil)
+	if h.logger != Logger(log) {
+		t.Fatal("expected the default logger")
+	}
+}
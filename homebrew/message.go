@@ -0,0 +1,114 @@
+package homebrew
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/bptc"
+)
+
+// BuildMessage builds the data header and rate ½ data block packets carrying a
+// short data message from srcID to dstID.
+func BuildMessage(msg string, srcID, dstID uint32, group bool, timeslot, colorCode uint8) ([]*dmr.Packet, error) {
+	data, err := dmr.BuildMessageData(msg, dmr.DDFormatUTF16, true)
+	if err != nil {
+		return nil, err
+	}
+
+	fragment := &dmr.DataFragment{Data: data}
+	blocks, err := fragment.DataBlocks(dmr.Rate12Data, false)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &dmr.DataHeader{
+		PacketFormat:       dmr.PacketFormatShortDataDefined,
+		DstIsGroup:         group,
+		ServiceAccessPoint: dmr.ServiceAccessPointShortData,
+		DstID:              dstID,
+		SrcID:              srcID,
+		Data: &dmr.ShortDataDefinedData{
+			AppendedBlocks: uint8(len(blocks)),
+			DDFormat:       dmr.DDFormatUTF16,
+			FullMessage:    true,
+		},
+	}
+	headerData, err := header.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var id = make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	var streamID = uint32(id[0])<<24 | uint32(id[1])<<16 | uint32(id[2])<<8 | uint32(id[3])
+	if streamID == 0 {
+		streamID = 1
+	}
+
+	var (
+		packets  = make([]*dmr.Packet, 0, len(blocks)+1)
+		callType = dmr.CallTypePrivate
+	)
+	if group {
+		callType = dmr.CallTypeGroup
+	}
+	add := func(info []byte, dataType uint8) error {
+		var bits = make([]byte, dmr.InfoBits)
+		if err := bptc.Encode(info, bits); err != nil {
+			return err
+		}
+		burst, err := dmr.BuildDataBurst(bits, colorCode, dataType)
+		if err != nil {
+			return err
+		}
+
+		p := &dmr.Packet{
+			Timeslot: timeslot,
+			Sequence: uint8(len(packets)),
+			SrcID:    srcID,
+			DstID:    dstID,
+			StreamID: streamID,
+			DataType: dataType,
+			CallType: callType,
+		}
+		p.SetData(burst)
+		packets = append(packets, p)
+		return nil
+	}
+
+	if err := add(headerData, dmr.Data); err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		if err := add(block.Bytes(dmr.Rate12Data, false), dmr.Rate12Data); err != nil {
+			return nil, err
+		}
+	}
+
+	return packets, nil
+}
+
+// SendMessageToPeer originates a short data message to a single peer.
+func (h *Homebrew) SendMessageToPeer(peer *Peer, msg string, srcID, dstID uint32, group bool, timeslot uint8) error {
+	if peer == nil {
+		return errors.New("homebrew: can't send message to nil peer")
+	}
+	if peer.NoData {
+		return fmt.Errorf("homebrew: peer %d does not accept data", peer.ID)
+	}
+
+	packets, err := BuildMessage(msg, srcID, dstID, group, timeslot, h.Config.ColorCode)
+	if err != nil {
+		return err
+	}
+	for _, p := range packets {
+		if err := h.WritePacketToPeer(p, peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
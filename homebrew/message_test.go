@@ -0,0 +1,70 @@
+package homebrew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/bptc"
+)
+
+func TestPeerReadyMessage(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, remote := testIncomingPeer(t, h, 1001)
+	quiet, quietRemote := testIncomingPeer(t, h, 1002)
+	quiet.NoData = true
+
+	var ready = map[uint32]error{}
+	h.OnPeerReady = func(peer *Peer) {
+		ready[peer.ID] = h.SendMessageToPeer(peer, "connected", 1, peer.ID, false, 0)
+	}
+
+	config := *testConfig
+	config.ID = peer.ID
+	if err := h.handle(peer.Addr, buildConfigData(&config)); err != nil {
+		t.Fatal(err)
+	}
+	config.ID = quiet.ID
+	if err := h.handle(quiet.Addr, buildConfigData(&config)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err, ok := ready[peer.ID]; !ok || err != nil {
+		t.Fatalf("expected ready hook to send message, got %v (called %t)", err, ok)
+	}
+	if err, ok := ready[quiet.ID]; !ok || err == nil {
+		t.Fatalf("expected ready hook to fail for data-less peer (called %t)", ok)
+	}
+
+	packets := readFrames(t, remote, 50*time.Millisecond)
+	if len(packets) < 2 {
+		t.Fatalf("expected data header and blocks, got %d packets", len(packets))
+	}
+	if packets[0].DataType != dmr.Data || packets[1].DataType != dmr.Rate12Data {
+		t.Fatalf("unexpected data types %d, %d", packets[0].DataType, packets[1].DataType)
+	}
+	if slotType := packets[0].SlotType(); slotType[0] != testConfig.ColorCode<<4|dmr.Data {
+		t.Fatalf("unexpected slot type %#02x", slotType[0])
+	}
+
+	var header = make([]byte, 12)
+	if err := bptc.Decode(packets[0].InfoBits(), header); err != nil {
+		t.Fatal(err)
+	}
+	dh, err := dmr.ParseDataHeader(header, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dh.DstID != peer.ID || dh.SrcID != 1 {
+		t.Fatalf("unexpected data header %s", dh)
+	}
+	if sd, ok := dh.Data.(*dmr.ShortDataDefinedData); !ok || int(sd.AppendedBlocks) != len(packets)-1 {
+		t.Fatalf("unexpected data header %s", dh)
+	}
+
+	if got := readFrames(t, quietRemote, 50*time.Millisecond); len(got) != 0 {
+		t.Fatalf("expected no frames to data-less peer, got %d", len(got))
+	}
+}
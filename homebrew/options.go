@@ -0,0 +1,93 @@
+package homebrew
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// optionsHeaderSize is the size of the RPTO frame header: the 4 byte tag and
+// the repeater ID, followed by the options string.
+const optionsHeaderSize = 8
+
+// BuildOptions formats options as the string carried by the RPTO frame, such
+// as "TS1_1=9990;TS2_1=91;", in key order. Options with a key containing '='
+// or ';', or a value containing ';', can't be represented and are left out.
+func BuildOptions(options map[string]string) []byte {
+	var keys = make([]string, 0, len(options))
+	for key, value := range options {
+		if key == "" || strings.ContainsAny(key, "=;") || strings.Contains(value, ";") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(options[key])
+		b.WriteByte(';')
+	}
+	return []byte(b.String())
+}
+
+// ParseOptions parses the options string carried by the RPTO frame. Options
+// that aren't of the form key=value are skipped and reported in the error,
+// the remaining options are returned regardless.
+func ParseOptions(data []byte) (map[string]string, error) {
+	var (
+		options   = make(map[string]string)
+		malformed []string
+	)
+	for _, option := range strings.Split(strings.TrimRight(string(data), "\x00"), ";") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		i := strings.IndexByte(option, '=')
+		if i < 1 {
+			malformed = append(malformed, option)
+			continue
+		}
+		options[strings.TrimSpace(option[:i])] = strings.TrimSpace(option[i+1:])
+	}
+	if len(malformed) > 0 {
+		return options, fmt.Errorf("homebrew: malformed options %q", malformed)
+	}
+	return options, nil
+}
+
+// buildOptionsData builds our RPTO frame.
+func buildOptionsData(id uint32, options map[string]string) []byte {
+	return append(append(append([]byte{}, RepeaterOptions...), RepeaterIDBytes(id)...), BuildOptions(options)...)
+}
+
+// writeConfig sends our configuration to an outgoing peer that accepted our
+// login, followed by our options if we have any.
+func (h *Homebrew) writeConfig(peer *Peer) error {
+	if err := h.writeControl(buildProfileConfigData(h.Config, peer.ConfigProfile), peer); err != nil {
+		return err
+	}
+	if len(h.Options) == 0 {
+		return nil
+	}
+	return h.WriteToPeer(buildOptionsData(h.Config.ID, h.Options), peer)
+}
+
+// handleOptions stores the options sent by an incoming peer.
+func (h *Homebrew) handleOptions(data []byte, peer *Peer) error {
+	if len(data) < optionsHeaderSize {
+		h.warnf(peer, "peer %d@%s sent short options frame (ignored)\n", peer.ID, peer.Addr)
+		return nil
+	}
+
+	options, err := ParseOptions(data[optionsHeaderSize:])
+	if err != nil {
+		h.warnf(peer, "peer %d@%s sent options: %v\n", peer.ID, peer.Addr, err)
+	}
+	h.logger.Debugf("peer %d@%s sent options %v\n", peer.ID, peer.Addr, options)
+	peer.Options = options
+	return h.WriteToPeer(append(RepeaterACK, h.id...), peer)
+}
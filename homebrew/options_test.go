@@ -0,0 +1,73 @@
+package homebrew
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptions(t *testing.T) {
+	var options = map[string]string{"TS1_1": "9990", "TS2_1": "91", "Voice": "1=on"}
+	data := BuildOptions(options)
+	if string(data) != "TS1_1=9990;TS2_1=91;Voice=1=on;" {
+		t.Fatalf("unexpected options %q", data)
+	}
+	parsed, err := ParseOptions(data)
+	if err != nil || !reflect.DeepEqual(parsed, options) {
+		t.Fatalf("expected options to parse back, got %v, %v", parsed, err)
+	}
+
+	// Unrepresentable options are left out.
+	if data := BuildOptions(map[string]string{"a;b": "1", "c": "2;3", "": "4", "d": ""}); string(data) != "d=;" {
+		t.Fatalf("unexpected options %q", data)
+	}
+
+	// Malformed options are reported, the others kept.
+	parsed, err = ParseOptions([]byte(" TS1_1 = 9990;;garbage;=1;TS2_1=91\x00\x00"))
+	if err == nil || !strings.Contains(err.Error(), "garbage") {
+		t.Fatalf("expected malformed options to be reported, got %v", err)
+	}
+	if !reflect.DeepEqual(parsed, map[string]string{"TS1_1": "9990", "TS2_1": "91"}) {
+		t.Fatalf("unexpected options %v", parsed)
+	}
+}
+
+func TestOptionsExchange(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	// Incoming peers have their options stored, and acknowledged.
+	peer, remote := testIncomingPeer(t, h, 1001)
+	if err := h.handle(peer.Addr, append(append([]byte("RPTO"), peer.id...), "TS2_1=91;bogus"...)); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, remote, RepeaterACK, time.Second)
+	if !reflect.DeepEqual(peer.Options, map[string]string{"TS2_1": "91"}) {
+		t.Fatalf("unexpected options %v", peer.Options)
+	}
+
+	// Outgoing peers are sent our options after our configuration.
+	h.Options = map[string]string{"TS1_1": "9990"}
+	master := testRemote(t)
+	defer master.Close()
+	outgoing := &Peer{
+		ID:      1002,
+		Addr:    master.LocalAddr().(*net.UDPAddr),
+		AuthKey: []byte("passw0rd"),
+	}
+	if err := h.Link(outgoing); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, master, RepeaterLogin, time.Second)
+	outgoing.Status = AuthBegin
+	if err := h.handle(outgoing.Addr, append(RepeaterACK, outgoing.id...)); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, master, RepeaterConfig, time.Second)
+	data := expectFrame(t, master, RepeaterOptions, time.Second)
+	if id := ParseRepeaterIDBytes(data[4:8]); id != h.Config.ID || string(data[8:]) != "TS1_1=9990;" {
+		t.Fatalf("unexpected options frame %q", data)
+	}
+}
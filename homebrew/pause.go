@@ -0,0 +1,39 @@
+package homebrew
+
+import "errors"
+
+// Pause stops forwarding traffic, for example during maintenance. The peers
+// stay linked, pings are still answered.
+func (h *Homebrew) Pause() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.logger.Infof("forwarding paused\n")
+	h.paused = true
+}
+
+// Resume forwarding traffic after Pause.
+func (h *Homebrew) Resume() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.logger.Infof("forwarding resumed\n")
+	h.paused = false
+}
+
+// Paused reports whether forwarding is paused.
+func (h *Homebrew) Paused() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.paused
+}
+
+// canTransmit checks that we may send DMR data, which we never do in Observer
+// mode.
+func (h *Homebrew) canTransmit() error {
+	if h.Observer {
+		return errors.New("homebrew: observer mode, not transmitting")
+	}
+	return nil
+}
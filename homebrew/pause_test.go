@@ -0,0 +1,87 @@
+package homebrew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestPause(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, originRemote := testIncomingPeer(t, h, 1001)
+	origin.Subscribe(91, 0)
+	listener, listenerRemote := testIncomingPeer(t, h, 1002)
+	listener.Subscribe(91, 0)
+
+	h.Pause()
+	if !h.Paused() {
+		t.Fatal("expected forwarding to be paused")
+	}
+	if err := h.handle(origin.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), origin.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SendSync(testPacket(2002, 91, dmr.CallTypeGroup)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrames(t, listenerRemote, 50*time.Millisecond); len(got) != 0 {
+		t.Fatalf("expected no frames while paused, got %d", len(got))
+	}
+
+	ping := append(append([]byte{}, RepeaterPing...), RepeaterIDBytes(origin.ID)...)
+	if err := h.handle(origin.Addr, ping); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, originRemote, MasterPong, time.Second)
+	if origin.Status != AuthDone || listener.Status != AuthDone {
+		t.Fatal("expected peers to stay authenticated while paused")
+	}
+
+	h.Resume()
+	if err := h.handle(origin.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), origin.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrames(t, listenerRemote, 50*time.Millisecond); len(got) != 1 {
+		t.Fatalf("expected frame to be forwarded after resume, got %d", len(got))
+	}
+}
+
+func TestObserver(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.Observer = true
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+	other, remote := testIncomingPeer(t, h, 1002)
+	other.Subscribe(91, 0)
+
+	// Without a packet handler, nothing is forwarded.
+	h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), origin)
+	if got := readFrames(t, remote, time.Millisecond*50); len(got) != 0 {
+		t.Fatalf("expected no frames forwarded in observer mode, got %v", got)
+	}
+
+	var received []*dmr.Packet
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received = append(received, p)
+		return nil
+	})
+	h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), origin)
+	if len(received) != 1 || received[0].SrcID != 2001 {
+		t.Fatalf("expected frame to reach the packet handler, got %v", received)
+	}
+	if err := h.Send(testPacket(2002, 91, dmr.CallTypeGroup)); err == nil {
+		t.Fatal("expected Send to fail in observer mode")
+	}
+	if err := h.SendTG(testPacket(2002, 91, dmr.CallTypeGroup), origin); err == nil {
+		t.Fatal("expected SendTG to fail in observer mode")
+	}
+	if err := h.WritePacketToPeer(testPacket(2002, 91, dmr.CallTypeGroup), other); err == nil {
+		t.Fatal("expected WritePacketToPeer to fail in observer mode")
+	}
+	if got := readFrames(t, remote, time.Millisecond*50); len(got) != 0 {
+		t.Fatalf("expected no frames sent in observer mode, got %v", got)
+	}
+}
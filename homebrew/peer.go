@@ -4,11 +4,20 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"net"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/polkabana/go-dmr"
 )
 
+// Forwarding filters, selecting the frames forwarded to a peer.
+const (
+	ForwardAll   uint8 = iota // Forward all frames
+	ForwardVoice              // Forward voice calls only
+	ForwardData               // Forward data calls only
+)
+
 // Peer is a remote repeater that also speaks the Homebrew protocol
 type Peer struct {
 	ID                  uint32
@@ -19,12 +28,15 @@ type Peer struct {
 	Nonce               []byte
 	Token               []byte
 	Incoming            bool
-	TGID                uint32
 	UnlinkOnAuthFailure bool
+	NoData              bool   // Peer doesn't accept data calls
+	RemoteSoftware      string // Detected master software, best effort
+	Forward             uint8  // Forwarding filter, defaults to ForwardAll
 	PacketReceived      dmr.PacketFunc
 	Last                struct {
 		TGSubscribed   time.Time
 		AuthSent       time.Time
+		Connected      time.Time // Login completed
 		PacketSent     time.Time
 		PacketReceived time.Time
 		PingSent       time.Time
@@ -32,8 +44,75 @@ type Peer struct {
 		PongReceived   time.Time
 	}
 
+	// LocalAddr binds an outgoing link to a local address, to select the
+	// interface or source address the master is reached from. The link then
+	// uses its own socket instead of the one shared by the other peers, its
+	// frames are still handled by ListenAndServe.
+	LocalAddr *net.UDPAddr
+	conn      *net.UDPConn
+
+	// Talkgroups always forwarded to the peer per timeslot (0 for TS1, 1 for
+	// TS2, or AnyTimeslot for both), next to the talkgroups it last
+	// transmitted on, see Subscribed.
+	Static map[uint8]map[uint32]bool
+
+	// Source and destination IDs accepted from the peer, see Permits. Empty
+	// allowlists allow all IDs, the denylists take precedence.
+	AllowedSrc map[uint32]bool
+	DeniedSrc  map[uint32]bool
+	AllowedDst map[uint32]bool
+	DeniedDst  map[uint32]bool
+
+	// Dynamic subscription per timeslot, the talkgroup last transmitted on,
+	// expiring after Timeouts.TGTimeout. Guarded by routing, as is the
+	// hangtime state of slot, so the snapshots and the callbacks can read them
+	// while frames are being handled.
+	routing    sync.Mutex
+	dynamic    [2]uint32
+	subscribed [2]time.Time
+	tgID       uint32 // Most recent dynamic subscription, see TGID
+	tgTimeslot uint8
+
+	// Log in again from scratch instead of only sending our new
+	// configuration on UpdateConfig, for masters that require it
+	ReloginOnConfigChange bool
+
+	// Options sent by the repeater in a RPTO frame, see ParseOptions
+	Options map[string]string
+
+	// Formatting of our configuration, as expected by the master
+	ConfigProfile ConfigProfile
+
+	// Round-trip time of the last ping, and its smoothed average. Only
+	// measured on outgoing links, where we send the pings.
+	RTT         time.Duration
+	SmoothedRTT time.Duration
+
+	// Traffic counters, see SnapshotCounters
+	Counters Counters
+	counters sync.Mutex
+	traffic  traffic
+
 	// Packed repeater ID
 	id []byte
+
+	// Active stream per timeslot
+	slot [2]slotState
+
+	// Rekey in progress, see Homebrew.Rekey
+	rekey bool
+
+	// The master acknowledged our configuration since the login
+	configured bool
+
+	// Control frame awaiting a reply, see Homebrew.ControlRetries
+	control *pendingControl
+
+	// Outbound data frames, see Homebrew.PeerQueueSize
+	queue *sendQueue
+
+	// Last warning logged, to coalesce repeats
+	warning warning
 }
 
 func (p *Peer) CheckRepeaterID(id []byte) bool {
@@ -47,3 +126,147 @@ func (p *Peer) UpdateToken(nonce []byte) {
 	hash.Write(p.AuthKey)
 	p.Token = []byte(hash.Sum(nil))
 }
+
+// setStatus sets the authentication status of the peer. Status and
+// Last.Connected are written under the counters lock, so snapshots such as
+// Peers and Stats can read them while frames are being handled.
+func (p *Peer) setStatus(status AuthStatus) {
+	p.counters.Lock()
+	p.Status = status
+	p.counters.Unlock()
+}
+
+// connect records the completed login of the peer.
+func (p *Peer) connect(now time.Time) {
+	p.counters.Lock()
+	p.Status = AuthDone
+	p.Last.Connected = now
+	p.counters.Unlock()
+}
+
+// session returns the authentication status of the peer and when it last
+// completed its login.
+func (p *Peer) session() (AuthStatus, time.Time) {
+	p.counters.Lock()
+	defer p.counters.Unlock()
+
+	return p.Status, p.Last.Connected
+}
+
+// done returns whether the peer completed its login, see session.
+func (p *Peer) done() bool {
+	status, _ := p.session()
+	return status == AuthDone
+}
+
+// Subscribe dynamically subscribes the peer to the talkgroup on the timeslot
+// (0 for TS1, 1 for TS2), as happens when it transmits on the talkgroup. It
+// replaces the previous dynamic subscription on the timeslot, and expires
+// after Timeouts.TGTimeout.
+func (p *Peer) Subscribe(tg uint32, timeslot uint8) {
+	p.routing.Lock()
+	defer p.routing.Unlock()
+
+	var now = time.Now()
+	p.dynamic[timeslot&0x01] = tg
+	p.subscribed[timeslot&0x01] = now
+	p.tgID = tg
+	p.tgTimeslot = timeslot & 0x01
+	p.Last.TGSubscribed = now
+}
+
+// TGID returns the talkgroup the peer is most recently dynamically subscribed
+// to, on either timeslot.
+func (p *Peer) TGID() uint32 {
+	p.routing.Lock()
+	defer p.routing.Unlock()
+
+	return p.tgID
+}
+
+// subscriptions returns the dynamic subscriptions per timeslot, and the most
+// recent one with its timeslot.
+func (p *Peer) subscriptions() (dynamic [2]uint32, tg uint32, timeslot uint8) {
+	p.routing.Lock()
+	defer p.routing.Unlock()
+
+	return p.dynamic, p.tgID, p.tgTimeslot
+}
+
+// Subscribed checks whether group calls to the talkgroup on the timeslot are
+// forwarded to the peer, because it's statically or dynamically subscribed.
+func (p *Peer) Subscribed(tg uint32, timeslot uint8) bool {
+	p.routing.Lock()
+	var dynamic = !p.subscribed[timeslot&0x01].IsZero() && p.dynamic[timeslot&0x01] == tg
+	p.routing.Unlock()
+
+	return dynamic || p.Static[timeslot&0x01][tg] || p.Static[AnyTimeslot][tg]
+}
+
+// Accepts checks the frame against the forwarding filter of the peer. The voice
+// LC header and terminator are part of a voice call, any other frame with a
+// data SYNC is part of a data call.
+func (p *Peer) Accepts(packet *dmr.Packet) bool {
+	if p.Forward == ForwardAll {
+		return true
+	}
+
+	var voice = packet.DataType == dmr.VoiceLC || packet.DataType == dmr.TerminatorWithLC ||
+		dmr.DataTypeBurstClass(packet.DataType) != dmr.BurstClassData
+	return voice == (p.Forward == ForwardVoice)
+}
+
+// Permits checks the source and destination of a packet received from the
+// peer against its allowlists and denylists.
+func (p *Peer) Permits(packet *dmr.Packet) bool {
+	switch {
+	case p.DeniedSrc[packet.SrcID], p.DeniedDst[packet.DstID]:
+		return false
+	case len(p.AllowedSrc) > 0 && !p.AllowedSrc[packet.SrcID]:
+		return false
+	case len(p.AllowedDst) > 0 && !p.AllowedDst[packet.DstID]:
+		return false
+	}
+	return true
+}
+
+// PeerInfo is a copy of the state of a peer, see Peers.
+type PeerInfo struct {
+	ID        uint32           `json:"id"`
+	Addr      string           `json:"addr"`
+	Incoming  bool             `json:"incoming"`
+	Status    string           `json:"status"`
+	Connected time.Time        `json:"connected"`        // Login completed
+	Dynamic   [2]uint32        `json:"dynamic"`          // Per timeslot
+	Static    []TGSubscription `json:"static,omitempty"` // See Peer.Static
+}
+
+// Peers returns the peers sorted by ID. Unlike the live Peer values, the
+// returned slice is a point-in-time snapshot owned by the caller, so it can be
+// rendered, by a dashboard for example, while the peers keep changing. It's
+// safe to call from the callbacks.
+func (h *Homebrew) Peers() []PeerInfo {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var peers = make([]PeerInfo, 0, len(h.PeerID))
+	for _, peer := range h.PeerID {
+		var addr string
+		if peer.Addr != nil {
+			addr = peer.Addr.String()
+		}
+		status, connected := peer.session()
+		dynamic, _, _ := peer.subscriptions()
+		peers = append(peers, PeerInfo{
+			ID:        peer.ID,
+			Addr:      addr,
+			Incoming:  peer.Incoming,
+			Status:    status.String(),
+			Connected: connected,
+			Dynamic:   dynamic,
+			Static:    staticRoutes(peer),
+		})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+	return peers
+}
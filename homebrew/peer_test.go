@@ -0,0 +1,359 @@
+package homebrew
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestForwardingFilter(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+	all, allRemote := testIncomingPeer(t, h, 1002)
+	voiceOnly, voiceRemote := testIncomingPeer(t, h, 1003)
+	voiceOnly.Forward = ForwardVoice
+	dataOnly, dataRemote := testIncomingPeer(t, h, 1004)
+	dataOnly.Forward = ForwardData
+	for _, peer := range []*Peer{all, voiceOnly, dataOnly} {
+		peer.Subscribe(91, 0)
+	}
+
+	header := testPacket(2001, 91, dmr.CallTypeGroup)
+	header.DataType = dmr.VoiceLC
+	packets := []*dmr.Packet{header, testPacket(2001, 91, dmr.CallTypeGroup)}
+	message, err := BuildMessage("hello", 2001, 91, true, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets = append(packets, message...)
+	for _, p := range packets {
+		if err := h.SendTG(p, origin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := readFrames(t, allRemote, 50*time.Millisecond); len(got) != len(packets) {
+		t.Fatalf("expected %d frames on unfiltered peer, got %d", len(packets), len(got))
+	}
+	got := readFrames(t, voiceRemote, 50*time.Millisecond)
+	if len(got) != 2 || got[0].DataType != dmr.VoiceLC || got[1].DataType != dmr.VoiceBurstA {
+		t.Fatalf("expected only voice frames on voice-only peer, got %d frames", len(got))
+	}
+	got = readFrames(t, dataRemote, 50*time.Millisecond)
+	if len(got) != len(message) {
+		t.Fatalf("expected %d data frames on data-only peer, got %d", len(message), len(got))
+	}
+	for _, p := range got {
+		if p.DataType != dmr.Data && p.DataType != dmr.Rate12Data {
+			t.Fatalf("unexpected %s frame on data-only peer", dmr.DataTypeName[p.DataType])
+		}
+	}
+}
+
+func TestSubscriptionsPerTimeslot(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+	dynamic, remoteDynamic := testIncomingPeer(t, h, 1002)
+	static, remoteStatic := testIncomingPeer(t, h, 1003)
+	static.Static = map[uint8]map[uint32]bool{1: {91: true}, AnyTimeslot: {3100: true}}
+
+	// The dynamic peer transmits on TG 91 on TS1, then on TG 92 on TS2, and
+	// stays subscribed to both.
+	for _, p := range []*dmr.Packet{testPacket(2002, 91, dmr.CallTypeGroup), testPacket(2002, 92, dmr.CallTypeGroup)} {
+		p.Timeslot = uint8(p.DstID - 91)
+		p.DataType = dmr.TerminatorWithLC
+		if err := h.handlePacket(p, dynamic); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readFrames(t, remoteStatic, 50*time.Millisecond)
+	if dynamic.TGID() != 92 || !dynamic.Subscribed(91, 0) || !dynamic.Subscribed(92, 1) || dynamic.Subscribed(91, 1) {
+		t.Fatalf("unexpected dynamic subscriptions %v", dynamic.dynamic)
+	}
+	if static.Subscribed(0, 0) || static.Subscribed(0, 1) {
+		t.Fatal("expected no subscription to TG 0 without a dynamic subscription")
+	}
+
+	var tests = []struct {
+		tg                  uint32
+		timeslot            uint8
+		toDynamic, toStatic int
+	}{
+		{91, 0, 1, 0},
+		{91, 1, 0, 1},
+		{92, 1, 1, 0},
+		{3100, 0, 0, 1},
+		{3100, 1, 0, 1},
+		{93, 0, 0, 0},
+	}
+	for _, test := range tests {
+		p := testPacket(2001, test.tg, dmr.CallTypeGroup)
+		p.Timeslot = test.timeslot
+		p.DataType = dmr.TerminatorWithLC
+		if err := h.handlePacket(p, origin); err != nil {
+			t.Fatal(err)
+		}
+		if got := readFrames(t, remoteDynamic, 50*time.Millisecond); len(got) != test.toDynamic {
+			t.Errorf("TG %d on TS%d: expected %d frames on the dynamic peer, got %d", test.tg, test.timeslot+1, test.toDynamic, len(got))
+		}
+		if got := readFrames(t, remoteStatic, 50*time.Millisecond); len(got) != test.toStatic {
+			t.Errorf("TG %d on TS%d: expected %d frames on the static peer, got %d", test.tg, test.timeslot+1, test.toStatic, len(got))
+		}
+	}
+
+	snapshot := h.DumpRouting()
+	if p := snapshot.Peers[1]; p.Dynamic != [2]uint32{91, 92} {
+		t.Fatalf("unexpected dynamic subscriptions %+v", p)
+	}
+	if p := snapshot.Peers[2]; len(p.Static) != 2 || p.Static[0] != (TGSubscription{91, 1}) || p.Static[1] != (TGSubscription{3100, AnyTimeslot}) {
+		t.Fatalf("unexpected static subscriptions %+v", p.Static)
+	}
+}
+
+func TestPeersSnapshot(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1002)
+	peer.Subscribe(91, 1)
+	peer.Static = map[uint8]map[uint32]bool{AnyTimeslot: {3100: true}}
+	testIncomingPeer(t, h, 1001)
+
+	peers := h.Peers()
+	if len(peers) != 2 || peers[0].ID != 1001 || peers[1].ID != 1002 {
+		t.Fatalf("expected peers sorted by ID, got %+v", peers)
+	}
+	if p := peers[1]; p.Addr != peer.Addr.String() || !p.Incoming || p.Status != "done" || p.Dynamic[1] != 91 ||
+		!reflect.DeepEqual(p.Static, []TGSubscription{{TGID: 3100, Timeslot: AnyTimeslot}}) {
+		t.Fatalf("unexpected peer %+v", p)
+	}
+
+	// The snapshot is safe to read while the peers change.
+	var (
+		wg   sync.WaitGroup
+		stop = make(chan struct{})
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := uint32(0); ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			p := testPacket(2001, 91+i%4, dmr.CallTypeGroup)
+			p.Timeslot = uint8(i % 2)
+			p.StreamID = i
+			h.handlePacket(p, peer)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := uint32(0); ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			extra := &Peer{ID: 2000 + i%8, Addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 30000 + int(i%8)}, Incoming: true}
+			h.Link(extra)
+			h.Unlink(extra.ID)
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		for _, p := range h.Peers() {
+			_ = p.Status + p.Addr
+			_ = p.Dynamic[0] + p.Dynamic[1]
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	// And while peers log in and out, as are the statistics.
+	transport := newTestTransport()
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only handle touches the peers, see InlineKeepalive.
+	h.InlineKeepalive = true
+	done := make(chan error)
+	go func() { done <- h.ListenAndServe() }()
+	defer func() {
+		h.Close()
+		<-done
+	}()
+
+	stop = make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, p := range h.Peers() {
+					_ = p.Status + p.Connected.String()
+				}
+				for _, s := range h.Stats() {
+					_ = s.Uptime
+				}
+			}
+		}()
+	}
+	var (
+		addr = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 62031}
+		id   = RepeaterIDBytes(1001)
+	)
+	for i := 0; i < 500; i++ {
+		reply := transport.exchange(t, addr, append(append([]byte{}, RepeaterLogin...), id...))
+		key := sha256.Sum256(append(append([]byte{}, reply[6:]...), "passw0rd"...))
+		reply = transport.exchange(t, addr, append(append(append([]byte{}, RepeaterKey...), id...), key[:]...))
+		if !bytes.HasPrefix(reply, RepeaterACK) {
+			t.Fatalf("expected RPTACK after key, got %q", reply)
+		}
+		transport.in <- testDatagram{addr: addr, data: BuildClosing(1001, false)}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestPeerPermits(t *testing.T) {
+	var ids = func(ids ...uint32) map[uint32]bool {
+		var m = make(map[uint32]bool)
+		for _, id := range ids {
+			m[id] = true
+		}
+		return m
+	}
+
+	var tests = []struct {
+		name     string
+		peer     *Peer
+		src, dst uint32
+		permits  bool
+	}{
+		{"none", &Peer{}, 2001, 91, true},
+		{"allowed source", &Peer{AllowedSrc: ids(2001)}, 2001, 91, true},
+		{"not allowed source", &Peer{AllowedSrc: ids(2001)}, 2002, 91, false},
+		{"allowed destination", &Peer{AllowedDst: ids(91, 92)}, 2001, 92, true},
+		{"not allowed destination", &Peer{AllowedDst: ids(91, 92)}, 2001, 93, false},
+		{"denied source", &Peer{DeniedSrc: ids(2001)}, 2001, 91, false},
+		{"not denied source", &Peer{DeniedSrc: ids(2001)}, 2002, 91, true},
+		{"denied destination", &Peer{DeniedDst: ids(9)}, 2001, 9, false},
+		{"not denied destination", &Peer{DeniedDst: ids(9)}, 2001, 91, true},
+		{"allowed and denied source", &Peer{AllowedSrc: ids(2001, 2002), DeniedSrc: ids(2002)}, 2002, 91, false},
+		{"allowed source, denied destination", &Peer{AllowedSrc: ids(2001), DeniedDst: ids(9)}, 2001, 9, false},
+		{"allowed source and destination", &Peer{AllowedSrc: ids(2001), AllowedDst: ids(91), DeniedDst: ids(9)}, 2001, 91, true},
+		{"allowed source, not allowed destination", &Peer{AllowedSrc: ids(2001), AllowedDst: ids(91)}, 2001, 92, false},
+	}
+	for _, test := range tests {
+		if got := test.peer.Permits(testPacket(test.src, test.dst, dmr.CallTypeGroup)); got != test.permits {
+			t.Errorf("%s: expected %t for %d to %d, got %t", test.name, test.permits, test.src, test.dst, got)
+		}
+	}
+
+	// Denied frames are dropped and counted
+	h := testHomebrew(t)
+	defer h.Close()
+
+	var received []*dmr.Packet
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received = append(received, p)
+		return nil
+	})
+	peer, _ := testIncomingPeer(t, h, 1001)
+	peer.DeniedDst = ids(9)
+
+	h.handlePacket(testPacket(2001, 9, dmr.CallTypeGroup), peer)
+	if len(received) != 0 || peer.Counters.DeniedFrames != 1 {
+		t.Fatalf("expected denied frame to be dropped, got %d frames and %d denied", len(received), peer.Counters.DeniedFrames)
+	}
+	h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), peer)
+	if len(received) != 1 || peer.Counters.DeniedFrames != 1 {
+		t.Fatalf("expected frame to be forwarded, got %d frames and %d denied", len(received), peer.Counters.DeniedFrames)
+	}
+}
+
+func TestPeersFromCallback(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	var snapshot []PeerInfo
+	h.OnStreamStart = func(p *dmr.Packet) {
+		snapshot = h.Peers()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), peer) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Peers from OnStreamStart blocked")
+	}
+	if len(snapshot) != 1 || snapshot[0].ID != 1001 {
+		t.Fatalf("expected snapshot of peer 1001, got %+v", snapshot)
+	}
+}
+
+func TestStatusReadBySenders(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	other, _ := testIncomingPeer(t, h, 1002)
+	other.Subscribe(91, 0)
+	h.AddTGRoute(91, 0, other.ID)
+
+	// The reader and keepalive goroutines change the status while frames are
+	// sent, run with -race.
+	var (
+		stop    = make(chan struct{})
+		started = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		close(started)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			other.setStatus(AuthNone)
+			other.connect(time.Now())
+		}
+	}()
+
+	group := testPacket(2001, 91, dmr.CallTypeGroup)
+	private := testPacket(2001, 3001, dmr.CallTypePrivate)
+	<-started
+	for deadline := time.Now().Add(50 * time.Millisecond); time.Now().Before(deadline); {
+		h.SendTG(group, peer)
+		h.SendRouted(group, peer)
+		h.SendPrivate(private)
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package homebrew
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+)
+
+// PeerDefinition describes an outgoing peer, such as a master to connect to,
+// as stored in a JSON configuration file.
+type PeerDefinition struct {
+	ID       uint32           `json:"id"`
+	Addr     string           `json:"addr"` // host:port
+	Password string           `json:"password"`
+	Static   []TGSubscription `json:"static,omitempty"` // See Peer.Static
+}
+
+// Peer resolves the address of the definition into a new Peer.
+func (d PeerDefinition) Peer() (*Peer, error) {
+	addr, err := net.ResolveUDPAddr("udp", d.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("homebrew: peer %d: %v", d.ID, err)
+	}
+	var static map[uint8]map[uint32]bool
+	for _, s := range d.Static {
+		if static == nil {
+			static = make(map[uint8]map[uint32]bool)
+		}
+		if static[s.Timeslot] == nil {
+			static[s.Timeslot] = make(map[uint32]bool)
+		}
+		static[s.Timeslot][s.TGID] = true
+	}
+	return &Peer{
+		ID:      d.ID,
+		Addr:    addr,
+		AuthKey: []byte(d.Password),
+		Static:  static,
+	}, nil
+}
+
+// LoadPeerDefinitions reads a JSON list of peer definitions.
+func LoadPeerDefinitions(r io.Reader) ([]PeerDefinition, error) {
+	var defs []PeerDefinition
+	if err := json.NewDecoder(r).Decode(&defs); err != nil {
+		return nil, fmt.Errorf("homebrew: invalid peer definitions: %v", err)
+	}
+	return defs, nil
+}
+
+// SavePeerDefinitions writes a JSON list of peer definitions.
+func SavePeerDefinitions(w io.Writer, defs []PeerDefinition) error {
+	data, err := json.MarshalIndent(defs, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// LinkAll links an outgoing peer for each of the definitions.
+func (h *Homebrew) LinkAll(defs []PeerDefinition) error {
+	for _, d := range defs {
+		peer, err := d.Peer()
+		if err != nil {
+			return err
+		}
+		if err := h.Link(peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PeerDefinitions returns the definitions of the linked outgoing peers, to
+// persist them with SavePeerDefinitions.
+func (h *Homebrew) PeerDefinitions() []PeerDefinition {
+	var defs []PeerDefinition
+	for _, peer := range h.getPeers() {
+		if peer.Incoming {
+			continue
+		}
+		defs = append(defs, PeerDefinition{
+			ID:       peer.ID,
+			Addr:     peer.Addr.String(),
+			Password: string(peer.AuthKey),
+			Static:   staticRoutes(peer),
+		})
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].ID < defs[j].ID })
+	return defs
+}
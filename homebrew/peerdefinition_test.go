@@ -0,0 +1,54 @@
+package homebrew
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPeerDefinitions(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	master := testRemote(t)
+	defer master.Close()
+
+	var input = fmt.Sprintf(`[
+		{"id": 2002, "addr": "127.0.0.1:62031", "password": "s3cret"},
+		{"id": 2001, "addr": %q, "password": "passw0rd", "static": [{"tgid": 91, "timeslot": 1}, {"tgid": 3100, "timeslot": 255}]}
+	]`, master.LocalAddr().String())
+	defs, err := LoadPeerDefinitions(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.LinkAll(defs); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, master, RepeaterLogin, time.Second)
+
+	peer := h.getPeer(2001)
+	if peer == nil || string(peer.AuthKey) != "passw0rd" || !peer.Subscribed(3100, 0) || !peer.Subscribed(91, 1) || peer.Subscribed(91, 0) {
+		t.Fatalf("unexpected linked peer %+v", peer)
+	}
+
+	var buf bytes.Buffer
+	if err := SavePeerDefinitions(&buf, h.PeerDefinitions()); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := LoadPeerDefinitions(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 || saved[0].ID != 2001 || saved[1].ID != 2002 {
+		t.Fatalf("unexpected saved definitions %+v", saved)
+	}
+	if fmt.Sprint(saved[0]) != fmt.Sprint(defs[1]) || fmt.Sprint(saved[1]) != fmt.Sprint(defs[0]) {
+		t.Fatalf("expected definitions %+v, got %+v", defs, saved)
+	}
+
+	if _, err := LoadPeerDefinitions(strings.NewReader("{")); err == nil {
+		t.Fatal("expected error for invalid definitions")
+	}
+}
@@ -0,0 +1,53 @@
+package homebrew
+
+import (
+	"errors"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/lc"
+)
+
+// embeddedLC reassembles the embedded LC carried by voice bursts B to E, and
+// reports the GPS info LCs to OnPosition. The color code of the fragments must
+// be consistent, a change means interference or an overlapping transmission,
+// it's reported to OnColorCodeChange and the reassembly starts over.
+func (h *Homebrew) embeddedLC(p *dmr.Packet, peer *Peer, now time.Time) {
+	if (h.OnPosition == nil && h.OnColorCodeChange == nil) || p.DataType < dmr.VoiceBurstB || p.DataType > dmr.VoiceBurstE || len(p.Bits) != dmr.PayloadBits {
+		return
+	}
+
+	emb, err := dmr.ParseEMB(p.EMBBits())
+	if err != nil || emb.LCSS == dmr.SingleFragment {
+		return
+	}
+
+	fragment, err := dmr.ParseEmbeddedSignallingLCFromSyncBits(p.SyncBits())
+	if err != nil {
+		return
+	}
+	s := &peer.slot[p.Timeslot&0x01]
+	if err := s.embedded.AddFragment(p.DataType, emb.ColorCode, fragment); err != nil {
+		var cc *lc.ColorCodeError
+		if !errors.As(err, &cc) {
+			return
+		}
+		h.warnf(peer, "peer %d@%s stream %#08x on TS%d changed color code from %d to %d mid superframe\n",
+			peer.ID, peer.Addr, p.StreamID, p.Timeslot+1, cc.From, cc.To)
+		if h.OnColorCodeChange != nil {
+			h.OnColorCodeChange(peer, p, cc.From, cc.To)
+		}
+		return
+	}
+	if p.DataType != dmr.VoiceBurstE {
+		return
+	}
+	l, ok := s.embedded.LC()
+	if !ok || l.Opcode != lc.GpsInfo || h.OnPosition == nil {
+		return
+	}
+
+	lat, lon := l.GpsInfo.Position()
+	h.logger.Debugf("peer %d@%s position of %d: %f, %f\n", peer.ID, peer.Addr, p.SrcID, lat, lon)
+	h.OnPosition(p.SrcID, lat, lon, now)
+}
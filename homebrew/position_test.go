@@ -0,0 +1,126 @@
+package homebrew
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/lc"
+	"github.com/polkabana/go-dmr/vbptc"
+)
+
+func TestPosition(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	type position struct {
+		src      uint32
+		lat, lon float64
+	}
+	var got []position
+	h.OnPosition = func(srcID uint32, lat, lon float64, _ time.Time) {
+		got = append(got, position{srcID, lat, lon})
+	}
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	gps := &lc.GpsInfoPDU{}
+	gps.SetPosition(-33.4489, -70.6693)
+	eslc, err := dmr.NewEmbeddedSignallingLC((&lc.LC{Opcode: lc.GpsInfo, GpsInfo: gps}).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := vbptc.Encode(eslc.Interleave(), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, lcss := range []uint8{dmr.FirstFragment, dmr.Continuation, dmr.Continuation, dmr.LastFragment} {
+		fragment := embedded[i*dmr.EMBSignallingLCFragmentBits : (i+1)*dmr.EMBSignallingLCFragmentBits]
+		signal, err := dmr.BuildEmbeddedSignalling(testConfig.ColorCode, lcss, fragment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		burst, err := dmr.BuildVoiceBurst(make([]byte, dmr.VoiceBits), signal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := testPacket(2001, 91, dmr.CallTypeGroup)
+		p.DataType = dmr.VoiceBurstB + uint8(i)
+		p.Data = burst
+		if err := h.handle(peer.Addr, testData(t, p, peer.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 1 || got[0].src != 2001 {
+		t.Fatalf("expected 1 position from 2001, got %+v", got)
+	}
+	if math.Abs(got[0].lat+33.4489) > 1e-4 || math.Abs(got[0].lon+70.6693) > 1e-4 {
+		t.Fatalf("unexpected position %f, %f", got[0].lat, got[0].lon)
+	}
+}
+
+func TestColorCodeChange(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	var (
+		positions int
+		changes   [][2]uint8
+	)
+	h.OnPosition = func(uint32, float64, float64, time.Time) { positions++ }
+	h.OnColorCodeChange = func(_ *Peer, _ *dmr.Packet, from, to uint8) {
+		changes = append(changes, [2]uint8{from, to})
+	}
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	gps := &lc.GpsInfoPDU{}
+	gps.SetPosition(52.0, 4.5)
+	eslc, err := dmr.NewEmbeddedSignallingLC((&lc.LC{Opcode: lc.GpsInfo, GpsInfo: gps}).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := vbptc.Encode(eslc.Interleave(), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	superframe := func(colorCodes []uint8) {
+		t.Helper()
+		for i, lcss := range []uint8{dmr.FirstFragment, dmr.Continuation, dmr.Continuation, dmr.LastFragment} {
+			fragment := embedded[i*dmr.EMBSignallingLCFragmentBits : (i+1)*dmr.EMBSignallingLCFragmentBits]
+			signal, err := dmr.BuildEmbeddedSignalling(colorCodes[i], lcss, fragment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			burst, err := dmr.BuildVoiceBurst(make([]byte, dmr.VoiceBits), signal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := testPacket(2001, 91, dmr.CallTypeGroup)
+			p.DataType = dmr.VoiceBurstB + uint8(i)
+			p.Data = burst
+			if err := h.handle(peer.Addr, testData(t, p, peer.ID)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// The color code changes half way, the reassembly is abandoned.
+	superframe([]uint8{1, 1, 2, 2})
+	if len(changes) != 1 || changes[0] != [2]uint8{1, 2} {
+		t.Fatalf("expected a color code change from 1 to 2, got %v", changes)
+	}
+	if positions != 0 {
+		t.Fatalf("expected no position from an inconsistent superframe, got %d", positions)
+	}
+
+	// The next consistent superframe is reassembled again.
+	superframe([]uint8{2, 2, 2, 2})
+	if len(changes) != 1 || positions != 1 {
+		t.Fatalf("expected 1 position and no further changes, got %d positions and changes %v", positions, changes)
+	}
+}
@@ -0,0 +1,47 @@
+package homebrew
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Rekey forces an authenticated incoming peer to authenticate again with a
+// fresh nonce. The peer goes through AuthBegin while the session stays
+// linked; it's dropped if it doesn't answer with the new key within
+// Timeouts.AuthTimeout.
+func (h *Homebrew) Rekey(id uint32) error {
+	peer := h.getPeer(id)
+	if peer == nil {
+		return fmt.Errorf("homebrew: peer %d not linked", id)
+	}
+	if !peer.Incoming || !peer.done() {
+		return fmt.Errorf("homebrew: peer %d is not an authenticated incoming peer", id)
+	}
+
+	nonce := make([]byte, 4)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	h.logger.Debugf("peer %d@%s rekeying\n", peer.ID, peer.Addr)
+	peer.UpdateToken(nonce)
+	peer.setStatus(AuthBegin)
+	peer.rekey = true
+	peer.Last.AuthSent = time.Now()
+	h.authEvent(peer, NonceIssued, "")
+	return h.WriteToPeer(append(RepeaterACK, nonce...), peer)
+}
+
+// failRekey drops a peer that didn't complete the rekey.
+func (h *Homebrew) failRekey(peer *Peer, reason string) error {
+	h.logger.Errorf("peer %d@%s rekey failed: %s; dropping\n", peer.ID, peer.Addr, reason)
+	peer.rekey = false
+	peer.setStatus(AuthNone)
+	if err := h.Unlink(peer.ID); err != nil {
+		return err
+	}
+	h.peerDisconnected(peer, &AuthError{PeerID: peer.ID, Phase: "rekey", Err: errors.New(reason)})
+	return h.WriteToPeer(BuildClosing(h.Config.ID, true), peer)
+}
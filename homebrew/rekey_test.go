@@ -0,0 +1,62 @@
+package homebrew
+
+import (
+	"crypto/sha256"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRekey(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	rekeyAndAnswer := func(peer *Peer, remote *net.UDPConn, key []byte) {
+		t.Helper()
+		if err := h.Rekey(peer.ID); err != nil {
+			t.Fatal(err)
+		}
+		if peer.Status != AuthBegin {
+			t.Fatalf("expected peer %d in AuthBegin, got %s", peer.ID, peer.Status.String())
+		}
+		nonce := expectFrame(t, remote, RepeaterACK, time.Second)[len(RepeaterACK):]
+		if key == nil {
+			return
+		}
+		hash := sha256.Sum256(append(append([]byte{}, nonce...), key...))
+		data := append(append(append([]byte{}, RepeaterKey...), RepeaterIDBytes(peer.ID)...), hash[:]...)
+		if err := h.handle(peer.Addr, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	good, goodRemote := testIncomingPeer(t, h, 1001)
+	rekeyAndAnswer(good, goodRemote, good.AuthKey)
+	expectFrame(t, goodRemote, RepeaterACK, time.Second)
+	if good.Status != AuthDone || h.getPeer(good.ID) != good {
+		t.Fatalf("expected peer to stay connected after rekey, status %s", good.Status.String())
+	}
+
+	wrong, wrongRemote := testIncomingPeer(t, h, 1002)
+	rekeyAndAnswer(wrong, wrongRemote, []byte("wrong"))
+	expectFrame(t, wrongRemote, MasterClosing, time.Second)
+	if h.getPeer(wrong.ID) != nil {
+		t.Fatal("expected peer with wrong key to be dropped")
+	}
+
+	silent, silentRemote := testIncomingPeer(t, h, 1003)
+	rekeyAndAnswer(silent, silentRemote, nil)
+	h.housekeeping(time.Now())
+	if h.getPeer(silent.ID) != silent {
+		t.Fatal("expected peer to stay linked during rekey")
+	}
+	h.housekeeping(time.Now().Add(h.Timeouts.AuthTimeout * 2))
+	expectFrame(t, silentRemote, MasterClosing, time.Second)
+	if h.getPeer(silent.ID) != nil {
+		t.Fatal("expected peer not answering rekey to be dropped")
+	}
+
+	if err := h.Rekey(silent.ID); err == nil {
+		t.Fatal("expected rekey of unlinked peer to fail")
+	}
+}
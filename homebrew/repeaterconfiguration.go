@@ -1,7 +1,10 @@
 package homebrew
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/polkabana/go-dmr"
 )
@@ -10,21 +13,49 @@ import (
 // should be returned by a callback in the implementation, returning actual
 // information about the current repeater status.
 type RepeaterConfiguration struct {
-	Callsign    string
-	ID          uint32 // Our RepeaterID
-	RXFreq      uint32
-	TXFreq      uint32
-	TXPower     uint8
-	ColorCode   uint8
-	Slots       uint8
-	Latitude    float32
-	Longitude   float32
-	Height      uint16
-	Location    string
-	Description string
-	URL         string
-	SoftwareID  string
-	PackageID   string
+	Callsign    string  `json:"callsign"`
+	ID          uint32  `json:"id"` // Our RepeaterID
+	RXFreq      uint32  `json:"rx_freq"`
+	TXFreq      uint32  `json:"tx_freq"`
+	TXPower     uint8   `json:"tx_power"`
+	ColorCode   uint8   `json:"color_code"`
+	Slots       uint8   `json:"slots"`
+	Latitude    float32 `json:"latitude"`
+	Longitude   float32 `json:"longitude"`
+	Height      uint16  `json:"height"`
+	Location    string  `json:"location"`
+	Description string  `json:"description"`
+	URL         string  `json:"url"`
+	SoftwareID  string  `json:"software_id,omitempty"`
+	PackageID   string  `json:"package_id,omitempty"`
+
+	// Network is the network New listens on: "udp" (the default), "udp4" to
+	// only use IPv4 or "udp6" to only use IPv6. It's not sent to peers.
+	Network string `json:"network,omitempty"`
+}
+
+// LoadRepeaterConfiguration reads a JSON repeater configuration, refusing
+// one with fields that don't fit the configuration frame. The warnings of
+// Validate are logged by New and UpdateConfig.
+func LoadRepeaterConfiguration(r io.Reader) (*RepeaterConfiguration, error) {
+	var config RepeaterConfiguration
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return nil, fmt.Errorf("homebrew: invalid repeater configuration: %v", err)
+	}
+	if err := config.checkFields(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// SaveRepeaterConfiguration writes the repeater configuration as JSON.
+func SaveRepeaterConfiguration(w io.Writer, config *RepeaterConfiguration) error {
+	data, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
 }
 
 // Bytes returns the configuration as bytes.
@@ -46,6 +77,9 @@ func (r *RepeaterConfiguration) String() string {
 	if r.Slots > 4 {
 		r.Slots = 4
 	}
+	if r.Height > 999 {
+		r.Height = 999
+	}
 	if r.SoftwareID == "" {
 		r.SoftwareID = dmr.SoftwareID
 	}
@@ -53,14 +87,7 @@ func (r *RepeaterConfiguration) String() string {
 		r.PackageID = dmr.PackageID
 	}
 
-	var lat = fmt.Sprintf("%-08f", r.Latitude)
-	if len(lat) > 8 {
-		lat = lat[:8]
-	}
-	var lon = fmt.Sprintf("%-09f", r.Longitude)
-	if len(lon) > 9 {
-		lon = lon[:9]
-	}
+	var lat, lon = ConfigProfileDefault.position(r.Latitude, r.Longitude)
 
 	var b = "RPTC"
 	b += fmt.Sprintf("%08x", r.ID)
@@ -81,7 +108,126 @@ func (r *RepeaterConfiguration) String() string {
 	return b
 }
 
+// band is an amateur or land mobile band with the range of duplex splits
+// (offsets between RX and TX frequency) that are plausible for a repeater.
+type band struct {
+	name               string
+	low, high          uint32
+	minSplit, maxSplit uint32
+}
+
+var bands = []band{
+	{"VHF", 136000000, 174000000, 100000, 10000000},
+	{"UHF", 400000000, 480000000, 1000000, 10000000},
+	{"900 MHz", 896000000, 941000000, 12000000, 39000000},
+}
+
+func findBand(freq uint32) *band {
+	for i := range bands {
+		if freq >= bands[i].low && freq <= bands[i].high {
+			return &bands[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks the configuration for fields that don't fit the
+// configuration frame, which are returned as error, and for common mistakes,
+// such as identical RX and TX frequencies or a duplex split that is
+// implausible for the band, which are returned as warnings. Unset (zero)
+// frequencies are not checked.
+func (r *RepeaterConfiguration) Validate() (warnings []error, err error) {
+	if err := r.checkFields(); err != nil {
+		return nil, err
+	}
+
+	switch rx, tx := r.RXFreq, r.TXFreq; {
+	case rx == 0 || tx == 0:
+	case rx == tx:
+		warnings = append(warnings, fmt.Errorf("homebrew: RX and TX frequency are both %d Hz", rx))
+	default:
+		var (
+			rxBand = findBand(rx)
+			split  = rx - tx
+		)
+		if tx > rx {
+			split = tx - rx
+		}
+		switch {
+		case rxBand == nil:
+		case rxBand != findBand(tx):
+			warnings = append(warnings, fmt.Errorf("homebrew: RX frequency %d Hz and TX frequency %d Hz are not in the same band", rx, tx))
+		case split < rxBand.minSplit || split > rxBand.maxSplit:
+			warnings = append(warnings, fmt.Errorf("homebrew: split of %d Hz between RX frequency %d Hz and TX frequency %d Hz is implausible for %s, expected %d-%d Hz",
+				split, rx, tx, rxBand.name, rxBand.minSplit, rxBand.maxSplit))
+		}
+	}
+	return warnings, nil
+}
+
+// checkFields checks the fields against the widths and ranges of the
+// configuration frame, which would otherwise silently truncate or clamp them.
+func (r *RepeaterConfiguration) checkFields() error {
+	var errs []error
+	for _, field := range []struct {
+		name  string
+		value string
+		width int
+	}{
+		{"callsign", r.Callsign, 8},
+		{"location", r.Location, 20},
+		{"description", r.Description, 19},
+		{"URL", r.URL, 124},
+		{"software ID", r.SoftwareID, 40},
+		{"package ID", r.PackageID, 40},
+	} {
+		if len(field.value) > field.width {
+			errs = append(errs, fmt.Errorf("homebrew: %s %q exceeds %d bytes", field.name, field.value, field.width))
+		}
+	}
+
+	for _, field := range []struct {
+		name            string
+		value, min, max uint32
+	}{
+		{"RX frequency", r.RXFreq, 0, 999999999},
+		{"TX frequency", r.TXFreq, 0, 999999999},
+		{"TX power", uint32(r.TXPower), 0, 99},
+		{"color code", uint32(r.ColorCode), 1, 15},
+		{"slots", uint32(r.Slots), 0, 4},
+		{"height", uint32(r.Height), 0, 999},
+	} {
+		switch {
+		case field.value < field.min:
+			errs = append(errs, fmt.Errorf("homebrew: %s %d is below %d", field.name, field.value, field.min))
+		case field.value > field.max:
+			errs = append(errs, fmt.Errorf("homebrew: %s %d exceeds %d", field.name, field.value, field.max))
+		}
+	}
+
+	if !(r.Latitude >= -90 && r.Latitude <= 90) {
+		errs = append(errs, fmt.Errorf("homebrew: latitude %f out of range", r.Latitude))
+	}
+	if !(r.Longitude >= -180 && r.Longitude <= 180) {
+		errs = append(errs, fmt.Errorf("homebrew: longitude %f out of range", r.Longitude))
+	}
+	return errors.Join(errs...)
+}
+
 // ConfigFunc returns an actual RepeaterConfiguration instance when called.
 // This is used by the DMR repeater to poll for current configuration,
 // statistics and metrics.
 type ConfigFunc func() *RepeaterConfiguration
+
+// validateConfig refuses a configuration with fields that don't fit the
+// configuration frame, and logs the warnings of Validate.
+func (h *Homebrew) validateConfig(config *RepeaterConfiguration) error {
+	warnings, err := config.Validate()
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		h.logger.Warningf("%v\n", warning)
+	}
+	return nil
+}
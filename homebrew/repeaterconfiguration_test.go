@@ -0,0 +1,149 @@
+package homebrew
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestValidateFrequencies(t *testing.T) {
+	var tests = []struct {
+		rx, tx uint32
+		valid  bool
+		want   string
+	}{
+		{438800000, 431200000, true, ""},
+		{145600000, 145000000, true, ""},
+		{0, 0, true, ""},
+		{438800000, 438800000, false, "both 438800000 Hz"},
+		{438800000, 438750000, false, "split of 50000 Hz"},
+		{438800000, 145000000, false, "not in the same band"},
+	}
+	for _, test := range tests {
+		config := *testConfig
+		config.RXFreq, config.TXFreq = test.rx, test.tx
+
+		warnings, err := config.Validate()
+		if err != nil {
+			t.Errorf("rx %d, tx %d: expected only warnings, got %v", test.rx, test.tx, err)
+		}
+		if test.valid {
+			if len(warnings) != 0 {
+				t.Errorf("rx %d, tx %d: unexpected warnings: %v", test.rx, test.tx, warnings)
+			}
+			continue
+		}
+		if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), test.want) {
+			t.Errorf("rx %d, tx %d: expected warning containing %q, got %v", test.rx, test.tx, test.want, warnings)
+		}
+	}
+
+	// The warnings are logged through the logger of the instance
+	h := testHomebrew(t)
+	defer h.Close()
+	logger := &testLogger{}
+	h.SetLogger(logger)
+	config := *testConfig
+	config.TXFreq = config.RXFreq
+	if err := h.UpdateConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+	if !logger.contains("warning", "RX and TX frequency are both") {
+		t.Fatalf("expected the warning to be logged, got %v", logger.messages)
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	var tests = []struct {
+		name   string
+		modify func(c *RepeaterConfiguration)
+		want   string
+	}{
+		{"callsign", func(c *RepeaterConfiguration) { c.Callsign = "PD0MZ/P/MM" }, "callsign"},
+		{"location", func(c *RepeaterConfiguration) { c.Location = strings.Repeat("x", 21) }, "location"},
+		{"description", func(c *RepeaterConfiguration) { c.Description = strings.Repeat("x", 20) }, "description"},
+		{"URL", func(c *RepeaterConfiguration) { c.URL = "https://" + strings.Repeat("x", 117) }, "URL"},
+		{"software ID", func(c *RepeaterConfiguration) { c.SoftwareID = strings.Repeat("x", 41) }, "software ID"},
+		{"package ID", func(c *RepeaterConfiguration) { c.PackageID = strings.Repeat("x", 41) }, "package ID"},
+		{"RX frequency", func(c *RepeaterConfiguration) { c.RXFreq = 1000000000 }, "RX frequency"},
+		{"TX frequency", func(c *RepeaterConfiguration) { c.TXFreq = 1000000000 }, "TX frequency"},
+		{"TX power", func(c *RepeaterConfiguration) { c.TXPower = 100 }, "TX power"},
+		{"color code", func(c *RepeaterConfiguration) { c.ColorCode = 16 }, "color code"},
+		{"zero color code", func(c *RepeaterConfiguration) { c.ColorCode = 0 }, "color code 0 is below 1"},
+		{"slots", func(c *RepeaterConfiguration) { c.Slots = 5 }, "slots"},
+		{"height", func(c *RepeaterConfiguration) { c.Height = 1000 }, "height"},
+		{"latitude", func(c *RepeaterConfiguration) { c.Latitude = 90.5 }, "latitude"},
+		{"longitude", func(c *RepeaterConfiguration) { c.Longitude = -180.5 }, "longitude"},
+		{"NaN latitude", func(c *RepeaterConfiguration) { c.Latitude = float32(math.NaN()) }, "latitude"},
+	}
+	for _, test := range tests {
+		config := *testConfig
+		test.modify(&config)
+
+		if _, err := config.Validate(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: expected error containing %q, got %v", test.name, test.want, err)
+		}
+		if _, err := New(&config, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}); err == nil {
+			t.Errorf("%s: expected New to refuse the configuration", test.name)
+		}
+	}
+
+	// At the limits
+	config := *testConfig
+	config.Callsign = "PD0MZ/MM"
+	config.URL = strings.Repeat("x", 124)
+	config.ColorCode, config.TXPower, config.Slots, config.Height = 15, 99, 4, 999
+	config.Latitude, config.Longitude = -90, 180
+	if warnings, err := config.Validate(); err != nil || len(warnings) != 0 {
+		t.Fatalf("expected valid configuration, got %v %v", err, warnings)
+	}
+
+	// All field errors are reported
+	config.Callsign = "PD0MZ/P/MM"
+	config.Slots = 5
+	if _, err := config.Validate(); err == nil || !strings.Contains(err.Error(), "callsign") || !strings.Contains(err.Error(), "slots") {
+		t.Fatalf("expected callsign and slots errors, got %v", err)
+	}
+
+	h := testHomebrew(t)
+	defer h.Close()
+	if err := h.UpdateConfig(&config); err == nil {
+		t.Fatal("expected UpdateConfig to refuse the configuration")
+	}
+}
+
+func TestRepeaterConfigurationJSON(t *testing.T) {
+	var config = *testConfig
+	config.Latitude, config.Longitude = 52.2963, -4.8567
+	config.Height = 12
+	config.Location = "Amsterdam"
+	config.URL = "https://pd0mz.example/"
+	config.Network = "udp4"
+
+	var buf bytes.Buffer
+	if err := SaveRepeaterConfiguration(&buf, &config); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"rx_freq": 438800000`, `"latitude": 52.2963`, `"longitude": -4.8567`, `"network": "udp4"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %s in %s", want, buf.String())
+		}
+	}
+
+	loaded, err := LoadRepeaterConfiguration(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *loaded != config {
+		t.Fatalf("expected %+v, got %+v", config, *loaded)
+	}
+
+	if _, err := LoadRepeaterConfiguration(strings.NewReader("{")); err == nil {
+		t.Fatal("expected error for invalid JSON")
+	}
+	if _, err := LoadRepeaterConfiguration(strings.NewReader(`{"callsign": "PD0MZ", "id": 2042214, "color_code": 16}`)); err == nil || !strings.Contains(err.Error(), "color code") {
+		t.Fatalf("expected color code error, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package homebrew
+
+import "time"
+
+// pendingControl is a control frame sent to an outgoing peer, awaiting its
+// reply.
+type pendingControl struct {
+	data    []byte
+	retries int
+	timer   *time.Timer
+}
+
+// writeControl sends a login, key or configuration frame to the peer. With
+// ControlRetries set, the frame is sent again every
+// Timeouts.ControlRetryInterval until the peer replies, instead of waiting for
+// the keepalive to retry the login.
+func (h *Homebrew) writeControl(b []byte, peer *Peer) error {
+	h.ackControl(peer)
+
+	if h.ControlRetries > 0 {
+		pc := &pendingControl{data: b}
+		h.control.Lock()
+		peer.control = pc
+		pc.timer = time.AfterFunc(h.Timeouts.ControlRetryInterval, func() { h.retryControl(peer, pc) })
+		h.control.Unlock()
+	}
+	return h.WriteToPeer(b, peer)
+}
+
+// retryControl sends the pending control frame again, if the peer still didn't
+// reply to it.
+func (h *Homebrew) retryControl(peer *Peer, pc *pendingControl) {
+	h.control.Lock()
+	if peer.control != pc {
+		h.control.Unlock()
+		return
+	}
+	pc.retries++
+	var retry = pc.retries
+	if retry < h.ControlRetries {
+		pc.timer = time.AfterFunc(h.Timeouts.ControlRetryInterval, func() { h.retryControl(peer, pc) })
+	} else {
+		peer.control = nil
+	}
+	h.control.Unlock()
+
+	h.logger.Debugf("peer %d@%s didn't reply to %q, retry %d\n", peer.ID, peer.Addr, pc.data[:4], retry)
+	if _, err := h.writeTo(pc.data, peer); err != nil {
+		h.logger.Errorf("peer %d@%s retry failed: %v\n", peer.ID, peer.Addr, err)
+	}
+}
+
+// ackControl stops retrying the pending control frame of the peer, if any.
+func (h *Homebrew) ackControl(peer *Peer) {
+	h.control.Lock()
+	defer h.control.Unlock()
+
+	if peer.control != nil {
+		peer.control.timer.Stop()
+		peer.control = nil
+	}
+}
@@ -0,0 +1,64 @@
+package homebrew
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestControlRetries(t *testing.T) {
+	transport := newTestTransport()
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ControlRetries = 3
+	h.Timeouts.ControlRetryInterval = 20 * time.Millisecond
+	done := make(chan error)
+	go func() { done <- h.ListenAndServe() }()
+
+	var (
+		addr     = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 62031}
+		masterID = RepeaterIDBytes(1001)
+		frame    = func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	)
+	expect := func(prefix []byte) {
+		t.Helper()
+		select {
+		case d := <-transport.out:
+			if !bytes.HasPrefix(d.data, prefix) {
+				t.Fatalf("expected %q, got %q", prefix, d.data)
+			}
+		case <-time.After(h.Timeouts.AuthTimeout):
+			t.Fatalf("timeout waiting for %q", prefix)
+		}
+	}
+
+	peer := &Peer{ID: 1001, Addr: addr, AuthKey: []byte("passw0rd")}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+	expect(RepeaterLogin)
+	transport.in <- testDatagram{addr: addr, data: frame(RepeaterACK, []byte{0x01, 0x02, 0x03, 0x04})}
+	expect(RepeaterKey)
+
+	// The ACK of the key is lost, the key is sent again.
+	expect(RepeaterKey)
+	transport.in <- testDatagram{addr: addr, data: frame(RepeaterACK, masterID)}
+	expect(RepeaterConfig)
+	transport.in <- testDatagram{addr: addr, data: frame(RepeaterACK, masterID)}
+
+	// No more retries once the configuration is acknowledged.
+	select {
+	case d := <-transport.out:
+		t.Fatalf("unexpected frame %q", d.data)
+	case <-time.After(5 * h.Timeouts.ControlRetryInterval):
+	}
+
+	h.Close()
+	<-done
+	if peer.Status != AuthDone {
+		t.Fatalf("expected peer to be authenticated, got %s", peer.Status.String())
+	}
+}
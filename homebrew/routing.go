@@ -0,0 +1,272 @@
+package homebrew
+
+import (
+	"sort"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+// route records the peer a subscriber was last heard on, so private calls
+// follow the subscriber when it roams between repeaters.
+type route struct {
+	peer *Peer
+	seen time.Time
+}
+
+// learnRoute records that traffic from the subscriber id arrived via peer.
+func (h *Homebrew) learnRoute(id uint32, peer *Peer, now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if r, ok := h.routes[id]; ok {
+		if r.peer != peer {
+			h.logger.Debugf("subscriber %d moved from peer %d to peer %d\n", id, r.peer.ID, peer.ID)
+		}
+		r.peer = peer
+		r.seen = now
+		return
+	}
+	h.routes[id] = &route{peer: peer, seen: now}
+}
+
+// lookupRoute returns the peer the subscriber id was last heard on, or nil
+// if it's unknown, expired or the peer is no longer linked.
+func (h *Homebrew) lookupRoute(id uint32, now time.Time) *Peer {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	r, ok := h.routes[id]
+	if !ok || now.Sub(r.seen) > h.Timeouts.RouteTimeout {
+		return nil
+	}
+	if h.PeerID[r.peer.ID] != r.peer {
+		return nil
+	}
+	return r.peer
+}
+
+// expireRoutes removes routes not refreshed within Timeouts.RouteTimeout.
+func (h *Homebrew) expireRoutes(now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for id, r := range h.routes {
+		if now.Sub(r.seen) > h.Timeouts.RouteTimeout {
+			h.logger.Debugf("subscriber %d route via peer %d expired\n", id, r.peer.ID)
+			delete(h.routes, id)
+		}
+	}
+}
+
+// expireSubscriptions drops the dynamic talkgroup subscriptions of the peers
+// that didn't transmit on the talkgroup within Timeouts.TGTimeout.
+func (h *Homebrew) expireSubscriptions(now time.Time) {
+	for _, peer := range h.getPeers() {
+		peer.routing.Lock()
+		for ts, tg := range peer.dynamic {
+			if tg == 0 || now.Sub(peer.subscribed[ts]) <= h.Timeouts.TGTimeout {
+				continue
+			}
+			h.logger.Debugf("peer %d@%s unsubscribed from TG %d on TS%d\n", peer.ID, peer.Addr, tg, ts+1)
+			peer.dynamic[ts] = 0
+			peer.subscribed[ts] = time.Time{}
+			if peer.tgTimeslot == uint8(ts) {
+				// Fall back to the subscription on the other timeslot
+				peer.tgTimeslot ^= 0x01
+				peer.tgID = peer.dynamic[peer.tgTimeslot]
+			}
+		}
+		peer.routing.Unlock()
+	}
+}
+
+// AnyTimeslot is the wildcard timeslot of a talkgroup route, matching group
+// calls on both timeslots.
+const AnyTimeslot uint8 = 0xff
+
+// tgRoute is the key of a talkgroup route.
+type tgRoute struct {
+	tgID     uint32
+	timeslot uint8
+}
+
+// AddTGRoute routes group calls to the talkgroup on the timeslot (0 for TS1,
+// 1 for TS2) to the peers, regardless of their subscriptions. Routes for
+// AnyTimeslot apply to both timeslots, unless a route for the specific
+// timeslot exists.
+func (h *Homebrew) AddTGRoute(tgID uint32, timeslot uint8, peerIDs ...uint32) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var key = tgRoute{tgID, timeslot}
+	h.tgRoutes[key] = append(h.tgRoutes[key], peerIDs...)
+}
+
+// RemoveTGRoute removes the route for the talkgroup on the timeslot.
+func (h *Homebrew) RemoveTGRoute(tgID uint32, timeslot uint8) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.tgRoutes, tgRoute{tgID, timeslot})
+}
+
+// routedPeers returns the IDs of the peers the talkgroup on the timeslot is
+// routed to, preferring a route for the timeslot over the wildcard route.
+func (h *Homebrew) routedPeers(tgID uint32, timeslot uint8) map[uint32]bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	ids, ok := h.tgRoutes[tgRoute{tgID, timeslot}]
+	if !ok {
+		ids = h.tgRoutes[tgRoute{tgID, AnyTimeslot}]
+	}
+	var routed = make(map[uint32]bool, len(ids))
+	for _, id := range ids {
+		routed[id] = true
+	}
+	return routed
+}
+
+// SendRouted sends a group call packet received from peer only to the peers
+// it is routed to with AddTGRoute.
+func (h *Homebrew) SendRouted(p *dmr.Packet, peer *Peer) error {
+	if err := h.canTransmit(); err != nil {
+		return err
+	}
+	if h.Paused() {
+		return nil
+	}
+	if p.CallType != dmr.CallTypeGroup {
+		return nil
+	}
+
+	data, err := buildData(p, h.Config.ID)
+	if err != nil {
+		return err
+	}
+	for id := range h.routedPeers(p.DstID, p.Timeslot) {
+		toPeer := h.getPeer(id)
+		if toPeer == nil || toPeer == peer || !toPeer.done() || !toPeer.Accepts(p) {
+			continue
+		}
+		if err := h.writeData(data, toPeer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RoutingSnapshot is the effective routing, for diagnostics, see DumpRouting.
+type RoutingSnapshot struct {
+	TGRoutes    []TGRouteSnapshot         `json:"tg_routes"`
+	Subscribers []SubscriberRouteSnapshot `json:"subscribers"`
+	Peers       []PeerRouting             `json:"peers"`
+}
+
+// TGRouteSnapshot is a talkgroup route added with AddTGRoute.
+type TGRouteSnapshot struct {
+	TGID     uint32   `json:"tgid"`
+	Timeslot uint8    `json:"timeslot"` // AnyTimeslot for both
+	PeerIDs  []uint32 `json:"peer_ids"`
+}
+
+// SubscriberRouteSnapshot is the peer a subscriber was last heard on, private
+// calls to the subscriber are routed there.
+type SubscriberRouteSnapshot struct {
+	ID     uint32    `json:"id"`
+	PeerID uint32    `json:"peer_id"`
+	Seen   time.Time `json:"seen"`
+}
+
+// PeerRouting holds the subscriptions and forwarding options of a peer.
+type PeerRouting struct {
+	ID           uint32           `json:"id"`
+	Status       string           `json:"status"`
+	TGSubscribed time.Time        `json:"tg_subscribed"`
+	Dynamic      [2]uint32        `json:"dynamic"`          // Per timeslot
+	Static       []TGSubscription `json:"static,omitempty"` // See Peer.Static
+	Forward      uint8            `json:"forward"`
+	Hangtime     [2]bool          `json:"hangtime"` // Per timeslot
+}
+
+// TGSubscription is a static subscription of a peer to a talkgroup on a
+// timeslot.
+type TGSubscription struct {
+	TGID     uint32 `json:"tgid"`
+	Timeslot uint8  `json:"timeslot"` // AnyTimeslot for both
+}
+
+// DumpRouting returns the static talkgroup routes, the subscriber routes and
+// the subscriptions and hangtime state of the peers, sorted by ID. It's safe
+// to call from the callbacks.
+func (h *Homebrew) DumpRouting() RoutingSnapshot {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var (
+		snapshot RoutingSnapshot
+		now      = time.Now()
+	)
+	for key, ids := range h.tgRoutes {
+		snapshot.TGRoutes = append(snapshot.TGRoutes, TGRouteSnapshot{
+			TGID:     key.tgID,
+			Timeslot: key.timeslot,
+			PeerIDs:  append([]uint32(nil), ids...),
+		})
+	}
+	sort.Slice(snapshot.TGRoutes, func(i, j int) bool {
+		a, b := snapshot.TGRoutes[i], snapshot.TGRoutes[j]
+		if a.TGID != b.TGID {
+			return a.TGID < b.TGID
+		}
+		return a.Timeslot < b.Timeslot
+	})
+
+	for id, r := range h.routes {
+		if now.Sub(r.seen) > h.Timeouts.RouteTimeout {
+			continue
+		}
+		snapshot.Subscribers = append(snapshot.Subscribers, SubscriberRouteSnapshot{ID: id, PeerID: r.peer.ID, Seen: r.seen})
+	}
+	sort.Slice(snapshot.Subscribers, func(i, j int) bool { return snapshot.Subscribers[i].ID < snapshot.Subscribers[j].ID })
+
+	for _, peer := range h.PeerID {
+		status, _ := peer.session()
+		peer.routing.Lock()
+		snapshot.Peers = append(snapshot.Peers, PeerRouting{
+			ID:           peer.ID,
+			Status:       status.String(),
+			TGSubscribed: peer.Last.TGSubscribed,
+			Dynamic:      peer.dynamic,
+			Static:       staticRoutes(peer),
+			Forward:      peer.Forward,
+			Hangtime:     [2]bool{peer.slot[0].hangtime(now, h.Timeouts.StreamTimeout), peer.slot[1].hangtime(now, h.Timeouts.StreamTimeout)},
+		})
+		peer.routing.Unlock()
+	}
+	sort.Slice(snapshot.Peers, func(i, j int) bool { return snapshot.Peers[i].ID < snapshot.Peers[j].ID })
+
+	return snapshot
+}
+
+// staticRoutes returns the static subscriptions per timeslot of the peer,
+// sorted by talkgroup.
+func staticRoutes(peer *Peer) []TGSubscription {
+	var subscriptions []TGSubscription
+	for timeslot, tgs := range peer.Static {
+		for tg, subscribed := range tgs {
+			if subscribed {
+				subscriptions = append(subscriptions, TGSubscription{TGID: tg, Timeslot: timeslot})
+			}
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		a, b := subscriptions[i], subscriptions[j]
+		if a.TGID != b.TGID {
+			return a.TGID < b.TGID
+		}
+		return a.Timeslot < b.Timeslot
+	})
+	return subscriptions
+}
@@ -0,0 +1,287 @@
+package homebrew
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestPrivateCallFollowsSubscriber(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peerA, remoteA := testIncomingPeer(t, h, 1001)
+	peerB, remoteB := testIncomingPeer(t, h, 1002)
+	peerC, remoteC := testIncomingPeer(t, h, 1003)
+
+	// Subscriber 2001 is heard on A, then roams to B.
+	if err := h.handlePacket(testPacket(2001, 3002, dmr.CallTypePrivate), peerA); err != nil {
+		t.Fatal(err)
+	}
+	roamed := testPacket(2001, 3002, dmr.CallTypePrivate)
+	roamed.StreamID++
+	if err := h.handlePacket(roamed, peerB); err != nil {
+		t.Fatal(err)
+	}
+
+	// 3002 wasn't heard, so these calls were sent to all other peers.
+	for _, remote := range []*net.UDPConn{remoteA, remoteB, remoteC} {
+		readFrames(t, remote, 50*time.Millisecond)
+	}
+
+	// Subscriber 2002 on C calls 2001.
+	if err := h.handlePacket(testPacket(2002, 2001, dmr.CallTypePrivate), peerC); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFrames(t, remoteA, 50*time.Millisecond); len(got) != 0 {
+		t.Fatalf("expected no frames on the old repeater, got %d", len(got))
+	}
+	if got := readFrames(t, remoteC, 50*time.Millisecond); len(got) != 0 {
+		t.Fatalf("expected no frames on the originating repeater, got %d", len(got))
+	}
+	got := readFrames(t, remoteB, 50*time.Millisecond)
+	if len(got) != 1 || got[0].DstID != 2001 || got[0].SrcID != 2002 {
+		t.Fatalf("expected private call routed to the new repeater, got %v", got)
+	}
+
+	// Stale routes are expired.
+	h.expireRoutes(time.Now().Add(h.Timeouts.RouteTimeout * 2))
+	if peer := h.lookupRoute(2001, time.Now()); peer != nil {
+		t.Fatalf("expected route to expire, got peer %d", peer.ID)
+	}
+}
+
+func TestTGRoutesPerTimeslot(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+	ts1, ts1Conn := testIncomingPeer(t, h, 1002)
+	ts2, ts2Conn := testIncomingPeer(t, h, 1003)
+	wild, wildConn := testIncomingPeer(t, h, 1004)
+
+	h.AddTGRoute(3100, 0, ts1.ID)
+	h.AddTGRoute(3100, 1, ts2.ID)
+	h.AddTGRoute(3200, AnyTimeslot, wild.ID)
+
+	var tests = []struct {
+		tg       uint32
+		timeslot uint8
+		want     *net.UDPConn
+	}{
+		{3100, 0, ts1Conn},
+		{3100, 1, ts2Conn},
+		{3200, 0, wildConn},
+		{3200, 1, wildConn},
+	}
+	for _, send := range []func(*dmr.Packet, *Peer) error{h.SendTG, h.SendRouted} {
+		for _, test := range tests {
+			p := testPacket(2001, test.tg, dmr.CallTypeGroup)
+			p.Timeslot = test.timeslot
+			if err := send(p, origin); err != nil {
+				t.Fatal(err)
+			}
+			for _, conn := range []*net.UDPConn{ts1Conn, ts2Conn, wildConn} {
+				got := readFrames(t, conn, time.Millisecond*50)
+				if conn == test.want && (len(got) != 1 || got[0].Timeslot != test.timeslot) {
+					t.Errorf("TG %d TS%d: expected frame on the routed peer, got %v", test.tg, test.timeslot+1, got)
+				}
+				if conn != test.want && len(got) != 0 {
+					t.Errorf("TG %d TS%d: expected no frames on other peers, got %v", test.tg, test.timeslot+1, got)
+				}
+			}
+		}
+	}
+
+	// A route for the timeslot takes precedence over the wildcard.
+	h.AddTGRoute(3200, 1, ts2.ID)
+	p := testPacket(2001, 3200, dmr.CallTypeGroup)
+	p.Timeslot = 1
+	if err := h.SendRouted(p, origin); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrames(t, ts2Conn, time.Millisecond*50); len(got) != 1 {
+		t.Fatalf("expected frame on TS2 route, got %v", got)
+	}
+	if got := readFrames(t, wildConn, time.Millisecond*50); len(got) != 0 {
+		t.Fatalf("expected no frame on wildcard route, got %v", got)
+	}
+
+	h.RemoveTGRoute(3100, 0)
+	if routed := h.routedPeers(3100, 0); len(routed) != 0 {
+		t.Fatalf("expected route to be removed, got %v", routed)
+	}
+}
+
+func TestDumpRouting(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+	other, _ := testIncomingPeer(t, h, 1002)
+	other.Static = map[uint8]map[uint32]bool{AnyTimeslot: {9: true}}
+	h.AddTGRoute(3100, 1, other.ID)
+
+	// A transmission subscribes the peer to the talkgroup dynamically.
+	h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), origin)
+
+	snapshot := h.DumpRouting()
+	if len(snapshot.TGRoutes) != 1 {
+		t.Fatalf("expected 1 talkgroup route, got %+v", snapshot.TGRoutes)
+	}
+	if r := snapshot.TGRoutes[0]; r.TGID != 3100 || r.Timeslot != 1 || len(r.PeerIDs) != 1 || r.PeerIDs[0] != other.ID {
+		t.Fatalf("unexpected talkgroup route %+v", r)
+	}
+	if len(snapshot.Subscribers) != 1 || snapshot.Subscribers[0].ID != 2001 || snapshot.Subscribers[0].PeerID != origin.ID {
+		t.Fatalf("unexpected subscriber routes %+v", snapshot.Subscribers)
+	}
+	if len(snapshot.Peers) != 2 {
+		t.Fatalf("expected 2 peers, got %+v", snapshot.Peers)
+	}
+	if p := snapshot.Peers[0]; p.ID != origin.ID || p.Dynamic != [2]uint32{91, 0} || p.TGSubscribed.IsZero() || p.Status != "done" {
+		t.Fatalf("expected dynamic subscription to TG 91, got %+v", p)
+	}
+	if p := snapshot.Peers[1]; p.ID != other.ID || len(p.Static) != 1 || p.Static[0] != (TGSubscription{9, AnyTimeslot}) {
+		t.Fatalf("expected static talkgroup 9, got %+v", p)
+	}
+
+	if _, err := json.Marshal(snapshot); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDumpRoutingFromCallback(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	var snapshot RoutingSnapshot
+	h.OnStreamEnd = func(p *dmr.Packet, _ time.Duration) {
+		snapshot = h.DumpRouting()
+	}
+
+	terminator := testPacket(2001, 91, dmr.CallTypeGroup)
+	terminator.DataType = dmr.TerminatorWithLC
+	done := make(chan error, 1)
+	go func() { done <- h.handlePacket(terminator, peer) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DumpRouting from OnStreamEnd blocked")
+	}
+	if len(snapshot.Peers) != 1 || snapshot.Peers[0].ID != peer.ID {
+		t.Fatalf("expected routing of peer 1001, got %+v", snapshot.Peers)
+	}
+}
+
+func TestSubscriptionExpiry(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+	peer, remote := testIncomingPeer(t, h, 1002)
+
+	// The peer subscribes with a group call on TS1, and later on TS2.
+	p := testPacket(2002, 91, dmr.CallTypeGroup)
+	p.DataType = dmr.TerminatorWithLC
+	if err := h.handlePacket(p, peer); err != nil {
+		t.Fatal(err)
+	}
+	peer.subscribed[0] = time.Now().Add(-h.Timeouts.TGTimeout / 2)
+	p = testPacket(2002, 92, dmr.CallTypeGroup)
+	p.Timeslot = 1
+	p.DataType = dmr.TerminatorWithLC
+	if err := h.handlePacket(p, peer); err != nil {
+		t.Fatal(err)
+	}
+
+	// TS1 expires first, and no longer receives the talkgroup.
+	h.housekeeping(time.Now().Add(h.Timeouts.TGTimeout/2 + time.Second))
+	if peer.Subscribed(91, 0) || !peer.Subscribed(92, 1) || peer.TGID() != 92 {
+		t.Fatalf("expected TS1 subscription to expire, got %v", peer.dynamic)
+	}
+	p = testPacket(2001, 91, dmr.CallTypeGroup)
+	if err := h.handlePacket(p, origin); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrames(t, remote, 50*time.Millisecond); len(got) != 0 {
+		t.Fatalf("expected no frames after expiry, got %d", len(got))
+	}
+
+	h.housekeeping(time.Now().Add(h.Timeouts.TGTimeout + time.Second))
+	if peer.Subscribed(92, 1) || peer.TGID() != 0 {
+		t.Fatalf("expected all subscriptions to expire, got %v", peer.dynamic)
+	}
+}
+
+func TestSendPrivate(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peerA, remoteA := testIncomingPeer(t, h, 1001)
+	_, remoteB := testIncomingPeer(t, h, 1002)
+
+	// Unknown destinations are sent to all peers.
+	if err := h.SendPrivate(testPacket(1, 2001, dmr.CallTypePrivate)); err != nil {
+		t.Fatal(err)
+	}
+	for _, remote := range []*net.UDPConn{remoteA, remoteB} {
+		if got := readFrames(t, remote, 50*time.Millisecond); len(got) != 1 || got[0].DstID != 2001 {
+			t.Fatalf("expected private call to unknown destination on all peers, got %v", got)
+		}
+	}
+
+	// Known destinations only to the peer they were heard on.
+	p := testPacket(2001, 91, dmr.CallTypeGroup)
+	p.DataType = dmr.TerminatorWithLC
+	if err := h.handlePacket(p, peerA); err != nil {
+		t.Fatal(err)
+	}
+	readFrames(t, remoteB, 50*time.Millisecond)
+
+	if err := h.SendPrivate(testPacket(2, 2001, dmr.CallTypePrivate)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrames(t, remoteA, 50*time.Millisecond); len(got) != 1 || got[0].SrcID != 2 {
+		t.Fatalf("expected private call routed to peer A, got %v", got)
+	}
+	if got := readFrames(t, remoteB, 50*time.Millisecond); len(got) != 0 {
+		t.Fatalf("expected no private call on peer B, got %v", got)
+	}
+}
+
+func TestSendPrivateFromPacketFunc(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peerA, remoteA := testIncomingPeer(t, h, 1001)
+
+	// The PacketFunc runs while the frame is handled, replying from it must
+	// not block.
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		return h.SendPrivate(testPacket(p.DstID, p.SrcID, dmr.CallTypePrivate))
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- h.handlePacket(testPacket(2001, 3001, dmr.CallTypePrivate), peerA) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SendPrivate from the PacketFunc blocked")
+	}
+	// The caller was heard on peer A, the reply is routed there.
+	if got := readFrames(t, remoteA, 50*time.Millisecond); len(got) != 1 || got[0].SrcID != 3001 || got[0].DstID != 2001 {
+		t.Fatalf("expected reply on peer A, got %v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package homebrew
+
+import "time"
+
+// sendQueue holds the DMR data frames waiting to be written to a peer, so a
+// slow peer doesn't hold up the others, see PeerQueueSize.
+type sendQueue struct {
+	frames chan []byte
+}
+
+// writeData writes a DMR data frame to the peer, through its send queue if
+// PeerQueueSize is set. Frames are dropped when the queue is full.
+func (h *Homebrew) writeData(data []byte, peer *Peer) error {
+	if h.PeerQueueSize <= 0 || peer == nil {
+		return h.WriteToPeer(data, peer)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	if peer.queue == nil {
+		peer.queue = &sendQueue{frames: make(chan []byte, h.PeerQueueSize)}
+		h.workers.Add(1)
+		go h.drain(peer, peer.queue)
+	}
+	select {
+	case peer.queue.frames <- data:
+		peer.counters.Lock()
+		peer.Last.PacketSent = time.Now()
+		peer.counters.Unlock()
+	default:
+		peer.count(&peer.Counters.DroppedFrames)
+		h.logger.Debugf("peer %d@%s send queue full, frame dropped\n", peer.ID, peer.Addr)
+	}
+	return nil
+}
+
+// drain writes the queued frames to the peer, until the queue is stopped.
+func (h *Homebrew) drain(peer *Peer, q *sendQueue) {
+	defer h.workers.Done()
+	for data := range q.frames {
+		if _, err := h.writeTo(data, peer); err != nil {
+			h.logger.Errorf("peer %d@%s write failed: %v\n", peer.ID, peer.Addr, err)
+			continue
+		}
+		peer.sent(len(data))
+	}
+}
+
+// stopQueue stops the send queue of the peer, must be called with the mutex
+// held.
+func (h *Homebrew) stopQueue(peer *Peer) {
+	if peer.queue != nil {
+		close(peer.queue.frames)
+		peer.queue = nil
+	}
+}
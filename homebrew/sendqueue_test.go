@@ -0,0 +1,135 @@
+package homebrew
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestPeerQueues(t *testing.T) {
+	var (
+		slow = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 62031}
+		fast = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 62032}
+	)
+	transport := &stallTransport{testTransport: newTestTransport(), stalled: slow, release: make(chan struct{})}
+	transport.out = make(chan testDatagram, 64)
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.PeerQueueSize = 4
+
+	var once sync.Once
+	unstall := func() { once.Do(func() { close(transport.release) }) }
+	defer unstall()
+
+	var peers []*Peer
+	for i, addr := range []*net.UDPAddr{slow, fast} {
+		peer := &Peer{ID: uint32(1001 + i), Addr: addr, AuthKey: []byte("passw0rd"), Incoming: true}
+		if err := h.Link(peer); err != nil {
+			t.Fatal(err)
+		}
+		peer.Status = AuthDone
+		peers = append(peers, peer)
+	}
+
+	const frames = 20
+	start := time.Now()
+	for i := 0; i < frames; i++ {
+		if err := h.SendSync(testPacket(2001, 91, dmr.CallTypeGroup)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected Send not to block on the stalled peer, took %s", elapsed)
+	}
+
+	for i := 0; i < frames; i++ {
+		select {
+		case d := <-transport.out:
+			if d.addr.String() != fast.String() {
+				t.Fatalf("expected frame for the fast peer, got %s", d.addr)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d frames on the fast peer, got %d", frames, i)
+		}
+	}
+
+	// The stalled peer holds at most a full queue and the frame being written.
+	if dropped := peers[0].SnapshotCounters().DroppedFrames; dropped < uint64(frames-h.PeerQueueSize-1) {
+		t.Fatalf("expected at least %d dropped frames on the stalled peer, got %d", frames-h.PeerQueueSize-1, dropped)
+	}
+	if dropped := peers[1].SnapshotCounters().DroppedFrames; dropped != 0 {
+		t.Fatalf("expected no dropped frames on the fast peer, got %d", dropped)
+	}
+
+	unstall()
+	for i := 0; i < h.PeerQueueSize; i++ {
+		select {
+		case d := <-transport.out:
+			if d.addr.String() != slow.String() {
+				t.Fatalf("expected queued frame for the stalled peer, got %s", d.addr)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected queued frames on the stalled peer after release, got %d", i)
+		}
+	}
+}
+
+func TestCloseStopsPeerWriters(t *testing.T) {
+	var addr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 62031}
+	transport := &stallTransport{testTransport: newTestTransport(), stalled: addr, release: make(chan struct{})}
+	h, err := NewWithTransport(testConfig, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.PeerQueueSize = 4
+	h.ControlRetries = 3
+	h.Timeouts.ControlRetryInterval = 20 * time.Millisecond
+
+	incoming := &Peer{ID: 1001, Addr: addr, AuthKey: []byte("passw0rd"), Incoming: true}
+	if err := h.Link(incoming); err != nil {
+		t.Fatal(err)
+	}
+	outgoing := &Peer{ID: 1002, Addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 62032}, AuthKey: []byte("passw0rd")}
+	if err := h.Link(outgoing); err != nil {
+		t.Fatal(err)
+	}
+	if d := <-transport.out; !bytes.HasPrefix(d.data, RepeaterLogin) {
+		t.Fatalf("expected login, got %q", d.data)
+	}
+
+	// The queued frame is written before the socket is closed.
+	if err := h.writeData(testData(t, testPacket(2001, 91, dmr.CallTypeGroup), 1001), incoming); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(transport.release)
+	}()
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case d := <-transport.out:
+		if !bytes.HasPrefix(d.data, DMRData) {
+			t.Fatalf("expected queued frame, got %q", d.data)
+		}
+	default:
+		t.Fatal("expected the queued frame to be written before Close returned")
+	}
+
+	// The login isn't retried.
+	h.control.Lock()
+	pending := outgoing.control
+	h.control.Unlock()
+	if pending != nil {
+		t.Fatal("expected the control retries to be stopped")
+	}
+}
@@ -0,0 +1,32 @@
+package homebrew
+
+import "bytes"
+
+// Master software, as detected from the login replies of a master.
+const (
+	SoftwareUnknown      = ""
+	SoftwareBrandMeister = "BrandMeister"
+	SoftwareDMRPlus      = "DMR+"
+	SoftwareHBlink       = "HBlink"
+)
+
+// detectSoftware makes a best-effort guess at the software of a master from
+// the quirks of the frame accepting our login:
+//
+//	repeater ID as 8 hex digits    BrandMeister
+//	login accepted with MSTACK     DMR+, as in the DL5DI specification
+//	login accepted with RPTACK     HBlink, as in the MMDVM specification
+//
+// XLX and other MMDVM style masters can't be told apart from HBlink.
+func detectSoftware(data []byte) string {
+	switch {
+	case len(data) == 14 && ParseRepeaterIDBytes(data[6:14]) != 0:
+		return SoftwareBrandMeister
+	case len(data) == 10 && bytes.Equal(data[:6], MasterACK):
+		return SoftwareDMRPlus
+	case len(data) == 10 && bytes.Equal(data[:6], RepeaterACK):
+		return SoftwareHBlink
+	default:
+		return SoftwareUnknown
+	}
+}
@@ -0,0 +1,47 @@
+package homebrew
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestDetectSoftware(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	remote := testRemote(t)
+	defer remote.Close()
+
+	peer := &Peer{
+		ID:      1234,
+		Addr:    remote.LocalAddr().(*net.UDPAddr),
+		AuthKey: []byte("passw0rd"),
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+
+	var hexID = []byte(fmt.Sprintf("%08x", testConfig.ID))
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{append(append([]byte{}, RepeaterACK...), hexID...), SoftwareBrandMeister},
+		{append(append([]byte{}, MasterACK...), RepeaterIDBytes(testConfig.ID)...), SoftwareDMRPlus},
+		{append(append([]byte{}, RepeaterACK...), RepeaterIDBytes(testConfig.ID)...), SoftwareHBlink},
+	}
+	for _, test := range tests {
+		peer.Status = AuthBegin
+		peer.RemoteSoftware = SoftwareUnknown
+		if err := h.handle(peer.Addr, test.data); err != nil {
+			t.Fatal(err)
+		}
+		if peer.Status != AuthDone {
+			t.Fatalf("%q: expected login to be accepted", test.data)
+		}
+		if peer.RemoteSoftware != test.want {
+			t.Errorf("%q: got software %q, want %q", test.data, peer.RemoteSoftware, test.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package homebrew
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+// Counters holds the traffic counters of a peer.
+type Counters struct {
+	// RejectedStreams counts streams dropped because another stream was
+	// already active on the same timeslot.
+	RejectedStreams uint64
+
+	// CutOffStreams counts streams cut off for exceeding MaxStreamDuration.
+	CutOffStreams uint64
+
+	// RejectedFrames counts frames dropped for invalid addressing, see
+	// InvalidFramePolicy.
+	RejectedFrames uint64
+
+	// SpoofedFrames counts frames of an incoming peer dropped for carrying
+	// another repeater ID, see EnforceRepeaterID.
+	SpoofedFrames uint64
+
+	// DeniedFrames counts frames dropped for their source or destination,
+	// see Peer.Permits.
+	DeniedFrames uint64
+
+	// LoopedFrames counts our own frames received back from the peer.
+	LoopedFrames uint64
+
+	// DuplicateFrames counts frames of streams already received from another
+	// peer, see DedupWindow.
+	DuplicateFrames uint64
+
+	// CorruptFrames counts frames failing the FEC or CRC checks, see
+	// VerifyFEC, and DroppedCorruptFrames those of them dropped, see
+	// DropCorrupt.
+	CorruptFrames        uint64
+	DroppedCorruptFrames uint64
+
+	// SuppressedIdle counts idle frames not forwarded, see SuppressIdle.
+	SuppressedIdle uint64
+
+	// DroppedFrames counts frames dropped because the send queue of the peer
+	// was full, see PeerQueueSize.
+	DroppedFrames uint64
+
+	// MalformedFrames counts talker alias frames dropped for failing to
+	// parse.
+	MalformedFrames uint64
+}
+
+// count increments one of the counters of the peer.
+func (p *Peer) count(counter *uint64) {
+	p.counters.Lock()
+	*counter++
+	p.counters.Unlock()
+}
+
+// SnapshotCounters returns the counters of the peer and resets them, so
+// interval based reporting doesn't count the same traffic twice.
+func (p *Peer) SnapshotCounters() Counters {
+	p.counters.Lock()
+	defer p.counters.Unlock()
+
+	c := p.Counters
+	p.Counters = Counters{}
+	return c
+}
+
+// SnapshotCounters returns the counters of all peers by peer ID, and resets
+// them, see Peer.SnapshotCounters.
+func (h *Homebrew) SnapshotCounters() map[uint32]Counters {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var counters = make(map[uint32]Counters, len(h.PeerID))
+	for id, peer := range h.PeerID {
+		counters[id] = peer.SnapshotCounters()
+	}
+	return counters
+}
+
+// ResetStats zeroes the counters and traffic statistics of the peer, the
+// session is left alone.
+func (p *Peer) ResetStats() {
+	p.counters.Lock()
+	defer p.counters.Unlock()
+
+	p.Counters = Counters{}
+	p.traffic = traffic{}
+}
+
+// ResetPeerStats zeroes the counters of a peer and drops its transmissions
+// from the last heard list, without disconnecting it.
+func (h *Homebrew) ResetPeerStats(id uint32) error {
+	peer := h.getPeer(id)
+	if peer == nil {
+		return fmt.Errorf("homebrew: peer %d not linked", id)
+	}
+	peer.ResetStats()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var heard = h.heard[:0]
+	for _, e := range h.heard {
+		if e.PeerID != id {
+			heard = append(heard, e)
+		}
+	}
+	h.heard = heard
+	return nil
+}
+
+// berWeight is the weight of a new sample in the rolling average BER.
+const berWeight = 0.1
+
+// rttWeight is the weight of a new sample in the smoothed RTT, as used for
+// TCP, see RFC 6298.
+const rttWeight = 0.125
+
+// PeerStats is a snapshot of the traffic of a peer, see Homebrew.Stats.
+type PeerStats struct {
+	RXPackets uint64 // DMR data frames received
+	TXPackets uint64 // Frames sent
+	RXBytes   uint64
+	TXBytes   uint64
+
+	// Quality of the last voice frame, and the rolling average BER
+	LastBER    float64 // Percent
+	LastRSSI   int     // dBm
+	AverageBER float64 // Percent
+
+	// Uptime is the time since the peer last completed its login
+	Uptime time.Duration
+
+	// Round-trip time of the last ping and its smoothed average, see Peer.RTT
+	RTT         time.Duration
+	SmoothedRTT time.Duration
+}
+
+// traffic holds the running traffic statistics of a peer.
+type traffic struct {
+	rxPackets, txPackets uint64
+	rxBytes, txBytes     uint64
+	lastBER, averageBER  float64
+	lastRSSI             int
+	berSamples           uint64
+}
+
+// received accounts a DMR data frame received from the peer.
+func (p *Peer) received(n int) {
+	p.counters.Lock()
+	p.traffic.rxPackets++
+	p.traffic.rxBytes += uint64(n)
+	p.counters.Unlock()
+}
+
+// sent accounts a frame sent to the peer.
+func (p *Peer) sent(n int) {
+	p.counters.Lock()
+	p.traffic.txPackets++
+	p.traffic.txBytes += uint64(n)
+	p.counters.Unlock()
+}
+
+// quality records the BER and RSSI of a voice frame received from the peer.
+func (p *Peer) quality(packet *dmr.Packet) {
+	p.counters.Lock()
+	defer p.counters.Unlock()
+
+	t := &p.traffic
+	t.lastBER = packet.BERPercent()
+	t.lastRSSI = packet.RSSIdBm()
+	if t.berSamples == 0 {
+		t.averageBER = t.lastBER
+	} else {
+		t.averageBER += (t.lastBER - t.averageBER) * berWeight
+	}
+	t.berSamples++
+}
+
+// pong records a pong received from the peer. If it answers our outstanding
+// ping, the round-trip time is measured.
+func (p *Peer) pong(now time.Time) {
+	if !p.Last.PingSent.IsZero() && p.Last.PingSent.After(p.Last.PongReceived) {
+		rtt := now.Sub(p.Last.PingSent)
+
+		p.counters.Lock()
+		p.RTT = rtt
+		if p.SmoothedRTT == 0 {
+			p.SmoothedRTT = rtt
+		} else {
+			p.SmoothedRTT += time.Duration(float64(rtt-p.SmoothedRTT) * rttWeight)
+		}
+		p.counters.Unlock()
+	}
+	p.Last.PongReceived = now
+}
+
+// Stats returns a snapshot of the traffic statistics of all peers by peer ID.
+func (h *Homebrew) Stats() map[uint32]PeerStats {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var (
+		stats = make(map[uint32]PeerStats, len(h.PeerID))
+		now   = time.Now()
+	)
+	for id, peer := range h.PeerID {
+		peer.counters.Lock()
+		t := peer.traffic
+		rtt, srtt := peer.RTT, peer.SmoothedRTT
+		status, connected := peer.Status, peer.Last.Connected
+		peer.counters.Unlock()
+
+		var uptime time.Duration
+		if status == AuthDone && !connected.IsZero() {
+			uptime = now.Sub(connected)
+		}
+		stats[id] = PeerStats{
+			RXPackets:  t.rxPackets,
+			TXPackets:  t.txPackets,
+			RXBytes:    t.rxBytes,
+			TXBytes:    t.txBytes,
+			LastBER:    t.lastBER,
+			LastRSSI:   t.lastRSSI,
+			AverageBER: t.averageBER,
+			Uptime:     uptime,
+
+			RTT:         rtt,
+			SmoothedRTT: srtt,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,224 @@
+package homebrew
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestFrameQuality(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	type quality struct {
+		ber  float64
+		rssi int
+	}
+	var got []quality
+	h.OnFrameQuality = func(peer *Peer, p *dmr.Packet) {
+		got = append(got, quality{p.BERPercent(), p.RSSIdBm()})
+	}
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	header := testPacket(2001, 91, dmr.CallTypeGroup)
+	header.DataType = dmr.VoiceLC
+	voice := testPacket(2001, 91, dmr.CallTypeGroup)
+	for _, p := range []*dmr.Packet{header, voice} {
+		data := testData(t, p, peer.ID)
+		data[53] = 0x07 // 7 bit errors
+		data[54] = 0x4b // -75 dBm
+		if err := h.handle(peer.Addr, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected quality of 1 voice frame, got %d", len(got))
+	}
+	if got[0].ber < 4.96 || got[0].ber > 4.97 || got[0].rssi != -75 {
+		t.Fatalf("unexpected quality %+v", got[0])
+	}
+}
+
+func TestSnapshotCounters(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	loop := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := h.handle(peer.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), testConfig.ID)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	loop(3)
+	if c := peer.SnapshotCounters(); c.LoopedFrames != 3 {
+		t.Fatalf("expected 3 looped frames, got %d", c.LoopedFrames)
+	}
+	if c := peer.SnapshotCounters(); c != (Counters{}) {
+		t.Fatalf("expected counters to be reset, got %+v", c)
+	}
+
+	loop(2)
+	counters := h.SnapshotCounters()
+	if c := counters[peer.ID]; c.LoopedFrames != 2 {
+		t.Fatalf("expected 2 looped frames after reset, got %d", c.LoopedFrames)
+	}
+	if peer.Counters.LoopedFrames != 0 {
+		t.Fatalf("expected counters to be reset, got %d", peer.Counters.LoopedFrames)
+	}
+}
+
+func TestResetPeerStats(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	other, _ := testIncomingPeer(t, h, 1002)
+	peer.Subscribe(91, 0)
+
+	h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), peer)
+	h.handlePacket(testPacket(2002, 92, dmr.CallTypeGroup), other)
+	if err := h.handle(peer.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), testConfig.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if peer.Counters.LoopedFrames != 1 || len(h.LastHeard(10)) != 2 {
+		t.Fatal("expected counters and last heard entries before reset")
+	}
+
+	if err := h.ResetPeerStats(peer.ID); err != nil {
+		t.Fatal(err)
+	}
+	if peer.Counters != (Counters{}) {
+		t.Fatalf("expected counters to be reset, got %+v", peer.Counters)
+	}
+	if heard := h.LastHeard(10); len(heard) != 1 || heard[0].PeerID != other.ID {
+		t.Fatalf("expected only the other peer in last heard, got %+v", heard)
+	}
+	if peer.Status != AuthDone || peer.TGID() != 91 || h.getPeer(peer.ID) == nil {
+		t.Fatal("expected session to be left alone")
+	}
+
+	// The session continues, counting from zero.
+	if err := h.handle(peer.Addr, testData(t, testPacket(2001, 91, dmr.CallTypeGroup), testConfig.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if peer.Counters.LoopedFrames != 1 {
+		t.Fatalf("expected 1 looped frame after reset, got %d", peer.Counters.LoopedFrames)
+	}
+
+	if err := h.ResetPeerStats(4242); err == nil {
+		t.Fatal("expected error for unknown peer")
+	}
+}
+
+func TestPeerStats(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+	other, remote := testIncomingPeer(t, h, 1002)
+	other.Subscribe(91, 0)
+	origin.Last.Connected = time.Now().Add(-time.Minute)
+
+	header := testPacket(2001, 91, dmr.CallTypeGroup)
+	header.DataType = dmr.VoiceLC
+	frames := []*dmr.Packet{header}
+	for i := 0; i < 3; i++ {
+		frames = append(frames, testPacket(2001, 91, dmr.CallTypeGroup))
+	}
+	var rxBytes uint64
+	for i, p := range frames {
+		data := testData(t, p, origin.ID)
+		data[53] = byte(i * 7) // bit errors
+		data[54] = 0x4b        // -75 dBm
+		rxBytes += uint64(len(data))
+		if err := h.handle(origin.Addr, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readFrames(t, remote, time.Millisecond*50); len(got) != len(frames) {
+		t.Fatalf("expected %d frames forwarded, got %d", len(frames), len(got))
+	}
+
+	stats := h.Stats()
+	rx, tx := stats[origin.ID], stats[other.ID]
+	if rx.RXPackets != 4 || rx.RXBytes != rxBytes || rx.TXPackets != 0 {
+		t.Fatalf("unexpected receive statistics %+v", rx)
+	}
+	if tx.TXPackets != 4 || tx.TXBytes != rxBytes || tx.RXPackets != 0 {
+		t.Fatalf("unexpected transmit statistics %+v", tx)
+	}
+
+	// BER of the voice frames: 7, 14 and 21 bit errors
+	if want := 21 * 100.0 / dmr.VoiceBitsChecked; math.Abs(rx.LastBER-want) > 1e-9 || rx.LastRSSI != -75 {
+		t.Fatalf("unexpected last quality %+v", rx)
+	}
+	if rx.AverageBER <= 7*100.0/dmr.VoiceBitsChecked || rx.AverageBER >= rx.LastBER {
+		t.Fatalf("expected rolling average BER between the first and last, got %f", rx.AverageBER)
+	}
+	if rx.Uptime < time.Minute {
+		t.Fatalf("expected uptime of at least a minute, got %s", rx.Uptime)
+	}
+
+	other.Status = AuthNone
+	if uptime := h.Stats()[other.ID].Uptime; uptime != 0 {
+		t.Fatalf("expected no uptime while not logged in, got %s", uptime)
+	}
+}
+
+func TestPingRTT(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	remote := testRemote(t)
+	defer remote.Close()
+
+	peer := &Peer{
+		ID:      1001,
+		Addr:    remote.LocalAddr().(*net.UDPAddr),
+		AuthKey: []byte("passw0rd"),
+	}
+	if err := h.Link(peer); err != nil {
+		t.Fatal(err)
+	}
+	peer.Status = AuthDone
+
+	var now = time.Now()
+	peer.Last.PongReceived = now.Add(-5 * time.Second)
+	peer.Last.PingSent = now
+	peer.pong(now.Add(40 * time.Millisecond))
+	if peer.RTT != 40*time.Millisecond || peer.SmoothedRTT != 40*time.Millisecond {
+		t.Fatalf("expected RTT of 40ms, got %s (smoothed %s)", peer.RTT, peer.SmoothedRTT)
+	}
+
+	// A pong without an outstanding ping isn't measured.
+	peer.pong(now.Add(time.Second))
+	if peer.RTT != 40*time.Millisecond {
+		t.Fatalf("expected unanswered pong to be ignored, got %s", peer.RTT)
+	}
+
+	peer.Last.PingSent = now.Add(2 * time.Second)
+	peer.pong(now.Add(2*time.Second + 120*time.Millisecond))
+	if peer.RTT != 120*time.Millisecond || peer.SmoothedRTT != 50*time.Millisecond {
+		t.Fatalf("expected RTT of 120ms smoothed to 50ms, got %s (smoothed %s)", peer.RTT, peer.SmoothedRTT)
+	}
+
+	// Through the protocol, and the statistics.
+	peer.Last.PingSent = time.Now().Add(-10 * time.Millisecond)
+	if err := h.handle(peer.Addr, append(MasterPong, h.id...)); err != nil {
+		t.Fatal(err)
+	}
+	if peer.RTT < 10*time.Millisecond {
+		t.Fatalf("expected RTT of at least 10ms, got %s", peer.RTT)
+	}
+	if s := h.Stats()[peer.ID]; s.RTT != peer.RTT || s.SmoothedRTT != peer.SmoothedRTT {
+		t.Fatalf("expected RTT in stats, got %+v", s)
+	}
+}
@@ -0,0 +1,170 @@
+package homebrew
+
+import (
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/lc"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+// slotState tracks the active stream on one of the timeslots of a peer. It's
+// handled with rxtx held, streamID, last and idle are also written under the
+// routing lock of the peer, so Hangtime can read them from the callbacks.
+type slotState struct {
+	streamID uint32
+	packet   *dmr.Packet // Last frame of the stream, for OnStreamEnd
+	rejected uint32      // Last rejected stream, so it's only counted once
+	start    time.Time
+	last     time.Time
+	idle     time.Time              // Last idle frame, sent during hangtime
+	expired  bool                   // Stream exceeded the maximum duration and is dropped
+	heard    *HeardEntry            // Last heard entry of the stream
+	jitter   *jitterBuffer          // De-jitter buffer of the stream, if enabled
+	embedded lc.EmbeddedLCAssembler // Embedded LC of the superframe
+}
+
+// acceptStream checks that p belongs to the active stream on its timeslot, or
+// that the timeslot is free. A repeater can't carry more than one stream per
+// timeslot, so a second concurrent stream is rejected.
+func (h *Homebrew) acceptStream(p *dmr.Packet, peer *Peer, now time.Time) bool {
+	s := &peer.slot[p.Timeslot&0x01]
+	if s.streamID != 0 && s.streamID != p.StreamID && now.Sub(s.last) < h.Timeouts.StreamTimeout {
+		if s.rejected != p.StreamID {
+			s.rejected = p.StreamID
+			peer.count(&peer.Counters.RejectedStreams)
+			h.logger.Warningf("peer %d@%s sent stream %#08x on busy TS%d (active stream %#08x), rejected\n",
+				peer.ID, peer.Addr, p.StreamID, p.Timeslot+1, s.streamID)
+		}
+		return false
+	}
+
+	if s.streamID != p.StreamID {
+		if s.streamID != 0 {
+			h.endStream(peer, s)
+		}
+		s.start = now
+		s.expired = false
+		if h.OnStreamStart != nil {
+			h.OnStreamStart(p)
+		}
+	}
+	peer.routing.Lock()
+	s.streamID = p.StreamID
+	s.last = now
+	peer.routing.Unlock()
+	s.packet = p
+	if p.DataType == dmr.TerminatorWithLC {
+		h.endStream(peer, s)
+	}
+	return true
+}
+
+// endStream ends the active stream of the timeslot, see OnStreamEnd.
+func (h *Homebrew) endStream(peer *Peer, s *slotState) {
+	peer.routing.Lock()
+	s.streamID = 0
+	peer.routing.Unlock()
+	if h.OnStreamEnd != nil {
+		h.OnStreamEnd(s.packet, s.last.Sub(s.start))
+	}
+	s.packet = nil
+}
+
+// expireStreams ends the streams that went silent for Timeouts.StreamTimeout
+// without a terminator.
+func (h *Homebrew) expireStreams(now time.Time) {
+	var peers = h.getPeers()
+
+	h.rxtx.Lock()
+	defer h.rxtx.Unlock()
+
+	for _, peer := range peers {
+		for i := range peer.slot {
+			s := &peer.slot[i]
+			if s.streamID != 0 && now.Sub(s.last) > h.Timeouts.StreamTimeout {
+				h.logger.Debugf("peer %d@%s stream %#08x on TS%d timed out\n", peer.ID, peer.Addr, s.streamID, i+1)
+				h.endStream(peer, s)
+			}
+		}
+	}
+}
+
+// idleFrame records an idle frame, which a repeater sends during the hangtime
+// following a transmission, and returns whether it is to be forwarded.
+func (h *Homebrew) idleFrame(p *dmr.Packet, peer *Peer, now time.Time) bool {
+	peer.routing.Lock()
+	peer.slot[p.Timeslot&0x01].idle = now
+	peer.routing.Unlock()
+	if h.SuppressIdle {
+		peer.count(&peer.Counters.SuppressedIdle)
+		return false
+	}
+	return true
+}
+
+// Hangtime checks whether the timeslot of the peer is in hangtime: idle
+// frames were received since the last transmission ended, recently enough to
+// keep the timeslot reserved. It's safe to call from the callbacks.
+func (h *Homebrew) Hangtime(peer *Peer, timeslot uint8) bool {
+	peer.routing.Lock()
+	defer peer.routing.Unlock()
+
+	return peer.slot[timeslot&0x01].hangtime(time.Now(), h.Timeouts.StreamTimeout)
+}
+
+// hangtime checks whether idle frames were received since the stream ended,
+// within timeout.
+func (s *slotState) hangtime(now time.Time, timeout time.Duration) bool {
+	return s.streamID == 0 && !s.idle.IsZero() && !s.idle.Before(s.last) && now.Sub(s.idle) < timeout
+}
+
+// streamExpired checks the duration of the stream p belongs to, counted from
+// its first frame, against MaxStreamDuration. Once exceeded, a terminator is
+// sent downstream and the remainder of the stream is dropped. The terminator
+// takes the same path as the frames, so it flushes the jitter buffer of the
+// stream and follows the frames it still holds.
+func (h *Homebrew) streamExpired(p *dmr.Packet, peer *Peer, now time.Time) bool {
+	s := &peer.slot[p.Timeslot&0x01]
+	if s.expired {
+		return true
+	}
+	if h.MaxStreamDuration == 0 || now.Sub(s.start) <= h.MaxStreamDuration {
+		return false
+	}
+
+	s.expired = true
+	peer.count(&peer.Counters.CutOffStreams)
+	h.logger.Warningf("peer %d@%s stream %#08x on TS%d exceeded %s, cut off\n",
+		peer.ID, peer.Addr, p.StreamID, p.Timeslot+1, h.MaxStreamDuration)
+
+	if p.DataType != dmr.TerminatorWithLC {
+		terminator, err := buildTerminator(p, h.Config.ColorCode)
+		if err == nil {
+			err = h.dejitter(terminator, peer)
+		}
+		if err != nil {
+			h.logger.Errorf("peer %d@%s stream %#08x terminator failed: %v\n", peer.ID, peer.Addr, p.StreamID, err)
+		}
+	}
+	if h.OnStreamCutOff != nil {
+		h.OnStreamCutOff(peer, p)
+	}
+	return true
+}
+
+// buildTerminator builds a terminator with LC ending the stream p belongs to.
+func buildTerminator(p *dmr.Packet, colorCode uint8) (*dmr.Packet, error) {
+	builder, err := voice.NewBuilder(p.SrcID, p.DstID, p.CallType, p.Timeslot, colorCode)
+	if err != nil {
+		return nil, err
+	}
+	builder.StreamID = p.StreamID
+
+	terminator, err := builder.Terminator()
+	if err != nil {
+		return nil, err
+	}
+	terminator.Sequence = p.Sequence + 1
+	return terminator, nil
+}
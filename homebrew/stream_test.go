@@ -0,0 +1,309 @@
+package homebrew
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+func TestConcurrentStreamRejected(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	var received []*dmr.Packet
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received = append(received, p)
+		return nil
+	})
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	first := testPacket(2001, 91, dmr.CallTypeGroup)
+	second := testPacket(2002, 92, dmr.CallTypeGroup)
+	for i := 0; i < 3; i++ {
+		h.handlePacket(first, peer)
+		h.handlePacket(second, peer)
+	}
+	if len(received) != 3 {
+		t.Fatalf("expected 3 frames of the first stream, got %d", len(received))
+	}
+	for _, p := range received {
+		if p.StreamID != first.StreamID {
+			t.Fatalf("expected only stream %#08x, got %#08x", first.StreamID, p.StreamID)
+		}
+	}
+	if peer.Counters.RejectedStreams != 1 {
+		t.Fatalf("expected 1 rejected stream, got %d", peer.Counters.RejectedStreams)
+	}
+
+	// The other timeslot is free.
+	other := testPacket(2003, 93, dmr.CallTypeGroup)
+	other.Timeslot = 1
+	h.handlePacket(other, peer)
+	if len(received) != 4 {
+		t.Fatalf("expected stream on TS2 to be accepted")
+	}
+
+	// After the terminator, the timeslot is free again.
+	terminator := testPacket(2001, 91, dmr.CallTypeGroup)
+	terminator.DataType = dmr.TerminatorWithLC
+	h.handlePacket(terminator, peer)
+	h.handlePacket(second, peer)
+	if len(received) != 6 || received[5].StreamID != second.StreamID {
+		t.Fatalf("expected second stream to be accepted after terminator")
+	}
+}
+
+func TestMaxStreamDuration(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.MaxStreamDuration = time.Minute * 3
+
+	var cutOff []uint32
+	h.OnStreamCutOff = func(peer *Peer, p *dmr.Packet) {
+		cutOff = append(cutOff, p.StreamID)
+	}
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	listener, remote := testIncomingPeer(t, h, 1002)
+	listener.Subscribe(91, 0)
+
+	long := testPacket(2001, 91, dmr.CallTypeGroup)
+	for i := 0; i < 3; i++ {
+		long.Sequence = uint8(i)
+		if err := h.handlePacket(long, peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Pretend the stream started longer than the maximum duration ago.
+	peer.slot[0].start = time.Now().Add(-h.MaxStreamDuration - time.Second)
+	for i := 3; i < 6; i++ {
+		long.Sequence = uint8(i)
+		if err := h.handlePacket(long, peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := readFrames(t, remote, 50*time.Millisecond)
+	if len(got) != 4 {
+		t.Fatalf("expected 3 frames and a terminator, got %d", len(got))
+	}
+	for _, p := range got[:3] {
+		if p.DataType != dmr.VoiceBurstA {
+			t.Fatalf("unexpected data type %s", dmr.DataTypeName[p.DataType])
+		}
+	}
+	if p := got[3]; p.DataType != dmr.TerminatorWithLC || p.StreamID != long.StreamID || p.Sequence != 4 {
+		t.Fatalf("expected synthesized terminator, got %s stream %#08x sequence %d",
+			dmr.DataTypeName[p.DataType], p.StreamID, p.Sequence)
+	}
+	if len(cutOff) != 1 || cutOff[0] != long.StreamID {
+		t.Fatalf("expected cut off callback once, got %v", cutOff)
+	}
+	if peer.Counters.CutOffStreams != 1 {
+		t.Fatalf("expected 1 cut off stream, got %d", peer.Counters.CutOffStreams)
+	}
+
+	// The terminator of the cut off stream is dropped, the next stream passes.
+	terminator := testPacket(2001, 91, dmr.CallTypeGroup)
+	terminator.DataType = dmr.TerminatorWithLC
+	h.handlePacket(terminator, peer)
+	h.handlePacket(testPacket(2002, 91, dmr.CallTypeGroup), peer)
+	if got := readFrames(t, remote, 50*time.Millisecond); len(got) != 1 || got[0].SrcID != 2002 {
+		t.Fatalf("expected only the next stream, got %d frames", len(got))
+	}
+}
+
+func TestMaxStreamDurationJitterBuffer(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.MaxStreamDuration = time.Minute * 3
+	h.JitterBuffer = 3
+	h.jitterInterval = time.Hour
+
+	var received = make(chan *dmr.Packet, 8)
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received <- p
+		return nil
+	})
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	for i := 0; i < 2; i++ {
+		p := testPacket(2001, 91, dmr.CallTypeGroup)
+		p.Sequence = uint8(i)
+		if err := h.handlePacket(p, peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The frames held by the jitter buffer precede the terminator.
+	peer.slot[0].start = time.Now().Add(-h.MaxStreamDuration - time.Second)
+	p := testPacket(2001, 91, dmr.CallTypeGroup)
+	p.Sequence = 2
+	if err := h.handlePacket(p, peer); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []uint8{dmr.VoiceBurstA, dmr.VoiceBurstA, dmr.TerminatorWithLC} {
+		select {
+		case p := <-received:
+			if p.DataType != want || p.Sequence != uint8(i) && want != dmr.TerminatorWithLC {
+				t.Fatalf("frame %d: expected %s, got %s sequence %d", i, dmr.DataTypeName[want], dmr.DataTypeName[p.DataType], p.Sequence)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for frame %d", i)
+		}
+	}
+
+	// Nothing of the stream follows the terminator.
+	if !peer.slot[0].jitter.done {
+		t.Fatal("expected the jitter buffer to be flushed")
+	}
+	select {
+	case p := <-received:
+		t.Fatalf("expected no frame after the terminator, got %s", dmr.DataTypeName[p.DataType])
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSuppressIdle(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+	other, remote := testIncomingPeer(t, h, 1002)
+	other.Subscribe(91, 0)
+	h.SuppressIdle = true
+
+	// A transmission, followed by hangtime
+	p := testPacket(2001, 91, dmr.CallTypeGroup)
+	h.handlePacket(p, origin)
+	terminator := testPacket(2001, 91, dmr.CallTypeGroup)
+	terminator.DataType = dmr.TerminatorWithLC
+	h.handlePacket(terminator, origin)
+	if got := readFrames(t, remote, time.Millisecond*50); len(got) != 2 {
+		t.Fatalf("expected transmission to be forwarded, got %v", got)
+	}
+	if h.Hangtime(origin, 0) {
+		t.Fatal("expected no hangtime before idle frames")
+	}
+
+	idle := testPacket(2001, 91, dmr.CallTypeGroup)
+	idle.DataType = dmr.Idle
+	for i := 0; i < 3; i++ {
+		h.handlePacket(idle, origin)
+	}
+	if got := readFrames(t, remote, time.Millisecond*50); len(got) != 0 {
+		t.Fatalf("expected idle frames to be suppressed, got %v", got)
+	}
+	if !h.Hangtime(origin, 0) || h.Hangtime(origin, 1) {
+		t.Fatal("expected hangtime on TS1 only")
+	}
+	if origin.Counters.SuppressedIdle != 3 {
+		t.Fatalf("expected 3 suppressed idle frames, got %d", origin.Counters.SuppressedIdle)
+	}
+
+	// A new transmission ends the hangtime
+	h.handlePacket(testPacket(2002, 91, dmr.CallTypeGroup), origin)
+	if h.Hangtime(origin, 0) {
+		t.Fatal("expected hangtime to end with a new transmission")
+	}
+
+	h.SuppressIdle = false
+	h.handlePacket(idle, origin)
+	if got := readFrames(t, remote, time.Millisecond*50); len(got) != 2 || got[1].DataType != dmr.Idle {
+		t.Fatalf("expected idle frame to be forwarded without suppression, got %v", got)
+	}
+}
+
+func TestStreamCallbacks(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	var events []string
+	h.OnStreamStart = func(p *dmr.Packet) {
+		events = append(events, fmt.Sprintf("start %#08x", p.StreamID))
+	}
+	h.OnStreamEnd = func(p *dmr.Packet, d time.Duration) {
+		events = append(events, fmt.Sprintf("end %#08x %s %s", p.StreamID, p.DataTypeString(), d))
+	}
+
+	// Ended by a terminator
+	var (
+		now        = time.Now()
+		p          = testPacket(2001, 91, dmr.CallTypeGroup)
+		terminator = testPacket(2001, 91, dmr.CallTypeGroup)
+	)
+	terminator.DataType = dmr.TerminatorWithLC
+	h.acceptStream(p, peer, now)
+	h.acceptStream(p, peer, now.Add(voice.BurstDuration))
+	h.acceptStream(terminator, peer, now.Add(2*voice.BurstDuration))
+
+	// Timed out
+	now = now.Add(time.Second)
+	p = testPacket(2002, 91, dmr.CallTypeGroup)
+	h.acceptStream(p, peer, now)
+	h.acceptStream(p, peer, now.Add(voice.BurstDuration))
+	h.expireStreams(now.Add(h.Timeouts.StreamTimeout))
+	if len(events) != 3 {
+		t.Fatalf("expected stream to be active within StreamTimeout, got %q", events)
+	}
+	h.expireStreams(now.Add(voice.BurstDuration + h.Timeouts.StreamTimeout + time.Millisecond))
+	h.expireStreams(now.Add(2 * h.Timeouts.StreamTimeout))
+
+	// Replacing a stream that timed out
+	p = testPacket(2003, 91, dmr.CallTypeGroup)
+	h.acceptStream(p, peer, now)
+	h.acceptStream(testPacket(2004, 91, dmr.CallTypeGroup), peer, now.Add(h.Timeouts.StreamTimeout))
+
+	var expected = []string{
+		"start 0x0007d15b", "end 0x0007d15b terminator with LC 120ms",
+		"start 0x0007d25b", "end 0x0007d25b voice (burst A) 60ms",
+		"start 0x0007d35b", "end 0x0007d35b voice (burst A) 0s", "start 0x0007d45b",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("expected %q, got %q", expected, events)
+	}
+}
+
+func TestHangtimeFromPacketFunc(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	origin, _ := testIncomingPeer(t, h, 1001)
+
+	var hangtime = make(chan bool, 4)
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		hangtime <- h.Hangtime(origin, p.Timeslot)
+		return nil
+	})
+
+	terminator := testPacket(2001, 91, dmr.CallTypeGroup)
+	terminator.DataType = dmr.TerminatorWithLC
+	idle := testPacket(2001, 91, dmr.CallTypeGroup)
+	idle.DataType = dmr.Idle
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), origin)
+		h.handlePacket(terminator, origin)
+		h.handlePacket(idle, origin)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Hangtime from the PacketFunc blocked")
+	}
+
+	for i, want := range []bool{false, false, true} {
+		if got := <-hangtime; got != want {
+			t.Fatalf("frame %d: expected hangtime %t, got %t", i, want, got)
+		}
+	}
+}
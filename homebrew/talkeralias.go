@@ -0,0 +1,148 @@
+package homebrew
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/polkabana/go-dmr/lc"
+)
+
+// Talker alias types carried in a DMRA frame.
+const (
+	talkerAliasHeader uint8 = iota
+	talkerAliasBlock1
+	talkerAliasBlock2
+	talkerAliasBlock3
+)
+
+// talkerAlias is the talker alias received for a source ID.
+type talkerAlias struct {
+	header *lc.TalkerAliasHeaderPDU
+	blocks [3]*lc.TalkerAliasBlockPDU
+	heard  time.Time
+}
+
+func (t *talkerAlias) String() string {
+	if t.header == nil {
+		return ""
+	}
+
+	var r lc.TalkerAliasReassembler
+	r.AddHeader(t.header)
+	for i, block := range t.blocks {
+		if block != nil {
+			r.AddBlock(i, block)
+		}
+	}
+	if alias, ok := r.Complete(); ok {
+		return strings.TrimRight(alias, "\x00 ")
+	}
+
+	// Not all blocks arrived yet
+	var parts = []string{t.header.DataAsString()}
+	for _, block := range t.blocks {
+		if block != nil {
+			parts = append(parts, block.DataAsStringWithFormat(t.header.DataFormat))
+		}
+	}
+	alias := []rune(strings.Join(parts, ""))
+	if int(t.header.Length) < len(alias) {
+		alias = alias[:t.header.Length]
+	}
+	return strings.TrimRight(string(alias), "\x00 ")
+}
+
+// TalkerAlias returns the talker alias received for the source ID, if any.
+func (h *Homebrew) TalkerAlias(srcID uint32) (string, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	t, ok := h.talkerAlias[srcID]
+	if !ok || t.header == nil {
+		return "", false
+	}
+	return t.String(), true
+}
+
+// handleTalkerAlias parses a DMRA frame, carrying the talker alias header or
+// one of the blocks for a source ID outside of the voice stream, into the
+// talker alias cache. The frame is passed on to the peers on the same
+// talkgroup as the sending peer, stamped with our repeater ID. Invalid frames
+// are logged, counted and dropped, see Counters.MalformedFrames.
+func (h *Homebrew) handleTalkerAlias(data []byte, peer *Peer) error {
+	if len(data) != 19 {
+		peer.count(&peer.Counters.MalformedFrames)
+		h.warnf(peer, "peer %d@%s sent %d talker alias bytes, expected 19 (dropped)\n", peer.ID, peer.Addr, len(data))
+		return nil
+	}
+
+	var (
+		srcID  = uint32(data[8])<<16 | uint32(data[9])<<8 | uint32(data[10])
+		kind   = data[11]
+		header *lc.TalkerAliasHeaderPDU
+		block  *lc.TalkerAliasBlockPDU
+		err    error
+	)
+	switch kind {
+	case talkerAliasHeader:
+		header, err = lc.ParseTalkerAliasHeaderPDU(data[12:19])
+	case talkerAliasBlock1, talkerAliasBlock2, talkerAliasBlock3:
+		block, err = lc.ParseTalkerAliasBlockPDU(data[12:19])
+	default:
+		err = fmt.Errorf("unknown type %d", kind)
+	}
+	if err != nil {
+		peer.count(&peer.Counters.MalformedFrames)
+		h.warnf(peer, "peer %d@%s sent invalid talker alias for %d: %v (dropped)\n", peer.ID, peer.Addr, srcID, err)
+		return nil
+	}
+
+	h.mutex.Lock()
+	t, ok := h.talkerAlias[srcID]
+	if !ok {
+		t = &talkerAlias{}
+		h.talkerAlias[srcID] = t
+	}
+	if header != nil {
+		*t = talkerAlias{header: header}
+	} else {
+		t.blocks[kind-talkerAliasBlock1] = block
+	}
+	t.heard = time.Now()
+	alias := t.String()
+	h.mutex.Unlock()
+
+	h.logger.Debugf("peer %d@%s sent talker alias for %d: %q\n", peer.ID, peer.Addr, srcID, alias)
+
+	if h.pf != nil || peer.PacketReceived != nil || h.Observer || h.Paused() {
+		return nil
+	}
+
+	var forward = make([]byte, len(data))
+	copy(forward, data)
+	copy(forward[4:8], h.id)
+	_, tg, timeslot := peer.subscriptions()
+	for _, toPeer := range h.getPeers() {
+		if toPeer == peer || !toPeer.done() || !toPeer.Subscribed(tg, timeslot) || toPeer.Forward == ForwardData {
+			continue
+		}
+		if err := h.WriteToPeer(forward, toPeer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireTalkerAliases removes the talker aliases not heard for
+// Timeouts.TalkerAliasTimeout.
+func (h *Homebrew) expireTalkerAliases(now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for srcID, t := range h.talkerAlias {
+		if now.Sub(t.heard) > h.Timeouts.TalkerAliasTimeout {
+			delete(h.talkerAlias, srcID)
+		}
+	}
+}
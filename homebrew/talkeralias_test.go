@@ -0,0 +1,79 @@
+package homebrew
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTalkerAliasFrame(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	peer.Subscribe(91, 0)
+	listener, listenerRemote := testIncomingPeer(t, h, 1002)
+	listener.Subscribe(91, 0)
+	_, otherRemote := testIncomingPeer(t, h, 1003)
+
+	talkerAlias := func(kind uint8, data string) []byte {
+		frame := append(append([]byte{}, DMRTalkerAlias...), RepeaterIDBytes(peer.ID)...)
+		frame = append(frame, 0x2f, 0x52, 0x32, kind) // source ID 3101234
+		return append(frame, data...)
+	}
+
+	// ISO 8 bit alias of 13 characters, in the header and the first block.
+	if err := h.handle(peer.Addr, talkerAlias(0, "\x5aPD0MZ ")); err != nil {
+		t.Fatal(err)
+	}
+	if alias, ok := h.TalkerAlias(3101234); !ok || alias != "PD0MZ" {
+		t.Fatalf("expected partial alias, got %q (%t)", alias, ok)
+	}
+	if err := h.handle(peer.Addr, talkerAlias(1, "Wijnand")); err != nil {
+		t.Fatal(err)
+	}
+	if alias, ok := h.TalkerAlias(3101234); !ok || alias != "PD0MZ Wijnand" {
+		t.Fatalf("expected full alias, got %q (%t)", alias, ok)
+	}
+	if _, ok := h.TalkerAlias(3101235); ok {
+		t.Fatal("expected no alias for unknown source")
+	}
+
+	// The frames are passed on to the peers on the same talkgroup.
+	var frames [][]byte
+	for i := 0; i < 2; i++ {
+		frames = append(frames, expectFrame(t, listenerRemote, DMRTalkerAlias, time.Second))
+	}
+	if !bytes.Equal(frames[1][4:8], RepeaterIDBytes(testConfig.ID)) || string(frames[1][12:]) != "Wijnand" {
+		t.Fatalf("unexpected forwarded frame %q", frames[1])
+	}
+	otherRemote.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, _, err := otherRemote.ReadFromUDP(make([]byte, 512)); err == nil {
+		t.Fatalf("expected no frames on other talkgroup, got %d bytes", n)
+	}
+
+	// Invalid frames are counted and dropped, without an alias entry.
+	if err := h.handle(peer.Addr, talkerAlias(4, "invalid")); err != nil {
+		t.Fatal(err)
+	}
+	invalid := talkerAlias(0, "\x5aPD0MZ ")
+	invalid[10] = 0x35 // source ID 3101237
+	if err := h.handle(peer.Addr, invalid[:18]); err != nil {
+		t.Fatal(err)
+	}
+	if peer.Counters.MalformedFrames != 2 {
+		t.Fatalf("expected 2 malformed frames, got %d", peer.Counters.MalformedFrames)
+	}
+	h.mutex.Lock()
+	entries := len(h.talkerAlias)
+	h.mutex.Unlock()
+	if entries != 1 {
+		t.Fatalf("expected 1 talker alias, got %d", entries)
+	}
+
+	// Aliases not heard from expire.
+	h.housekeeping(time.Now().Add(h.Timeouts.TalkerAliasTimeout + time.Second))
+	if _, ok := h.TalkerAlias(3101234); ok {
+		t.Fatal("expected the alias to expire")
+	}
+}
@@ -0,0 +1,87 @@
+package homebrew
+
+import (
+	"sort"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+// tgStatsSize is the number of talkgroups tracked, the least recently active
+// talkgroup is dropped when a new one is heard.
+const tgStatsSize = 1000
+
+// TGStat holds the activity of a talkgroup.
+type TGStat struct {
+	TGID          uint32
+	Transmissions uint64
+	Duration      time.Duration // Total duration of the transmissions
+	Sources       int           // Unique source IDs heard
+	Last          time.Time
+}
+
+type tgStat struct {
+	TGStat
+	sources map[uint32]struct{}
+}
+
+// TalkgroupStats returns the statistics per talkgroup, busiest first.
+func (h *Homebrew) TalkgroupStats() []TGStat {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var stats = make([]TGStat, 0, len(h.tgStats))
+	for _, s := range h.tgStats {
+		stats = append(stats, s.TGStat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Transmissions != stats[j].Transmissions {
+			return stats[i].Transmissions > stats[j].Transmissions
+		}
+		return stats[i].TGID < stats[j].TGID
+	})
+	return stats
+}
+
+// updateTGStats accounts a group call frame to its talkgroup. A frame starting
+// a stream counts a transmission, the following frames add to the duration.
+// Must be called with the mutex held.
+func (h *Homebrew) updateTGStats(p *dmr.Packet, start bool, elapsed time.Duration, now time.Time) {
+	if p.CallType != dmr.CallTypeGroup {
+		return
+	}
+
+	s, ok := h.tgStats[p.DstID]
+	if !ok {
+		if len(h.tgStats) >= tgStatsSize {
+			h.expireTGStats()
+		}
+		s = &tgStat{
+			TGStat:  TGStat{TGID: p.DstID},
+			sources: make(map[uint32]struct{}),
+		}
+		h.tgStats[p.DstID] = s
+	}
+
+	if start {
+		s.Transmissions++
+		s.sources[p.SrcID] = struct{}{}
+		s.Sources = len(s.sources)
+	}
+	s.Duration += elapsed
+	s.Last = now
+}
+
+// expireTGStats drops the least recently active talkgroup.
+func (h *Homebrew) expireTGStats() {
+	var (
+		oldest uint32
+		last   time.Time
+	)
+	for id, s := range h.tgStats {
+		if last.IsZero() || s.Last.Before(last) {
+			oldest, last = id, s.Last
+		}
+	}
+	delete(h.tgStats, oldest)
+}
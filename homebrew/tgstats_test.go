@@ -0,0 +1,37 @@
+package homebrew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestTalkgroupStats(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	transmit := func(src, dst uint32) {
+		h.handlePacket(testPacket(src, dst, dmr.CallTypeGroup), peer)
+		time.Sleep(5 * time.Millisecond)
+		h.handlePacket(testPacket(src, dst, dmr.CallTypeGroup), peer)
+		terminator := testPacket(src, dst, dmr.CallTypeGroup)
+		terminator.DataType = dmr.TerminatorWithLC
+		h.handlePacket(terminator, peer)
+	}
+	transmit(2001, 91)
+	transmit(2002, 91)
+	transmit(2001, 92)
+
+	stats := h.TalkgroupStats()
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 talkgroups, got %d", len(stats))
+	}
+	if s := stats[0]; s.TGID != 91 || s.Transmissions != 2 || s.Sources != 2 || s.Duration < 10*time.Millisecond {
+		t.Fatalf("unexpected stats for TG91: %+v", s)
+	}
+	if s := stats[1]; s.TGID != 92 || s.Transmissions != 1 || s.Sources != 1 || s.Duration < 5*time.Millisecond {
+		t.Fatalf("unexpected stats for TG92: %+v", s)
+	}
+}
@@ -0,0 +1,143 @@
+package homebrew
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+// TrafficGenerator originates synthetic voice streams through a repeater, for
+// load testing a master or reflector. Each stream runs from a voice LC header
+// through voice bursts of coded silence to a terminator, from a random source
+// to a random destination, alternating between the timeslots.
+type TrafficGenerator struct {
+	Repeater     dmr.Repeater
+	Sources      []uint32
+	Destinations []uint32
+	CallType     uint8
+	ColorCode    uint8
+
+	// StreamsPerMinute is the rate at which new streams are started, streams
+	// may overlap when they last longer than the interval between them.
+	StreamsPerMinute int
+
+	// Bursts is the number of voice bursts per stream, sent Interval apart.
+	Bursts   int
+	Interval time.Duration
+
+	timeslot uint8
+}
+
+// NewTrafficGenerator returns a TrafficGenerator for group calls of three
+// seconds, sent in real time.
+func NewTrafficGenerator(r dmr.Repeater, sources, destinations []uint32, streamsPerMinute int) *TrafficGenerator {
+	return &TrafficGenerator{
+		Repeater:         r,
+		Sources:          sources,
+		Destinations:     destinations,
+		CallType:         dmr.CallTypeGroup,
+		ColorCode:        1,
+		StreamsPerMinute: streamsPerMinute,
+		Bursts:           50,
+		Interval:         voice.BurstDuration,
+	}
+}
+
+// Stream sends a single stream from srcID to dstID on the timeslot.
+func (g *TrafficGenerator) Stream(srcID, dstID uint32, timeslot uint8) error {
+	builder, err := voice.NewBuilder(srcID, dstID, g.CallType, timeslot, g.ColorCode)
+	if err != nil {
+		return err
+	}
+
+	var audio []byte
+	for i := 0; i < 3; i++ {
+		audio = append(audio, voice.SilenceFrame...)
+	}
+
+	p, err := builder.Header()
+	if err != nil {
+		return err
+	}
+	if err := g.send(p); err != nil {
+		return err
+	}
+	for i := 0; i < g.Bursts; i++ {
+		if p, err = builder.Voice(dmr.BytesToBits(audio)); err != nil {
+			return err
+		}
+		if err := g.send(p); err != nil {
+			return err
+		}
+	}
+	if p, err = builder.Terminator(); err != nil {
+		return err
+	}
+	return g.Repeater.Send(p)
+}
+
+func (g *TrafficGenerator) send(p *dmr.Packet) error {
+	if err := g.Repeater.Send(p); err != nil {
+		return err
+	}
+	if g.Interval > 0 {
+		time.Sleep(g.Interval)
+	}
+	return nil
+}
+
+// Run starts streams at StreamsPerMinute until stop is closed, and waits for
+// the streams in progress to end.
+func (g *TrafficGenerator) Run(stop <-chan struct{}) error {
+	if g.Repeater == nil {
+		return errors.New("homebrew: traffic generator needs a repeater")
+	}
+	if len(g.Sources) == 0 || len(g.Destinations) == 0 {
+		return errors.New("homebrew: traffic generator needs source and destination IDs")
+	}
+	if g.StreamsPerMinute <= 0 {
+		return errors.New("homebrew: traffic generator needs a positive rate")
+	}
+
+	var (
+		ticker = time.NewTicker(time.Minute / time.Duration(g.StreamsPerMinute))
+		wg     sync.WaitGroup
+	)
+	defer ticker.Stop()
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ticker.C:
+			var (
+				srcID    = g.Sources[rand.Intn(len(g.Sources))]
+				dstID    = g.Destinations[rand.Intn(len(g.Destinations))]
+				timeslot = g.timeslot
+			)
+			g.timeslot ^= 1
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := g.Stream(srcID, dstID, timeslot); err != nil {
+					g.logger().Errorf("traffic from %d to %d on TS%d failed: %v\n", srcID, dstID, timeslot+1, err)
+				}
+			}()
+
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// logger returns the logger of the repeater, if it's ours.
+func (g *TrafficGenerator) logger() Logger {
+	if h, ok := g.Repeater.(*Homebrew); ok {
+		return h.logger
+	}
+	return log
+}
@@ -0,0 +1,65 @@
+package homebrew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestTrafficGenerator(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	_, remote := testIncomingPeer(t, h, 1001)
+
+	// Send queues the packets, the queue is sent by ListenAndServe.
+	h.Timeouts.SendInterval = time.Millisecond
+	serving := make(chan error)
+	go func() { serving <- h.ListenAndServe() }()
+	defer func() {
+		h.Close()
+		<-serving
+	}()
+
+	g := NewTrafficGenerator(h, []uint32{2001, 2002}, []uint32{91, 92}, 600)
+	g.Bursts = 6
+	g.Interval = 0
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- g.Run(stop) }()
+	time.Sleep(time.Millisecond * 250)
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	var streams = map[uint32][]*dmr.Packet{}
+	for _, p := range readFrames(t, remote, time.Millisecond*500) {
+		streams[p.StreamID] = append(streams[p.StreamID], p)
+	}
+	if len(streams) < 2 {
+		t.Fatalf("expected several streams, got %d", len(streams))
+	}
+	for id, packets := range streams {
+		if len(packets) != g.Bursts+2 {
+			t.Fatalf("stream %#08x: expected %d packets, got %d", id, g.Bursts+2, len(packets))
+		}
+		if packets[0].DataType != dmr.VoiceLC || packets[len(packets)-1].DataType != dmr.TerminatorWithLC {
+			t.Fatalf("stream %#08x: expected header and terminator, got %v", id, packets)
+		}
+		for i, p := range packets[1 : len(packets)-1] {
+			if p.DataType != dmr.VoiceBurstA+uint8(i%6) {
+				t.Fatalf("stream %#08x: expected voice burst %d, got data type %d", id, i, p.DataType)
+			}
+			if p.CallType != dmr.CallTypeGroup || (p.DstID != 91 && p.DstID != 92) {
+				t.Fatalf("stream %#08x: unexpected packet %v", id, p)
+			}
+		}
+	}
+
+	if err := NewTrafficGenerator(h, nil, []uint32{91}, 60).Run(stop); err == nil {
+		t.Fatal("expected error without sources")
+	}
+}
@@ -0,0 +1,20 @@
+package homebrew
+
+import (
+	"net"
+	"time"
+)
+
+// Transport exchanges the Homebrew frames with the peers. It's satisfied by
+// *net.UDPConn, other implementations can be injected with NewWithTransport,
+// for example to run the protocol over a different network or in tests.
+// Setting a past read deadline must interrupt a pending read, as it's used to
+// wake up ListenAndServe for the frames of peers with a LocalAddr.
+type Transport interface {
+	ReadFromUDP(b []byte) (int, *net.UDPAddr, error)
+	WriteTo(b []byte, addr net.Addr) (int, error)
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+var _ Transport = (*net.UDPConn)(nil)
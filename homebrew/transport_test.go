@@ -0,0 +1,105 @@
+package homebrew
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testDatagram struct {
+	addr *net.UDPAddr
+	data []byte
+}
+
+// testTransport is an in-memory Transport passing frames through channels.
+type testTransport struct {
+	in     chan testDatagram
+	out    chan testDatagram
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newTestTransport() *testTransport {
+	return &testTransport{
+		in:     make(chan testDatagram),
+		out:    make(chan testDatagram, 16),
+		closed: make(chan struct{}),
+	}
+}
+
+func (t *testTransport) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	select {
+	case d := <-t.in:
+		return copy(b, d.data), d.addr, nil
+	case <-t.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (t *testTransport) WriteTo(b []byte, addr net.Addr) (int, error) {
+	d := testDatagram{addr: addr.(*net.UDPAddr), data: append([]byte{}, b...)}
+	select {
+	case t.out <- d:
+		return len(b), nil
+	case <-t.closed:
+		return 0, net.ErrClosed
+	}
+}
+
+func (t *testTransport) SetReadDeadline(time.Time) error { return nil }
+
+func (t *testTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+// exchange sends a frame from addr and returns the reply.
+func (t *testTransport) exchange(tt *testing.T, addr *net.UDPAddr, data []byte) []byte {
+	tt.Helper()
+
+	t.in <- testDatagram{addr: addr, data: data}
+	select {
+	case d := <-t.out:
+		if d.addr.String() != addr.String() {
+			tt.Fatalf("reply sent to %s, expected %s", d.addr, addr)
+		}
+		return d.data
+	case <-time.After(time.Second):
+		tt.Fatalf("no reply to %q", data[:4])
+		return nil
+	}
+}
+
+// stallTransport is a testTransport that blocks writes to the stalled address
+// until released.
+type stallTransport struct {
+	*testTransport
+	stalled *net.UDPAddr
+	release chan struct{}
+}
+
+func (t *stallTransport) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if addr.String() == t.stalled.String() {
+		select {
+		case <-t.release:
+		case <-t.closed:
+			return 0, net.ErrClosed
+		}
+	}
+	return t.testTransport.WriteTo(b, addr)
+}
+
+// failTransport is a testTransport failing the writes to one address.
+type failTransport struct {
+	*testTransport
+	failing *net.UDPAddr
+}
+
+func (t *failTransport) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if addr.String() == t.failing.String() {
+		return 0, errors.New("write failed")
+	}
+	return t.testTransport.WriteTo(b, addr)
+}
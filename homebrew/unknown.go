@@ -0,0 +1,41 @@
+package homebrew
+
+import "net"
+
+// Policies for DMR data received from unknown peers.
+const (
+	UnknownDataIgnore uint8 = iota // Silently ignore the frame
+	UnknownDataLog                 // Log the frame
+	UnknownDataCount               // Log and count the frame, see UnknownDataFrames
+)
+
+// handleUnknownData applies the UnknownDataPolicy to a DMRD frame from a peer
+// that never logged in.
+func (h *Homebrew) handleUnknownData(remote *net.UDPAddr, data []byte) {
+	switch h.UnknownDataPolicy {
+	case UnknownDataCount:
+		h.mutex.Lock()
+		h.unknownData++
+		h.mutex.Unlock()
+		fallthrough
+	case UnknownDataLog:
+		var src uint32
+		if len(data) >= 8 {
+			src = uint32(data[5])<<16 | uint32(data[6])<<8 | uint32(data[7])
+		}
+		h.logger.Warningf("DMR data from unknown peer %s, source %d (ignored)\n", remote, src)
+	}
+
+	if h.OnUnknownData != nil {
+		h.OnUnknownData(remote, data)
+	}
+}
+
+// UnknownDataFrames returns the number of DMRD frames received from unknown
+// peers, counted with the UnknownDataCount policy.
+func (h *Homebrew) UnknownDataFrames() uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.unknownData
+}
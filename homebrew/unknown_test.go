@@ -0,0 +1,45 @@
+package homebrew
+
+import (
+	"net"
+	"testing"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestUnknownData(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	var (
+		unknown []*net.UDPAddr
+		addr    = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 62031}
+		data    = testData(t, testPacket(2001, 91, dmr.CallTypeGroup), 1001)
+	)
+	h.OnUnknownData = func(remote *net.UDPAddr, _ []byte) {
+		unknown = append(unknown, remote)
+	}
+
+	if err := h.handle(addr, data); err != nil {
+		t.Fatal(err)
+	}
+	if len(unknown) != 1 || unknown[0] != addr {
+		t.Fatalf("expected callback for DMR data from %s, got %v", addr, unknown)
+	}
+	if n := h.UnknownDataFrames(); n != 0 {
+		t.Fatalf("expected no count with the default policy, got %d", n)
+	}
+
+	h.UnknownDataPolicy = UnknownDataCount
+	for i := 0; i < 2; i++ {
+		if err := h.handle(addr, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := h.UnknownDataFrames(); n != 2 {
+		t.Fatalf("expected 2 counted frames, got %d", n)
+	}
+	if h.getPeerByAddr(addr) != nil {
+		t.Fatal("expected unknown peer not to be linked")
+	}
+}
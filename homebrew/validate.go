@@ -0,0 +1,29 @@
+package homebrew
+
+import "github.com/polkabana/go-dmr"
+
+// Policies for DMR data with invalid addressing, see dmr.Packet.Validate.
+const (
+	InvalidFrameForward uint8 = iota // Forward the frame unchecked
+	InvalidFrameLog                  // Log the frame, and forward it anyway
+	InvalidFrameDrop                 // Log, count and drop the frame, see Counters.RejectedFrames
+)
+
+// invalidFrame applies the InvalidFramePolicy to a frame received from peer.
+// It returns whether the frame is to be dropped.
+func (h *Homebrew) invalidFrame(p *dmr.Packet, peer *Peer) bool {
+	if h.InvalidFramePolicy == InvalidFrameForward {
+		return false
+	}
+	err := p.Validate()
+	if err == nil {
+		return false
+	}
+	if h.InvalidFramePolicy == InvalidFrameLog {
+		h.warnf(peer, "peer %d@%s sent invalid frame: %v\n", peer.ID, peer.Addr, err)
+		return false
+	}
+	peer.count(&peer.Counters.RejectedFrames)
+	h.warnf(peer, "peer %d@%s sent invalid frame: %v (dropped)\n", peer.ID, peer.Addr, err)
+	return true
+}
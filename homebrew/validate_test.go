@@ -0,0 +1,61 @@
+package homebrew
+
+import (
+	"testing"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestInvalidFramePolicy(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+
+	var received []*dmr.Packet
+	h.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received = append(received, p)
+		return nil
+	})
+	peer, _ := testIncomingPeer(t, h, 1001)
+
+	var tests = map[string]func(p *dmr.Packet){
+		"TG 0":      func(p *dmr.Packet) { p.DstID = 0 },
+		"stream ID": func(p *dmr.Packet) { p.StreamID = 0 },
+		"IPSC sync": func(p *dmr.Packet) { p.DataType = dmr.IPSCSync },
+		"timeslot":  func(p *dmr.Packet) { p.Timeslot = 2 },
+	}
+	for name, invalidate := range tests {
+		p := testPacket(2001, 91, dmr.CallTypeGroup)
+		invalidate(p)
+		if err := h.handlePacket(p, peer); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if len(received) != 0 {
+		t.Fatalf("expected invalid frames to be dropped, got %d frames", len(received))
+	}
+	if peer.Counters.RejectedFrames != uint64(len(tests)) {
+		t.Fatalf("expected %d rejected frames, got %d", len(tests), peer.Counters.RejectedFrames)
+	}
+
+	// Valid frames pass
+	if err := h.handlePacket(testPacket(2001, 91, dmr.CallTypeGroup), peer); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("expected valid frame to be forwarded, got %d frames", len(received))
+	}
+
+	// Logged but forwarded, or not checked at all
+	for _, policy := range []uint8{InvalidFrameLog, InvalidFrameForward} {
+		h.InvalidFramePolicy = policy
+		// Same stream as the valid frame, which holds the timeslot
+		p := testPacket(2001, 91, dmr.CallTypeGroup)
+		p.DstID = 0
+		if err := h.handlePacket(p, peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(received) != 3 || peer.Counters.RejectedFrames != uint64(len(tests)) {
+		t.Fatalf("expected invalid frames forwarded, got %d frames and %d rejected", len(received), peer.Counters.RejectedFrames)
+	}
+}
@@ -0,0 +1,82 @@
+package homebrew
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/bptc"
+	"github.com/polkabana/go-dmr/crc"
+	"github.com/polkabana/go-dmr/fec"
+	"github.com/polkabana/go-dmr/lc"
+)
+
+// verifyFEC checks the FEC and CRC protecting the burst, as far as they apply
+// to its data type: the EMB of voice bursts B to F, the Slot Type of bursts
+// with a data SYNC, and the Reed-Solomon checksum or CRC of the full LCs, CSBKs
+// and data headers.
+func verifyFEC(p *dmr.Packet) error {
+	switch dmr.DataTypeBurstClass(p.DataType) {
+	case dmr.BurstClassVoice:
+		return nil
+	case dmr.BurstClassEmbedded:
+		_, err := dmr.ParseEMB(p.EMBBits())
+		return err
+	case dmr.BurstClassUnknown:
+		return nil
+	}
+
+	if err := fec.Golay_20_8_Check(p.SlotTypeBits()); err != nil {
+		return err
+	}
+	if slotType := p.SlotType()[0] & dmr.B00001111; slotType != p.DataType {
+		return fmt.Errorf("homebrew: slot type %d doesn't match data type %d", slotType, p.DataType)
+	}
+
+	switch p.DataType {
+	case dmr.VoiceLC, dmr.TerminatorWithLC:
+		_, err := lc.DecodeFullLC(p.InfoBits(), p.DataType)
+		return err
+	case dmr.CSBK, dmr.Data:
+	default:
+		return nil
+	}
+
+	var data = make([]byte, dmr.InfoSize)
+	if err := bptc.Decode(p.InfoBits(), data); err != nil {
+		return err
+	}
+	switch p.DataType {
+	case dmr.CSBK:
+		if !crc.CheckCCITT16(data, crc.MaskCSBK) {
+			return errors.New("homebrew: CSBK CRC error")
+		}
+	case dmr.Data:
+		if !crc.CheckCCITT16(data, crc.MaskDataHeader) {
+			return errors.New("homebrew: data header CRC error")
+		}
+	}
+	return nil
+}
+
+// corruptFrame verifies the FEC of a frame received from peer with VerifyFEC
+// set, and counts it when corrupt. It returns whether the frame is to be
+// dropped, see DropCorrupt.
+func (h *Homebrew) corruptFrame(p *dmr.Packet, peer *Peer) bool {
+	if !h.VerifyFEC {
+		return false
+	}
+	err := verifyFEC(p)
+	if err == nil {
+		return false
+	}
+
+	peer.count(&peer.Counters.CorruptFrames)
+	if h.DropCorrupt {
+		peer.count(&peer.Counters.DroppedCorruptFrames)
+		h.logger.Debugf("peer %d@%s sent a corrupt %s frame, stream %#08x: %v (dropped)\n", peer.ID, peer.Addr, p.DataTypeString(), p.StreamID, err)
+		return true
+	}
+	h.logger.Debugf("peer %d@%s sent a corrupt %s frame, stream %#08x: %v\n", peer.ID, peer.Addr, p.DataTypeString(), p.StreamID, err)
+	return false
+}
@@ -0,0 +1,87 @@
+package homebrew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+func TestVerifyFEC(t *testing.T) {
+	h := testHomebrew(t)
+	defer h.Close()
+	h.VerifyFEC = true
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	other, remote := testIncomingPeer(t, h, 1002)
+	other.Subscribe(91, 0)
+
+	b, err := voice.NewBuilder(2001, 91, dmr.CallTypeGroup, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packets []*dmr.Packet
+	header, err := b.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets = append(packets, header)
+	for i := 0; i < 4; i++ {
+		p, err := b.Voice(make([]byte, dmr.VoiceBits))
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, p)
+	}
+	terminator, err := b.Terminator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets = append(packets, terminator)
+
+	corrupt := func(p *dmr.Packet, bit int) {
+		var bits = append([]byte(nil), p.Bits...)
+		bits[bit] ^= 1
+		p.SetData(dmr.BitsToBytes(bits))
+	}
+	// The EMB of burst C is counted, but still forwarded.
+	corrupt(packets[3], dmr.SyncOffsetBits)
+	for _, p := range packets[:4] {
+		if err := h.handlePacket(p, peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Burst D and the Slot Type of the terminator are dropped.
+	h.DropCorrupt = true
+	corrupt(packets[4], dmr.SyncOffsetBits+1)
+	corrupt(packets[5], dmr.InfoHalfBits)
+	for _, p := range packets[4:] {
+		if err := h.handlePacket(p, peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := readFrames(t, remote, 100*time.Millisecond)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 frames forwarded, got %d", len(got))
+	}
+	for i, p := range got {
+		if p.DataType != packets[i].DataType {
+			t.Fatalf("unexpected frame %d: %v", i, p)
+		}
+	}
+	if c := peer.SnapshotCounters(); c.CorruptFrames != 3 || c.DroppedCorruptFrames != 2 {
+		t.Fatalf("expected 3 corrupt frames and 2 dropped, got %d and %d", c.CorruptFrames, c.DroppedCorruptFrames)
+	}
+
+	// Valid frames pass the checks.
+	if terminator, err = b.Terminator(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range append(packets[:3], terminator) {
+		if err := verifyFEC(p); err != nil {
+			t.Fatalf("%s: %v", dmr.DataTypeName[p.DataType], err)
+		}
+	}
+}
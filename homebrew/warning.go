@@ -0,0 +1,57 @@
+package homebrew
+
+import (
+	"fmt"
+	"time"
+)
+
+// warning is the last warning logged for a peer, repeats of it are counted
+// instead of logged.
+type warning struct {
+	msg     string
+	since   time.Time
+	repeats int
+}
+
+// warnf logs a warning about a peer. The same warning repeated within
+// Timeouts.WarningInterval is coalesced, and logged once with a count when a
+// different warning comes along or the interval ends.
+func (h *Homebrew) warnf(peer *Peer, format string, args ...interface{}) {
+	var (
+		msg = fmt.Sprintf(format, args...)
+		now = time.Now()
+	)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	w := &peer.warning
+	if msg == w.msg && now.Sub(w.since) < h.Timeouts.WarningInterval {
+		w.repeats++
+		return
+	}
+	h.flushWarning(peer)
+	h.logger.Warningf("%s", msg)
+	peer.warning = warning{msg: msg, since: now}
+}
+
+// flushWarning logs the number of times the last warning of the peer was
+// repeated, if any. Must be called with the mutex held.
+func (h *Homebrew) flushWarning(peer *Peer) {
+	if w := &peer.warning; w.repeats > 0 {
+		h.logger.Warningf("peer %d@%s repeated the last warning %d times\n", peer.ID, peer.Addr, w.repeats)
+		w.repeats = 0
+	}
+}
+
+// expireWarnings logs the repeats of warnings of which the interval ended.
+func (h *Homebrew) expireWarnings(now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, peer := range h.Peer {
+		if now.Sub(peer.warning.since) >= h.Timeouts.WarningInterval {
+			h.flushWarning(peer)
+		}
+	}
+}
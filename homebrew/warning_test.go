@@ -0,0 +1,42 @@
+package homebrew
+
+import (
+	stdlog "log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+func TestWarningsCoalesced(t *testing.T) {
+	backend := logging.NewMemoryBackend(64)
+	log.SetBackend(logging.AddModuleLevel(backend))
+	defer log.SetBackend(logging.AddModuleLevel(logging.NewLogBackend(os.Stderr, "", stdlog.LstdFlags)))
+
+	h := testHomebrew(t)
+	defer h.Close()
+
+	peer, _ := testIncomingPeer(t, h, 1001)
+	unexpected := []byte("RPTSBKN\x00\x00\x00\x00")
+	for i := 0; i < 5; i++ {
+		if err := h.handle(peer.Addr, unexpected); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.expireWarnings(time.Now().Add(h.Timeouts.WarningInterval))
+
+	var warnings []string
+	for n := backend.Head(); n != nil; n = n.Next() {
+		if n.Record.Level == logging.WARNING {
+			warnings = append(warnings, n.Record.Message())
+		}
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %q", warnings)
+	}
+	if !strings.Contains(warnings[1], "repeated the last warning 4 times") {
+		t.Fatalf("expected repeat count, got %q", warnings[1])
+	}
+}
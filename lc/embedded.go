@@ -0,0 +1,109 @@
+package lc
+
+import (
+	"fmt"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/vbptc"
+)
+
+// ColorCodeError is returned by AddFragment when the color code of a burst
+// differs from the one of burst B, which hints at interference or an
+// overlapping transmission.
+type ColorCodeError struct {
+	From, To uint8
+}
+
+func (e *ColorCodeError) Error() string {
+	return fmt.Sprintf("dmr/lc: color code changed from %d to %d mid superframe", e.From, e.To)
+}
+
+// EmbeddedLCAssembler collects the embedded signalling fragments carried by
+// voice bursts B to E into the link control they make up, so the caller can be
+// known mid-stream without the Voice LC Header.
+type EmbeddedLCAssembler struct {
+	fragments [4][]byte
+	colorCode uint8
+}
+
+// AddFragment adds the 32 fragment bits of voice burst B to E, by data type
+// dmr.VoiceBurstB to dmr.VoiceBurstE, with the color code of its EMB. Burst B
+// starts a new link control, other bursts and fragments of the wrong length
+// are ignored. A color code differing from the one of burst B drops the
+// collected fragments and returns a *ColorCodeError.
+func (e *EmbeddedLCAssembler) AddFragment(burst, colorCode uint8, bits []byte) error {
+	if burst < dmr.VoiceBurstB || burst > dmr.VoiceBurstE || len(bits) != dmr.EMBSignallingLCFragmentBits {
+		return nil
+	}
+	if burst == dmr.VoiceBurstB {
+		e.Reset()
+		e.colorCode = colorCode
+	} else if e.fragments[0] != nil && colorCode != e.colorCode {
+		err := &ColorCodeError{From: e.colorCode, To: colorCode}
+		e.Reset()
+		return err
+	}
+	e.fragments[burst-dmr.VoiceBurstB] = append([]byte(nil), bits...)
+	return nil
+}
+
+// Reset drops the collected fragments.
+func (e *EmbeddedLCAssembler) Reset() {
+	*e = EmbeddedLCAssembler{}
+}
+
+// LC decodes the link control once all four fragments arrived, see
+// LinkControl.
+func (e *EmbeddedLCAssembler) LC() (*LC, bool) {
+	data, ok := e.data()
+	if !ok {
+		return nil, false
+	}
+	l, err := ParseLC(data)
+	if err != nil {
+		return nil, false
+	}
+	return l, true
+}
+
+// LinkControl decodes the voice link control once all four fragments arrived.
+// The BPTC (128, 72) repairs single bit errors per row, link control that
+// fails the 5 bit checksum or isn't of a voice channel user is not returned.
+func (e *EmbeddedLCAssembler) LinkControl() (*VoiceLCHeaderPDU, bool) {
+	data, ok := e.data()
+	if !ok {
+		return nil, false
+	}
+	header, err := ParseVoiceLCHeaderPDU(data)
+	if err != nil {
+		return nil, false
+	}
+	return header, true
+}
+
+// data returns the 9 link control bytes once all four fragments arrived and
+// pass the checksum.
+func (e *EmbeddedLCAssembler) data() ([]byte, bool) {
+	var v = vbptc.New(8)
+	for _, fragment := range e.fragments {
+		if fragment == nil {
+			return nil, false
+		}
+		if err := v.AddBurst(fragment); err != nil {
+			return nil, false
+		}
+	}
+	if err := v.CheckAndRepair(); err != nil {
+		return nil, false
+	}
+
+	var bits = make([]byte, 77)
+	if err := v.GetData(bits); err != nil {
+		return nil, false
+	}
+	eslc, err := dmr.DeinterleaveEmbeddedSignallingLC(bits)
+	if err != nil || !eslc.Check() {
+		return nil, false
+	}
+	return dmr.BitsToBytes(eslc.Bits), true
+}
@@ -0,0 +1,83 @@
+package lc
+
+import (
+	"testing"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestEmbeddedLCAssembler(t *testing.T) {
+	// Embedded fragments of bursts B to E of a group call from 2042214 to
+	// talk group 91.
+	var fragments = [][]byte{
+		{0x05, 0x0f, 0x0c, 0x06},
+		{0x0a, 0x09, 0x05, 0x03},
+		{0x06, 0x18, 0x22, 0x0c},
+		{0x14, 0x30, 0x28, 0x39},
+	}
+
+	var e EmbeddedLCAssembler
+	for i, fragment := range fragments {
+		if _, ok := e.LinkControl(); ok {
+			t.Fatalf("expected incomplete LC after %d fragments", i)
+		}
+		e.AddFragment(dmr.VoiceBurstB+uint8(i), 1, dmr.BytesToBits(fragment))
+	}
+	header, ok := e.LinkControl()
+	if !ok {
+		t.Fatal("expected LC")
+	}
+	if !header.GroupCall() || header.SrcID() != 2042214 || header.DstID() != 91 {
+		t.Fatalf("unexpected LC %s", header)
+	}
+	if l, ok := e.LC(); !ok || l.Opcode != GroupVoiceChannelUser {
+		t.Fatalf("expected group voice channel user LC, got %+v", l)
+	}
+
+	// A single bit error is repaired.
+	bits := dmr.BytesToBits(fragments[2])
+	bits[5] ^= 1
+	e.AddFragment(dmr.VoiceBurstD, 1, bits)
+	if header, ok := e.LinkControl(); !ok || header.SrcID() != 2042214 {
+		t.Fatalf("expected repaired LC, got %v, %t", header, ok)
+	}
+
+	// Burst B starts over.
+	e.AddFragment(dmr.VoiceBurstB, 1, dmr.BytesToBits(fragments[0]))
+	if _, ok := e.LinkControl(); ok {
+		t.Fatal("expected incomplete LC after burst B")
+	}
+	for i, fragment := range fragments[1:] {
+		e.AddFragment(dmr.VoiceBurstC+uint8(i), 1, dmr.BytesToBits(fragment))
+	}
+	if _, ok := e.LinkControl(); !ok {
+		t.Fatal("expected LC")
+	}
+
+	// Errors beyond repair are not returned.
+	bits = dmr.BytesToBits(fragments[3])
+	bits[0] ^= 1
+	bits[1] ^= 1
+	bits[16] ^= 1
+	bits[17] ^= 1
+	e.AddFragment(dmr.VoiceBurstE, 1, bits)
+	if header, ok := e.LinkControl(); ok {
+		t.Fatalf("expected no LC, got %s", header)
+	}
+
+	// A color code change drops the collected fragments.
+	e.AddFragment(dmr.VoiceBurstB, 1, dmr.BytesToBits(fragments[0]))
+	e.AddFragment(dmr.VoiceBurstC, 1, dmr.BytesToBits(fragments[1]))
+	err := e.AddFragment(dmr.VoiceBurstD, 2, dmr.BytesToBits(fragments[2]))
+	if cc, ok := err.(*ColorCodeError); !ok || cc.From != 1 || cc.To != 2 {
+		t.Fatalf("expected color code change from 1 to 2, got %v", err)
+	}
+	e.AddFragment(dmr.VoiceBurstD, 2, dmr.BytesToBits(fragments[2]))
+	e.AddFragment(dmr.VoiceBurstE, 2, dmr.BytesToBits(fragments[3]))
+	if header, ok := e.LinkControl(); ok {
+		t.Fatalf("expected no LC, got %s", header)
+	}
+	if l, ok := e.LC(); ok {
+		t.Fatalf("expected no LC, got %+v", l)
+	}
+}
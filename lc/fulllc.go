@@ -0,0 +1,76 @@
+package lc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/bptc"
+	"github.com/polkabana/go-dmr/fec"
+)
+
+// Full LC Reed-Solomon checksum masks, see DMR AI spec. page 143.
+const (
+	voiceLCMask    = 0x96
+	terminatorMask = 0x99
+)
+
+func fullLCMask(dataType uint8) (uint8, error) {
+	switch dataType {
+	case dmr.VoiceLC:
+		return voiceLCMask, nil
+	case dmr.TerminatorWithLC:
+		return terminatorMask, nil
+	default:
+		return 0, fmt.Errorf("dmr/lc: data type %d doesn't carry a full LC", dataType)
+	}
+}
+
+// DecodeFullLC decodes the 9 byte full link control from the 196 info bits of
+// a Voice LC Header or Terminator with LC burst, by data type dmr.VoiceLC or
+// dmr.TerminatorWithLC. The Reed-Solomon (12, 9) checksum is checked, not
+// used to correct errors.
+func DecodeFullLC(info []byte, dataType uint8) ([]byte, error) {
+	mask, err := fullLCMask(dataType)
+	if err != nil {
+		return nil, err
+	}
+
+	var data = make([]byte, dmr.InfoSize)
+	if err := bptc.Decode(info, data); err != nil {
+		return nil, err
+	}
+	for i := 9; i < 12; i++ {
+		data[i] ^= mask
+	}
+	syndrome := &fec.RS_12_9_Poly{}
+	if err := fec.RS_12_9_CalcSyndrome(data, syndrome); err != nil {
+		return nil, err
+	}
+	if !fec.RS_12_9_CheckSyndrome(syndrome) {
+		return nil, errors.New("dmr/lc: full LC checksum error")
+	}
+	return data[:9], nil
+}
+
+// EncodeFullLC encodes the 9 byte full link control in the 196 info bits of a
+// Voice LC Header or Terminator with LC burst, by data type, see DecodeFullLC.
+func EncodeFullLC(data []byte, dataType uint8) ([]byte, error) {
+	if len(data) != 9 {
+		return nil, fmt.Errorf("dmr/lc: expected 9 full LC bytes, got %d", len(data))
+	}
+	mask, err := fullLCMask(dataType)
+	if err != nil {
+		return nil, err
+	}
+
+	var block = append([]byte(nil), data...)
+	for _, c := range fec.RS_12_9_CalcChecksum(data) {
+		block = append(block, c^mask)
+	}
+	var info = make([]byte, dmr.InfoBits)
+	if err := bptc.Encode(block, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,41 @@
+package lc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestFullLC(t *testing.T) {
+	// Group call from 2042214 to talk group 91
+	var data = []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x5b, 0x1f, 0x29, 0x66}
+
+	for _, dataType := range []uint8{dmr.VoiceLC, dmr.TerminatorWithLC} {
+		info, err := EncodeFullLC(data, dataType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(info) != dmr.InfoBits {
+			t.Fatalf("expected %d info bits, got %d", dmr.InfoBits, len(info))
+		}
+		decoded, err := DecodeFullLC(info, dataType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("expected %x, got %x", data, decoded)
+		}
+	}
+
+	info, err := EncodeFullLC(data, dmr.VoiceLC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeFullLC(info, dmr.CSBK); err == nil {
+		t.Fatal("expected CSBK to fail")
+	}
+	if _, err := EncodeFullLC(data[:8], dmr.VoiceLC); err == nil {
+		t.Fatal("expected short LC to fail")
+	}
+}
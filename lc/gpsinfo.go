@@ -2,11 +2,12 @@ package lc
 
 import (
 	"fmt"
+	"math"
 
 	dmr "github.com/polkabana/go-dmr"
 )
 
-// Data Format
+// Position Error
 // ref: ETSI TS 102 361-2 7.2.18
 const (
 	ErrorLT2m uint8 = iota
@@ -41,7 +42,7 @@ type GpsInfoPDU struct {
 // ParseGpsInfoPDU parse gps info pdu
 func ParseGpsInfoPDU(data []byte) (*GpsInfoPDU, error) {
 	if len(data) != 7 {
-		return nil, fmt.Errorf("dmr/lc/talkeralias: expected 7 bytes, got %d", len(data))
+		return nil, fmt.Errorf("dmr/lc/gpsinfo: expected 7 bytes, got %d", len(data))
 	}
 
 	return &GpsInfoPDU{
@@ -64,7 +65,28 @@ func (g *GpsInfoPDU) Bytes() []byte {
 	}
 }
 
+// Position returns the latitude and longitude in degrees. Both are two's
+// complement, in steps of 180/2^24 degrees latitude and 360/2^25 degrees
+// longitude.
+func (g *GpsInfoPDU) Position() (lat, lon float64) {
+	lat = float64(int32(g.Latitude<<8)>>8) * 180 / (1 << 24)
+	lon = float64(int32(g.Longitude<<7)>>7) * 360 / (1 << 25)
+	return lat, lon
+}
+
+// SetPosition encodes the latitude and longitude in degrees, see Position.
+func (g *GpsInfoPDU) SetPosition(lat, lon float64) {
+	g.Latitude = uint32(int32(math.Round(lat*(1<<24)/180))) & 0x00ffffff
+	g.Longitude = uint32(int32(math.Round(lon*(1<<25)/360))) & 0x01ffffff
+}
+
+// PositionAsString returns the latitude and longitude in degrees as text.
+func (g *GpsInfoPDU) PositionAsString() string {
+	lat, lon := g.Position()
+	return fmt.Sprintf("%.6f, %.6f", lat, lon)
+}
+
 func (g *GpsInfoPDU) String() string {
-	return fmt.Sprintf("GpsInfo: [ error: %s lon: %d lat: %d ]",
-		PositionErrorName[g.PositionError], g.Longitude, g.Latitude)
+	return fmt.Sprintf("GpsInfo: [ error: %s position: %s ]",
+		PositionErrorName[g.PositionError], g.PositionAsString())
 }
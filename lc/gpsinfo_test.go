@@ -0,0 +1,50 @@
+package lc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGpsInfoPDU(t *testing.T) {
+	var tests = []struct {
+		data     []byte
+		err      uint8
+		lat, lon float64
+	}{
+		{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, ErrorLT2m, 0, 0},
+		{[]byte{0x05, 0x80, 0x00, 0x00, 0x40, 0x00, 0x00}, ErrorLT200m, 45, -90},
+		{[]byte{0x0e, 0x80, 0x00, 0x00, 0xc0, 0x00, 0x00}, ErrorUnknown, -45, 90},
+		{[]byte{0x03, 0x00, 0x00, 0x00, 0x80, 0x00, 0x00}, ErrorLT20m, -90, -180},
+		{[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x01}, ErrorLT2m, 180.0 / (1 << 24), 360.0 / (1 << 25)},
+		{[]byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, ErrorLT2m, -180.0 / (1 << 24), -360.0 / (1 << 25)},
+	}
+	for _, test := range tests {
+		g, err := ParseGpsInfoPDU(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.PositionError != test.err {
+			t.Errorf("%x: expected error %s, got %s", test.data, PositionErrorName[test.err], PositionErrorName[g.PositionError])
+		}
+		if lat, lon := g.Position(); lat != test.lat || lon != test.lon {
+			t.Errorf("%x: expected %f, %f, got %f, %f", test.data, test.lat, test.lon, lat, lon)
+		}
+		if b := g.Bytes(); !bytes.Equal(b, test.data) {
+			t.Errorf("expected %x, got %x", test.data, b)
+		}
+
+		var e = &GpsInfoPDU{PositionError: test.err}
+		e.SetPosition(test.lat, test.lon)
+		if b := e.Bytes(); !bytes.Equal(b, test.data) {
+			t.Errorf("%f, %f: expected %x, got %x", test.lat, test.lon, test.data, b)
+		}
+	}
+
+	g, _ := ParseGpsInfoPDU([]byte{0x05, 0x80, 0x00, 0x00, 0x40, 0x00, 0x00})
+	if s := g.String(); s != "GpsInfo: [ error: < 200m position: 45.000000, -90.000000 ]" {
+		t.Errorf("unexpected string %q", s)
+	}
+	if _, err := ParseGpsInfoPDU(make([]byte, 6)); err == nil {
+		t.Error("expected error for short PDU")
+	}
+}
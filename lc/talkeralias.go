@@ -1,7 +1,10 @@
 package lc
 
 import (
+	"errors"
 	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	dmr "github.com/polkabana/go-dmr"
 )
@@ -23,6 +26,14 @@ var DataFormatName = map[uint8]string{
 	FormatUTF16BE: "unicode utf-16be",
 }
 
+// Talker alias capacity, the header carries 6 data bytes and each of the three
+// blocks carries 7.
+const (
+	talkerAliasHeaderBytes = 6
+	talkerAliasBlockBytes  = 7
+	talkerAliasBlocks      = 3
+)
+
 // TalkerAliasHeaderPDU Conforms to ETSI TS 102 361-2 7.1.1.4
 type TalkerAliasHeaderPDU struct {
 	DataFormat uint8
@@ -40,17 +51,51 @@ func movebit(src []byte, srcByte int, srcBit int, dst []byte, dstByte int, dstBi
 	if bit >= 1 {
 		dst[dstByte] |= 1 << uint8(dstBit)
 	} else {
-		dst[dstByte] &= 0 << uint8(dstBit)
+		dst[dstByte] &^= 1 << uint8(dstBit)
+	}
+}
+
+// MaxChars returns the number of characters the talker alias header and blocks
+// can carry in the given data format.
+func MaxChars(format uint8) int {
+	var n = talkerAliasHeaderBytes + talkerAliasBlocks*talkerAliasBlockBytes
+	switch format {
+	case Format7Bit:
+		return 7 + talkerAliasBlocks*8
+	case FormatISO8Bit, FormatUTF8:
+		return n
+	case FormatUTF16BE:
+		return n / 2
+	default:
+		return 0
 	}
 }
 
 // ParseTalkerAliasHeaderPDU parses TalkerAliasHeader PDU from bytes
 func ParseTalkerAliasHeaderPDU(data []byte) (*TalkerAliasHeaderPDU, error) {
+	return parseTalkerAliasHeaderPDU(data, false)
+}
+
+// ParseTalkerAliasHeaderPDUStrict parses TalkerAliasHeader PDU from bytes like
+// ParseTalkerAliasHeaderPDU, but also refuses headers with the reserved bit 49
+// set, for the formats that don't carry a character in it.
+func ParseTalkerAliasHeaderPDUStrict(data []byte) (*TalkerAliasHeaderPDU, error) {
+	return parseTalkerAliasHeaderPDU(data, true)
+}
+
+func parseTalkerAliasHeaderPDU(data []byte, strict bool) (*TalkerAliasHeaderPDU, error) {
 	if len(data) != 7 {
 		return nil, fmt.Errorf("dmr/lc/talkeralias: expected 7 bytes, got %d", len(data))
 	}
 
 	dataFormat := (data[0] & dmr.B11000000) >> 6
+	length := (data[0] & dmr.B00111110) >> 1
+	if max := MaxChars(dataFormat); int(length) > max {
+		return nil, fmt.Errorf("dmr/lc/talkeralias: length %d exceeds %d characters for %s", length, max, DataFormatName[dataFormat])
+	}
+	if strict && dataFormat != Format7Bit && data[0]&dmr.B00000001 != 0 {
+		return nil, fmt.Errorf("dmr/lc/talkeralias: reserved bit 49 set for %s", DataFormatName[dataFormat])
+	}
 
 	var out []byte
 	if dataFormat == Format7Bit {
@@ -61,46 +106,39 @@ func ParseTalkerAliasHeaderPDU(data []byte) (*TalkerAliasHeaderPDU, error) {
 			movebit(data, i/8, (7 - (i % 8)), out, (i-7)/7, 6-(i%7))
 		}
 	} else {
-		out = data[1:6]
+		out = data[1:7]
 	}
 
 	return &TalkerAliasHeaderPDU{
 		DataFormat: dataFormat,
-		Length:     (data[0] & dmr.B00111110) >> 1,
+		Length:     length,
 		Data:       out,
 	}, nil
 }
 
-// Bytes returns object as bytes
+// Bytes returns object as bytes. The 7 bit format packs its 7 characters
+// right after the length, starting at bit 49, for the other formats bit 49 is
+// reserved and left zero.
 func (t *TalkerAliasHeaderPDU) Bytes() []byte {
-	var (
-		out   []byte
-		bit49 byte
-	)
+	var out = make([]byte, 7)
 
 	if t.DataFormat == Format7Bit {
-		out = make([]byte, 6)
+		var chars = make([]byte, 7)
+		copy(chars, t.Data)
 		for i := 7; i < 56; i++ {
-			movebit(t.Data, (i-7)/7, 6-(i%7), out, (i/8)-1, (7 - (i % 8)))
+			movebit(chars, (i-7)/7, 6-(i%7), out, i/8, 7-(i%8))
 		}
 	} else {
-		out = t.Data
+		copy(out[1:], t.Data)
 	}
 
-	return []byte{
-		((t.DataFormat << 6) & dmr.B11000000) | ((t.Length << 1) & dmr.B00111110) | (bit49 & dmr.B00000001),
-		out[0],
-		out[1],
-		out[2],
-		out[3],
-		out[4],
-		out[5],
-	}
+	out[0] |= ((t.DataFormat << 6) & dmr.B11000000) | ((t.Length << 1) & dmr.B00111110)
+	return out
 }
 
-// DataAsString Returns data part of PDU encoded as string
+// DataAsString Returns data part of PDU decoded according to its data format
 func (t *TalkerAliasHeaderPDU) DataAsString() string {
-	return string(t.Data)
+	return decodeTalkerAlias(t.Data, t.DataFormat)
 }
 
 func (t *TalkerAliasHeaderPDU) String() string {
@@ -115,7 +153,7 @@ func ParseTalkerAliasBlockPDU(data []byte) (*TalkerAliasBlockPDU, error) {
 	}
 
 	return &TalkerAliasBlockPDU{
-		Data: data[0:6],
+		Data: data[0:7],
 	}, nil
 }
 
@@ -124,11 +162,243 @@ func (t *TalkerAliasBlockPDU) Bytes() []byte {
 	return t.Data
 }
 
-// DataAsString Returns data part of PDU encoded as string
+// DataAsString Returns data part of PDU as UTF-8 string, the block doesn't
+// carry the data format, see DataAsStringWithFormat
 func (t *TalkerAliasBlockPDU) DataAsString() string {
-	return string(t.Data)
+	return t.DataAsStringWithFormat(FormatUTF8)
+}
+
+// DataAsStringWithFormat Returns data part of PDU decoded according to the
+// data format of the header. A UTF-16 character split over two blocks is
+// left out, see TalkerAliasReassembler to decode the complete alias.
+func (t *TalkerAliasBlockPDU) DataAsStringWithFormat(format uint8) string {
+	if format == Format7Bit {
+		return decodeTalkerAlias(unpack7Bit(t.Data), format)
+	}
+	return decodeTalkerAlias(t.Data, format)
 }
 
 func (t *TalkerAliasBlockPDU) String() string {
 	return fmt.Sprintf("TalkerAliasBlock: [ data: \"%s\" ]", t.DataAsString())
 }
+
+// BuildTalkerAlias encodes s in the given data format, into the talker alias
+// header and as many blocks as needed to carry it. The header carries 6 bytes
+// and each block 7, except for 7 bit aliases where they carry 7 and 8
+// characters, so up to 31 characters fit.
+func BuildTalkerAlias(s string, format uint8) (*TalkerAliasHeaderPDU, []*TalkerAliasBlockPDU, error) {
+	var (
+		data   []byte
+		length int
+	)
+	switch format {
+	case Format7Bit:
+		for _, r := range s {
+			if r > 0x7f {
+				return nil, nil, fmt.Errorf("dmr/lc/talkeralias: %q can't be encoded as 7 bit", r)
+			}
+		}
+		return build7BitTalkerAlias([]byte(s))
+	case FormatISO8Bit:
+		for _, r := range s {
+			if r > 0xff {
+				return nil, nil, fmt.Errorf("dmr/lc/talkeralias: %q can't be encoded as ISO 8 bit", r)
+			}
+			data = append(data, byte(r))
+		}
+		length = len(data)
+	case FormatUTF8:
+		data = []byte(s)
+		length = utf8.RuneCountInString(s)
+	case FormatUTF16BE:
+		for _, u := range utf16.Encode([]rune(s)) {
+			data = append(data, byte(u>>8), byte(u))
+		}
+		length = len(data) / 2
+	default:
+		return nil, nil, errors.New("dmr/lc/talkeralias: unsupported data format " + DataFormatName[format])
+	}
+
+	if max := talkerAliasHeaderBytes + talkerAliasBlocks*talkerAliasBlockBytes; len(data) > max {
+		return nil, nil, fmt.Errorf("dmr/lc/talkeralias: alias of %d bytes exceeds %d bytes", len(data), max)
+	}
+
+	var padded = make([]byte, talkerAliasHeaderBytes)
+	copy(padded, data)
+	header := &TalkerAliasHeaderPDU{
+		DataFormat: format,
+		Length:     uint8(length),
+		Data:       padded,
+	}
+
+	var blocks []*TalkerAliasBlockPDU
+	for o := talkerAliasHeaderBytes; o < len(data); o += talkerAliasBlockBytes {
+		var block = make([]byte, talkerAliasBlockBytes)
+		copy(block, data[o:])
+		blocks = append(blocks, &TalkerAliasBlockPDU{Data: block})
+	}
+	return header, blocks, nil
+}
+
+// build7BitTalkerAlias builds the talker alias header carrying the first 7
+// characters, unpacked, and the blocks carrying 8 packed characters each.
+func build7BitTalkerAlias(chars []byte) (*TalkerAliasHeaderPDU, []*TalkerAliasBlockPDU, error) {
+	const (
+		headerChars = 7
+		blockChars  = 8
+	)
+	if max := MaxChars(Format7Bit); len(chars) > max {
+		return nil, nil, fmt.Errorf("dmr/lc/talkeralias: alias of %d characters exceeds %d characters", len(chars), max)
+	}
+
+	var padded = make([]byte, headerChars)
+	copy(padded, chars)
+	header := &TalkerAliasHeaderPDU{
+		DataFormat: Format7Bit,
+		Length:     uint8(len(chars)),
+		Data:       padded,
+	}
+
+	var blocks []*TalkerAliasBlockPDU
+	for o := headerChars; o < len(chars); o += blockChars {
+		var block = make([]byte, blockChars)
+		copy(block, chars[o:])
+		blocks = append(blocks, &TalkerAliasBlockPDU{Data: pack7Bit(block)})
+	}
+	return header, blocks, nil
+}
+
+// TalkerAliasReassembler assembles the talker alias spread over the header
+// and blocks, which may arrive in any order.
+type TalkerAliasReassembler struct {
+	header *TalkerAliasHeaderPDU
+	blocks [talkerAliasBlocks]*TalkerAliasBlockPDU
+}
+
+// AddHeader adds the talker alias header, replacing a previous one.
+func (r *TalkerAliasReassembler) AddHeader(header *TalkerAliasHeaderPDU) {
+	r.header = header
+}
+
+// AddBlock adds talker alias block 1 to 3 by index 0 to 2, other indexes are
+// ignored.
+func (r *TalkerAliasReassembler) AddBlock(index int, block *TalkerAliasBlockPDU) {
+	if index >= 0 && index < talkerAliasBlocks {
+		r.blocks[index] = block
+	}
+}
+
+// Reset drops the header and blocks, to start on a new alias.
+func (r *TalkerAliasReassembler) Reset() {
+	*r = TalkerAliasReassembler{}
+}
+
+// Complete returns the alias once the header and enough blocks arrived to
+// make up the number of characters in the header's Length.
+func (r *TalkerAliasReassembler) Complete() (string, bool) {
+	if r.header == nil {
+		return "", false
+	}
+
+	var (
+		format = r.header.DataFormat
+		length = int(r.header.Length)
+		data   = r.header.Data
+	)
+	// The 7 bit header carries 7 unpacked characters, each block 8 packed ones
+	for i := 0; !talkerAliasComplete(data, format, length); i++ {
+		if i == talkerAliasBlocks || r.blocks[i] == nil {
+			return "", false
+		}
+		block := r.blocks[i].Data
+		if format == Format7Bit {
+			block = unpack7Bit(block)
+		}
+		data = append(data[:len(data):len(data)], block...)
+	}
+
+	switch format {
+	case Format7Bit, FormatISO8Bit:
+		data = data[:length]
+	case FormatUTF16BE:
+		data = data[:length*2]
+	case FormatUTF8:
+		var n int
+		for i := 0; i < length; i++ {
+			_, size := utf8.DecodeRune(data[n:])
+			n += size
+		}
+		data = data[:n]
+	}
+	return decodeTalkerAlias(data, format), true
+}
+
+// talkerAliasComplete checks whether data holds length characters.
+func talkerAliasComplete(data []byte, format uint8, length int) bool {
+	switch format {
+	case FormatUTF16BE:
+		return len(data) >= length*2
+	case FormatUTF8:
+		for i := 0; i < length; i++ {
+			if !utf8.FullRune(data) {
+				return false
+			}
+			_, size := utf8.DecodeRune(data)
+			data = data[size:]
+		}
+		return true
+	default:
+		return len(data) >= length
+	}
+}
+
+// unpack7Bit unpacks the 7 bit characters packed in data, most significant
+// bit first, one per byte.
+func unpack7Bit(data []byte) []byte {
+	var out = make([]byte, len(data)*8/7)
+	for i := range out {
+		for bit := i * 7; bit < (i+1)*7; bit++ {
+			out[i] = out[i]<<1 | (data[bit/8]>>uint(7-bit%8))&0x01
+		}
+	}
+	return out
+}
+
+// pack7Bit packs 7 bit characters, most significant bit first.
+func pack7Bit(chars []byte) []byte {
+	var out = make([]byte, (len(chars)*7+7)/8)
+	for i, c := range chars {
+		for j := 0; j < 7; j++ {
+			bit := i*7 + j
+			out[bit/8] |= ((c >> uint(6-j)) & 0x01) << uint(7-bit%8)
+		}
+	}
+	return out
+}
+
+// decodeTalkerAlias decodes talker alias bytes in the data format to a string.
+// 7 bit data is expected unpacked, one character per byte.
+func decodeTalkerAlias(data []byte, format uint8) string {
+	switch format {
+	case Format7Bit:
+		var b = make([]byte, len(data))
+		for i, c := range data {
+			b[i] = c & 0x7f
+		}
+		return string(b)
+	case FormatISO8Bit:
+		var runes = make([]rune, len(data))
+		for i, c := range data {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	case FormatUTF16BE:
+		var units = make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = uint16(data[i*2])<<8 | uint16(data[i*2+1])
+		}
+		return string(utf16.Decode(units))
+	default:
+		return string(data)
+	}
+}
@@ -0,0 +1,250 @@
+package lc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// testPack7Bit packs the 7 bit characters in s after the prefix bits, most
+// significant bit first.
+func testPack7Bit(prefix []byte, s string) []byte {
+	var bits = append([]byte{}, prefix...)
+	for _, c := range []byte(s) {
+		for i := 6; i >= 0; i-- {
+			bits = append(bits, (c>>uint(i))&0x01)
+		}
+	}
+	var out = make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		out[i/8] |= bit << uint(7-i%8)
+	}
+	return out
+}
+
+func TestTalkerAliasReassemblerUTF8(t *testing.T) {
+	const alias = "PD0MZ Wijnand ☺ 73 de NL"
+	header, blocks, err := BuildTalkerAlias(alias, FormatUTF8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %d", len(blocks))
+	}
+
+	var r TalkerAliasReassembler
+	if _, ok := r.Complete(); ok {
+		t.Fatal("expected incomplete alias without header")
+	}
+
+	// Blocks arrive out of order, the alias is complete with the last one.
+	for _, i := range []int{2, 0} {
+		block, err := ParseTalkerAliasBlockPDU(blocks[i].Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.AddBlock(i, block)
+	}
+	parsed, err := ParseTalkerAliasHeaderPDU(header.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddHeader(parsed)
+	if s, ok := r.Complete(); ok {
+		t.Fatalf("expected incomplete alias without block 2, got %q", s)
+	}
+	r.AddBlock(1, blocks[1])
+	if s, ok := r.Complete(); !ok || s != alias {
+		t.Fatalf("expected %q, got %q, %t", alias, s, ok)
+	}
+
+	r.Reset()
+	if _, ok := r.Complete(); ok {
+		t.Fatal("expected incomplete alias after reset")
+	}
+}
+
+func TestTalkerAliasReassembler7Bit(t *testing.T) {
+	const alias = "PD0MZ Wijnand"
+
+	// Format and length in the first 7 bits, followed by 7 characters.
+	header, err := ParseTalkerAliasHeaderPDU(testPack7Bit([]byte{0, 0, 0, 1, 1, 0, 1}, alias[:7]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.DataFormat != Format7Bit || header.Length != uint8(len(alias)) {
+		t.Fatalf("unexpected header %s", header)
+	}
+	block, err := ParseTalkerAliasBlockPDU(testPack7Bit(nil, alias[7:]+"\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r TalkerAliasReassembler
+	r.AddHeader(header)
+	if s, ok := r.Complete(); ok {
+		t.Fatalf("expected incomplete alias, got %q", s)
+	}
+	r.AddBlock(0, block)
+	if s, ok := r.Complete(); !ok || s != alias {
+		t.Fatalf("expected %q, got %q, %t", alias, s, ok)
+	}
+}
+
+func TestTalkerAliasDataAsString(t *testing.T) {
+	var tests = []struct {
+		format uint8
+		header []byte
+		block  []byte
+		want   string
+		block1 string
+	}{
+		{FormatISO8Bit, []byte("Jos\xe9 M"), []byte("\xfcller\x20\xa9"), "José M", "üller ©"},
+		{FormatUTF8, []byte("Zoë 7"), []byte("73 ☺\x00"), "Zoë 7", "73 ☺\x00"},
+		{FormatUTF16BE, []byte{0x00, 'Z', 0x00, 0xeb, 0x26, 0x3a}, []byte{0x00, 'a', 0x04, 0x16, 0x00, 'b', 0x00}, "Zë☺", "aЖb"},
+		{Format7Bit, nil, testPack7Bit(nil, "PD0MZ NL"), "", "PD0MZ NL"},
+	}
+	for _, test := range tests {
+		if test.header != nil {
+			header := &TalkerAliasHeaderPDU{DataFormat: test.format, Data: test.header}
+			if s := header.DataAsString(); s != test.want {
+				t.Errorf("%s: expected header %q, got %q", DataFormatName[test.format], test.want, s)
+			}
+		}
+		block := &TalkerAliasBlockPDU{Data: test.block}
+		if s := block.DataAsStringWithFormat(test.format); s != test.block1 {
+			t.Errorf("%s: expected block %q, got %q", DataFormatName[test.format], test.block1, s)
+		}
+	}
+
+	// Blocks default to UTF-8.
+	if s := (&TalkerAliasBlockPDU{Data: []byte("Zoë ☺")}).DataAsString(); s != "Zoë ☺" {
+		t.Fatalf("unexpected block %q", s)
+	}
+}
+
+func TestBuildTalkerAlias(t *testing.T) {
+	var tests = []struct {
+		format uint8
+		alias  string
+	}{
+		{Format7Bit, "PD0MZ"},
+		{Format7Bit, "PD0MZ Wijnand 73 de JO22 NL"},
+		{Format7Bit, "0123456789012345678901234567890"},
+		{FormatISO8Bit, "José Müller ©"},
+		{FormatUTF8, "PD0MZ Wijnand ☺ 73 de NL"},
+		{FormatUTF16BE, "Zoë ☺ Жb"},
+	}
+	for _, test := range tests {
+		header, blocks, err := BuildTalkerAlias(test.alias, test.format)
+		if err != nil {
+			t.Fatalf("%s: %v", DataFormatName[test.format], err)
+		}
+
+		parsed, err := ParseTalkerAliasHeaderPDU(header.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var r TalkerAliasReassembler
+		r.AddHeader(parsed)
+		for i, block := range blocks {
+			parsed, err := ParseTalkerAliasBlockPDU(block.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.AddBlock(i, parsed)
+		}
+		if s, ok := r.Complete(); !ok || s != test.alias {
+			t.Errorf("%s: expected %q, got %q, %t", DataFormatName[test.format], test.alias, s, ok)
+		}
+	}
+
+	for format, alias := range map[uint8]string{
+		Format7Bit:    "0123456789012345678901234567890X",
+		FormatISO8Bit: "012345678901234567890123456X",
+		FormatUTF8:    "☺☺☺☺☺☺☺☺☺☺",
+		FormatUTF16BE: "01234567890123",
+	} {
+		if _, _, err := BuildTalkerAlias(alias, format); err == nil {
+			t.Errorf("%s: expected error for %q", DataFormatName[format], alias)
+		}
+	}
+	if _, _, err := BuildTalkerAlias("Zoë", Format7Bit); err == nil {
+		t.Error("expected error for non 7 bit character")
+	}
+}
+
+func TestTalkerAliasHeaderRoundTrip(t *testing.T) {
+	var tests = []struct {
+		name string
+		data []byte
+	}{
+		{"7 bit", testPack7Bit([]byte{0, 0, 0, 1, 1, 0, 1}, "PD0MZ W")},
+		{"7 bit, bit 49 set", testPack7Bit([]byte{0, 0, 0, 0, 1, 1, 1}, "\x7f~}|{zy")},
+		{"ISO 8 bit", []byte{0x5a, 'J', 'o', 's', 0xe9, ' ', 'M'}},
+		{"UTF-8", []byte{0x8a, 'Z', 'o', 0xc3, 0xab, ' ', '7'}},
+		{"UTF-16BE", []byte{0xc6, 0x00, 'Z', 0x00, 0xeb, 0x26, 0x3a}},
+	}
+	for _, test := range tests {
+		header, err := ParseTalkerAliasHeaderPDU(test.data)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if b := header.Bytes(); !bytes.Equal(b, test.data) {
+			t.Errorf("%s: expected %x, got %x", test.name, test.data, b)
+		}
+	}
+
+	// Built 7 bit headers survive the round trip and unused bits are zero.
+	header, _, err := BuildTalkerAlias("PD0", Format7Bit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := header.Bytes(); !bytes.Equal(b, testPack7Bit([]byte{0, 0, 0, 0, 0, 1, 1}, "PD0\x00\x00\x00\x00")) {
+		t.Fatalf("unexpected header %x", b)
+	}
+	parsed, err := ParseTalkerAliasHeaderPDU(header.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, header) {
+		t.Fatalf("expected %s, got %s", header, parsed)
+	}
+}
+
+func TestParseTalkerAliasHeaderValidation(t *testing.T) {
+	for format, want := range map[uint8]int{Format7Bit: 31, FormatISO8Bit: 27, FormatUTF8: 27, FormatUTF16BE: 13} {
+		if n := MaxChars(format); n != want {
+			t.Errorf("%s: expected %d characters, got %d", DataFormatName[format], want, n)
+		}
+	}
+
+	var tests = []struct {
+		name   string
+		data   []byte
+		strict bool
+		ok     bool
+	}{
+		{"7 bit, 31 characters", []byte{0x3f, 0, 0, 0, 0, 0, 0}, true, true},
+		{"ISO 8 bit, 27 characters", []byte{0x76, 0, 0, 0, 0, 0, 0}, true, true},
+		{"ISO 8 bit, 28 characters", []byte{0x78, 0, 0, 0, 0, 0, 0}, false, false},
+		{"UTF-8, 28 characters", []byte{0xb8, 0, 0, 0, 0, 0, 0}, false, false},
+		{"UTF-16BE, 13 characters", []byte{0xda, 0, 0, 0, 0, 0, 0}, true, true},
+		{"UTF-16BE, 14 characters", []byte{0xdc, 0, 0, 0, 0, 0, 0}, false, false},
+		{"UTF-8, bit 49 set", []byte{0x8b, 0, 0, 0, 0, 0, 0}, false, true},
+		{"UTF-8, bit 49 set, strict", []byte{0x8b, 0, 0, 0, 0, 0, 0}, true, false},
+		{"7 bit, bit 49 set, strict", []byte{0x0b, 0, 0, 0, 0, 0, 0}, true, true},
+	}
+	for _, test := range tests {
+		var err error
+		if test.strict {
+			_, err = ParseTalkerAliasHeaderPDUStrict(test.data)
+		} else {
+			_, err = ParseTalkerAliasHeaderPDU(test.data)
+		}
+		if (err == nil) != test.ok {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
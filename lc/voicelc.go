@@ -0,0 +1,131 @@
+package lc
+
+import (
+	"fmt"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/lc/serviceoptions"
+)
+
+// VoiceLCHeaderPDU Conforms to ETSI TS 102 361-1 7.1.1
+type VoiceLCHeaderPDU struct {
+	LC
+}
+
+// TerminatorWithLCPDU Conforms to ETSI TS 102 361-1 7.1.2
+type TerminatorWithLCPDU struct {
+	LC
+}
+
+// parseVoiceLC parses the full link control of a voice call, as carried by
+// the Voice LC Header and the Terminator with LC bursts.
+func parseVoiceLC(data []byte) (LC, error) {
+	l, err := ParseLC(data)
+	if err != nil {
+		return LC{}, err
+	}
+	if l.VoiceChannelUser == nil {
+		return LC{}, fmt.Errorf("dmr/lc: FLCO %06b is not a voice channel user", l.Opcode)
+	}
+	return *l, nil
+}
+
+// buildVoiceLC builds the full link control of a group or unit to unit call.
+func buildVoiceLC(groupCall bool, srcID, dstID uint32, options serviceoptions.ServiceOptions) (LC, error) {
+	if srcID > 0xffffff || dstID > 0xffffff {
+		return LC{}, fmt.Errorf("dmr/lc: addresses %d->%d exceed 24 bits", srcID, dstID)
+	}
+
+	var l = LC{
+		CallType: dmr.CallTypePrivate,
+		Opcode:   UnitToUnitVoiceChannelUser,
+		VoiceChannelUser: &VoiceChannelUserPDU{
+			ServiceOptions: options,
+			DstID:          dstID,
+			SrcID:          srcID,
+		},
+	}
+	if groupCall {
+		l.CallType = dmr.CallTypeGroup
+		l.Opcode = GroupVoiceChannelUser
+	}
+	return l, nil
+}
+
+// ParseVoiceLCHeaderPDU parses the link control of a Voice LC Header.
+func ParseVoiceLCHeaderPDU(data []byte) (*VoiceLCHeaderPDU, error) {
+	l, err := parseVoiceLC(data)
+	if err != nil {
+		return nil, err
+	}
+	return &VoiceLCHeaderPDU{l}, nil
+}
+
+// BuildVoiceLCHeaderPDU builds the link control of a Voice LC Header for a
+// group or unit to unit call.
+func BuildVoiceLCHeaderPDU(groupCall bool, srcID, dstID uint32, options serviceoptions.ServiceOptions) (*VoiceLCHeaderPDU, error) {
+	l, err := buildVoiceLC(groupCall, srcID, dstID, options)
+	if err != nil {
+		return nil, err
+	}
+	return &VoiceLCHeaderPDU{l}, nil
+}
+
+// SrcID returns the source address.
+func (v *VoiceLCHeaderPDU) SrcID() uint32 {
+	return v.VoiceChannelUser.SrcID
+}
+
+// DstID returns the destination address, a talk group for group calls.
+func (v *VoiceLCHeaderPDU) DstID() uint32 {
+	return v.VoiceChannelUser.DstID
+}
+
+// GroupCall returns whether the LC is of a group call, as opposed to a unit to
+// unit call.
+func (v *VoiceLCHeaderPDU) GroupCall() bool {
+	return v.Opcode == GroupVoiceChannelUser
+}
+
+func (v *VoiceLCHeaderPDU) String() string {
+	return fmt.Sprintf("VoiceLCHeader: [ %s ]", v.LC.String())
+}
+
+// ParseTerminatorWithLCPDU parses the link control of a Terminator with LC.
+func ParseTerminatorWithLCPDU(data []byte) (*TerminatorWithLCPDU, error) {
+	l, err := parseVoiceLC(data)
+	if err != nil {
+		return nil, err
+	}
+	return &TerminatorWithLCPDU{l}, nil
+}
+
+// BuildTerminatorWithLCPDU builds the link control of a Terminator with LC for
+// a group or unit to unit call.
+func BuildTerminatorWithLCPDU(groupCall bool, srcID, dstID uint32, options serviceoptions.ServiceOptions) (*TerminatorWithLCPDU, error) {
+	l, err := buildVoiceLC(groupCall, srcID, dstID, options)
+	if err != nil {
+		return nil, err
+	}
+	return &TerminatorWithLCPDU{l}, nil
+}
+
+// SrcID returns the source address.
+func (t *TerminatorWithLCPDU) SrcID() uint32 {
+	return t.VoiceChannelUser.SrcID
+}
+
+// DstID returns the destination address, a talk group for group calls.
+func (t *TerminatorWithLCPDU) DstID() uint32 {
+	return t.VoiceChannelUser.DstID
+}
+
+// GroupCall returns whether the LC is of a group call, as opposed to a unit to
+// unit call.
+func (t *TerminatorWithLCPDU) GroupCall() bool {
+	return t.Opcode == GroupVoiceChannelUser
+}
+
+func (t *TerminatorWithLCPDU) String() string {
+	return fmt.Sprintf("TerminatorWithLC: [ %s ]", t.LC.String())
+}
@@ -0,0 +1,68 @@
+package lc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/polkabana/go-dmr/lc/serviceoptions"
+)
+
+func TestVoiceLCHeaderPDU(t *testing.T) {
+	var tests = []struct {
+		data      []byte
+		groupCall bool
+		src, dst  uint32
+		options   serviceoptions.ServiceOptions
+	}{
+		// Group call from 2042214 to talk group 91
+		{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x5b, 0x1f, 0x29, 0x66}, true, 2042214, 91, serviceoptions.ServiceOptions{}},
+		// Unit to unit call from 2042214 to 4000
+		{[]byte{0x03, 0x00, 0x00, 0x00, 0x0f, 0xa0, 0x1f, 0x29, 0x66}, false, 2042214, 4000, serviceoptions.ServiceOptions{}},
+		// Emergency group call with priority 3
+		{[]byte{0x00, 0x00, 0xc1, 0xff, 0xff, 0xff, 0x00, 0x00, 0x01}, true, 1, 0xffffff, serviceoptions.ServiceOptions{Emergency: true, Priority: serviceoptions.Priority3}},
+	}
+	for _, test := range tests {
+		header, err := ParseVoiceLCHeaderPDU(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.GroupCall() != test.groupCall || header.SrcID() != test.src || header.DstID() != test.dst || header.VoiceChannelUser.ServiceOptions != test.options {
+			t.Errorf("%x: unexpected %s", test.data, header)
+		}
+		if b := header.Bytes(); !bytes.Equal(b, test.data) {
+			t.Errorf("expected %x, got %x", test.data, b)
+		}
+
+		built, err := BuildVoiceLCHeaderPDU(test.groupCall, test.src, test.dst, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := built.Bytes(); !bytes.Equal(b, test.data) {
+			t.Errorf("expected %x, got %x", test.data, b)
+		}
+
+		terminator, err := ParseTerminatorWithLCPDU(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(terminator.Bytes(), header.Bytes()) || terminator.SrcID() != test.src || terminator.GroupCall() != test.groupCall {
+			t.Errorf("%x: expected %s, got %s", test.data, header, terminator)
+		}
+		if built, err := BuildTerminatorWithLCPDU(test.groupCall, test.src, test.dst, test.options); err != nil || !bytes.Equal(built.Bytes(), test.data) {
+			t.Errorf("%x: unexpected terminator %s, %v", test.data, built, err)
+		}
+	}
+
+	for _, data := range [][]byte{
+		make([]byte, 8),
+		{0x80, 0, 0, 0, 0, 0, 0, 0, 0},
+		{TalkerAliasHeader, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		if _, err := ParseVoiceLCHeaderPDU(data); err == nil {
+			t.Errorf("%x: expected error", data)
+		}
+	}
+	if _, err := BuildVoiceLCHeaderPDU(true, 1, 1<<24, serviceoptions.ServiceOptions{}); err == nil {
+		t.Error("expected error for destination exceeding 24 bits")
+	}
+}
@@ -0,0 +1,356 @@
+// Package mmdvm implements the DMR frames of the MMDVM host protocol, spoken by
+// MMDVM modems over a serial port or UDP. It lets a local modem be bridged to a
+// Homebrew master using the same dmr.Packet type.
+//
+// The modem is expected to be configured and in DMR mode, the package only
+// exchanges the DMR data frames with it.
+package mmdvm
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/op/go-logging"
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/lc"
+)
+
+var log = logging.MustGetLogger("dmr/mmdvm")
+
+// FrameStart marks the start of every frame of the host protocol.
+const FrameStart = 0xe0
+
+// Commands carrying DMR frames, see the MMDVM firmware SerialPort.cpp.
+const (
+	CmdDMRData1 = 0x18 // DMR frame on slot 1
+	CmdDMRLost1 = 0x19 // Signal lost on slot 1
+	CmdDMRData2 = 0x1a // DMR frame on slot 2
+	CmdDMRLost2 = 0x1b // Signal lost on slot 2
+)
+
+// Control byte flags, preceding the 33 bytes of a DMR frame. Voice bursts
+// without a SYNC carry their position in the superframe instead, in the low
+// bits like the data type of data bursts.
+const (
+	syncAudio = 0x20 // Voice SYNC, burst A
+	syncData  = 0x40 // Data SYNC, the low nibble is the data type
+)
+
+// FrameSize is the size of a DMR data frame: start, length, command, control
+// byte and the 33 bytes of the burst. Frames received from the modem may have
+// the RSSI appended.
+const FrameSize = 4 + 33
+
+var errClosed = errors.New("mmdvm: closed")
+
+// MarshalFrame packs the burst of p in a DMR data frame for its timeslot. The
+// addressing isn't part of the frame, the modem transmits the burst as is.
+func MarshalFrame(p *dmr.Packet) ([]byte, error) {
+	if len(p.Data) != 33 {
+		return nil, fmt.Errorf("mmdvm: expected 33 data bytes, got %d", len(p.Data))
+	}
+
+	var data = make([]byte, FrameSize)
+	data[0] = FrameStart
+	data[1] = FrameSize
+	data[2] = CmdDMRData1
+	if p.Timeslot&0x01 == 1 {
+		data[2] = CmdDMRData2
+	}
+
+	switch {
+	case p.DataType == dmr.VoiceBurstA:
+		data[3] = syncAudio
+	case p.DataType > dmr.VoiceBurstA && p.DataType <= dmr.VoiceBurstF:
+		data[3] = p.DataType - dmr.VoiceBurstA
+	case p.DataType < dmr.VoiceBurstA:
+		data[3] = syncData | p.DataType
+	default:
+		return nil, fmt.Errorf("mmdvm: unsupported data type %d", p.DataType)
+	}
+	copy(data[4:], p.Data)
+	return data, nil
+}
+
+// UnmarshalFrame parses a DMR data frame into a packet carrying the timeslot,
+// data type and burst. The addressing isn't part of the frame, see MMDVM for
+// recovering it from the link control.
+func UnmarshalFrame(data []byte) (*dmr.Packet, error) {
+	if len(data) < FrameSize {
+		return nil, fmt.Errorf("mmdvm: expected at least %d bytes, got %d", FrameSize, len(data))
+	}
+	if data[0] != FrameStart {
+		return nil, fmt.Errorf("mmdvm: expected frame start %#02x, got %#02x", FrameStart, data[0])
+	}
+	if int(data[1]) != len(data) {
+		return nil, fmt.Errorf("mmdvm: frame length %d doesn't match %d bytes", data[1], len(data))
+	}
+
+	var p = &dmr.Packet{}
+	switch data[2] {
+	case CmdDMRData1:
+	case CmdDMRData2:
+		p.Timeslot = 1
+	default:
+		return nil, fmt.Errorf("mmdvm: expected DMR data command, got %#02x", data[2])
+	}
+
+	switch {
+	case data[3]&syncData != 0:
+		p.DataType = data[3] & 0x0f
+	case data[3]&syncAudio != 0:
+		p.DataType = dmr.VoiceBurstA
+	case data[3]&0x0f < 6:
+		p.DataType = dmr.VoiceBurstA + data[3]&0x0f
+	default:
+		return nil, fmt.Errorf("mmdvm: invalid voice sequence %d", data[3]&0x0f)
+	}
+
+	var pData = make([]byte, 33)
+	copy(pData, data[4:FrameSize])
+	p.SetData(pData)
+	return p, nil
+}
+
+// stream tracks the addressing of the transmission received on a timeslot.
+type stream struct {
+	streamID uint32
+	srcID    uint32
+	dstID    uint32
+	callType uint8
+	sequence uint8
+	embedded lc.EmbeddedLCAssembler
+}
+
+// MMDVM is a repeater for a modem speaking the MMDVM host protocol. The
+// addressing of received packets is taken from the voice LC header, or from
+// the embedded LC on late entry, and a stream ID is picked per transmission.
+type MMDVM struct {
+	// RepeaterID is set on the received packets.
+	RepeaterID uint32
+
+	conn   io.ReadWriteCloser
+	pf     dmr.PacketFunc
+	mutex  sync.Mutex
+	closed bool
+	stream [2]*stream
+}
+
+// New returns an MMDVM speaking to the modem over conn, a serial port or a
+// connected UDP socket.
+func New(conn io.ReadWriteCloser, repeaterID uint32) *MMDVM {
+	return &MMDVM{
+		RepeaterID: repeaterID,
+		conn:       conn,
+	}
+}
+
+// Active returns whether the modem connection is open.
+func (m *MMDVM) Active() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return !m.closed
+}
+
+// Close closes the modem connection, which stops ListenAndServe.
+func (m *MMDVM) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	return m.conn.Close()
+}
+
+// ListenAndServe reads the frames sent by the modem, and passes the DMR
+// packets to the PacketFunc. Frames of other modes and status replies are
+// skipped.
+func (m *MMDVM) ListenAndServe() error {
+	var r = bufio.NewReader(m.conn)
+	for {
+		frame, err := readFrame(r)
+		if err != nil {
+			if !m.Active() {
+				return nil
+			}
+			return err
+		}
+		if err := m.handle(frame); err != nil {
+			log.Errorf("error handling frame: %v\n", err)
+		}
+	}
+}
+
+// readFrame reads the next frame, skipping any garbage before the frame start.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != FrameStart {
+			continue
+		}
+		n, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if n < 3 {
+			log.Debugf("skipped frame of %d bytes\n", n)
+			continue
+		}
+		var frame = make([]byte, n)
+		frame[0], frame[1] = b, n
+		if _, err := io.ReadFull(r, frame[2:]); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+}
+
+func (m *MMDVM) handle(frame []byte) error {
+	switch frame[2] {
+	case CmdDMRData1, CmdDMRData2:
+	case CmdDMRLost1, CmdDMRLost2:
+		var timeslot = (frame[2] - CmdDMRLost1) >> 1
+		log.Debugf("signal lost on TS%d\n", timeslot+1)
+		m.stream[timeslot] = nil
+		return nil
+	default:
+		return nil
+	}
+
+	p, err := UnmarshalFrame(frame)
+	if err != nil {
+		return err
+	}
+	if !m.address(p) {
+		return nil
+	}
+	if m.pf == nil {
+		return nil
+	}
+	return m.pf(m, p)
+}
+
+// address sets the addressing and stream of a received packet. It returns
+// false for voice bursts received before the addressing is known.
+func (m *MMDVM) address(p *dmr.Packet) bool {
+	var s = m.stream[p.Timeslot]
+	p.RepeaterID = m.RepeaterID
+
+	switch p.DataType {
+	case dmr.VoiceLC, dmr.TerminatorWithLC:
+		header, err := parseFullLC(p)
+		if err != nil {
+			log.Debugf("TS%d %s ignored: %v\n", p.Timeslot+1, p.DataTypeString(), err)
+			return false
+		}
+		if s == nil || s.srcID != header.SrcID() || s.dstID != header.DstID() {
+			s = newStream(header)
+		}
+		m.stream[p.Timeslot] = s
+		if p.DataType == dmr.TerminatorWithLC {
+			m.stream[p.Timeslot] = nil
+		}
+
+	case dmr.VoiceBurstA, dmr.VoiceBurstB, dmr.VoiceBurstC, dmr.VoiceBurstD, dmr.VoiceBurstE, dmr.VoiceBurstF:
+		if s == nil {
+			// Late entry, wait for the embedded LC.
+			s = &stream{}
+			m.stream[p.Timeslot] = s
+		}
+		if s.streamID == 0 {
+			if emb, err := dmr.ParseEMB(p.EMBBits()); err == nil {
+				if err := s.embedded.AddFragment(p.DataType, emb.ColorCode, p.SyncBits()[dmr.EMBHalfBits:dmr.EMBHalfBits+dmr.EMBSignallingLCFragmentBits]); err != nil {
+					log.Debugf("TS%d late entry: %v\n", p.Timeslot+1, err)
+				}
+			}
+			if header, ok := s.embedded.LinkControl(); ok {
+				m.stream[p.Timeslot] = newStream(header)
+				log.Debugf("TS%d late entry %s\n", p.Timeslot+1, header)
+			}
+			return false
+		}
+
+	default:
+		if s == nil || s.streamID == 0 {
+			return true
+		}
+	}
+
+	p.StreamID = s.streamID
+	p.SrcID = s.srcID
+	p.DstID = s.dstID
+	p.CallType = s.callType
+	p.Sequence = s.sequence
+	s.sequence++
+	return true
+}
+
+func newStream(header *lc.VoiceLCHeaderPDU) *stream {
+	var s = &stream{
+		srcID:    header.SrcID(),
+		dstID:    header.DstID(),
+		callType: dmr.CallTypePrivate,
+	}
+	if header.GroupCall() {
+		s.callType = dmr.CallTypeGroup
+	}
+	var id = make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		id = []byte{0, 0, 0, 1}
+	}
+	s.streamID = uint32(id[0])<<24 | uint32(id[1])<<16 | uint32(id[2])<<8 | uint32(id[3])
+	if s.streamID == 0 {
+		s.streamID = 1
+	}
+	return s
+}
+
+// parseFullLC decodes the voice channel user LC of a voice LC header or
+// terminator with LC, see lc.DecodeFullLC.
+func parseFullLC(p *dmr.Packet) (*lc.VoiceLCHeaderPDU, error) {
+	data, err := lc.DecodeFullLC(p.InfoBits(), p.DataType)
+	if err != nil {
+		return nil, err
+	}
+	return lc.ParseVoiceLCHeaderPDU(data)
+}
+
+// Send transmits the burst of p on its timeslot.
+func (m *MMDVM) Send(p *dmr.Packet) error {
+	data, err := MarshalFrame(p)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return errClosed
+	}
+	_, err = m.conn.Write(data)
+	return err
+}
+
+// GetPacketFunc returns the callback for received packets.
+func (m *MMDVM) GetPacketFunc() dmr.PacketFunc {
+	return m.pf
+}
+
+// SetPacketFunc sets the callback for received packets.
+func (m *MMDVM) SetPacketFunc(f dmr.PacketFunc) {
+	m.pf = f
+}
+
+// Interface compliance check
+var _ dmr.Repeater = (*MMDVM)(nil)
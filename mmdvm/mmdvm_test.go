@@ -0,0 +1,201 @@
+package mmdvm
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/voice"
+)
+
+// testStream returns the frames of a voice stream from 2042214 to TG 91 on
+// TS2, with two superframes of voice.
+func testStream(t *testing.T) ([]*dmr.Packet, [][]byte) {
+	t.Helper()
+
+	b, err := voice.NewBuilder(2042214, 91, dmr.CallTypeGroup, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packets []*dmr.Packet
+	p, err := b.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets = append(packets, p)
+	for i := 0; i < 12; i++ {
+		if p, err = b.Voice(make([]byte, dmr.VoiceBits)); err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, p)
+	}
+	if p, err = b.Terminator(); err != nil {
+		t.Fatal(err)
+	}
+	packets = append(packets, p)
+
+	var frames [][]byte
+	for _, p := range packets {
+		frame, err := MarshalFrame(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+	return packets, frames
+}
+
+func TestMarshalFrame(t *testing.T) {
+	packets, frames := testStream(t)
+
+	// Control bytes as sent by the MMDVM firmware
+	var control = []byte{0x41, 0x20, 0x01, 0x02, 0x03, 0x04, 0x05, 0x20, 0x01, 0x02, 0x03, 0x04, 0x05, 0x42}
+	for i, frame := range frames {
+		if !bytes.Equal(frame[:4], []byte{FrameStart, FrameSize, CmdDMRData2, control[i]}) {
+			t.Fatalf("frame %d: unexpected header % x", i, frame[:4])
+		}
+		if !bytes.Equal(frame[4:], packets[i].Data) {
+			t.Fatalf("frame %d: burst not copied", i)
+		}
+
+		p, err := UnmarshalFrame(frame)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if p.Timeslot != 1 || p.DataType != packets[i].DataType || !bytes.Equal(p.Data, packets[i].Data) {
+			t.Fatalf("frame %d: expected TS2 %s, got TS%d %s", i, packets[i].DataTypeString(), p.Timeslot+1, p.DataTypeString())
+		}
+	}
+
+	// Received with the RSSI appended
+	var frame = append(append([]byte{}, frames[1]...), 0x01, 0x23)
+	frame[1] = byte(len(frame))
+	if p, err := UnmarshalFrame(frame); err != nil || p.DataType != dmr.VoiceBurstA {
+		t.Fatalf("expected voice burst A with RSSI, got %v", err)
+	}
+
+	for _, data := range [][]byte{
+		frames[0][:FrameSize-1],
+		append([]byte{0xe1}, frames[0][1:]...),
+		append([]byte{FrameStart, FrameSize, 0x10}, frames[0][3:]...),
+		append([]byte{FrameStart, FrameSize, CmdDMRData1, 0x06}, frames[0][4:]...),
+	} {
+		if _, err := UnmarshalFrame(data); err == nil {
+			t.Fatalf("expected error for % x", data[:4])
+		}
+	}
+
+	p := packets[0]
+	p.DataType = dmr.IPSCSync
+	if _, err := MarshalFrame(p); err == nil {
+		t.Fatal("expected error for IPSC sync")
+	}
+}
+
+func TestMMDVMAddressing(t *testing.T) {
+	_, frames := testStream(t)
+
+	m := New(nil, 1234)
+	var received []*dmr.Packet
+	m.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received = append(received, p)
+		return nil
+	})
+
+	for _, frame := range frames {
+		if err := m.handle(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(received) != len(frames) {
+		t.Fatalf("expected %d packets, got %d", len(frames), len(received))
+	}
+	for i, p := range received {
+		if p.SrcID != 2042214 || p.DstID != 91 || p.CallType != dmr.CallTypeGroup || p.RepeaterID != 1234 {
+			t.Fatalf("packet %d: unexpected addressing %s", i, p)
+		}
+		if p.StreamID == 0 || p.StreamID != received[0].StreamID || p.Sequence != uint8(i) {
+			t.Fatalf("packet %d: expected stream %#08x sequence %d, got %#08x %d", i, received[0].StreamID, i, p.StreamID, p.Sequence)
+		}
+	}
+	var first = received[0].StreamID
+
+	// Late entry: the voice bursts are dropped until the embedded LC is
+	// complete at burst E. The next transmission gets a new stream ID.
+	received = nil
+	for _, frame := range frames[2:] {
+		if err := m.handle(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(received) != 8 || received[0].DataType != dmr.VoiceBurstF {
+		t.Fatalf("expected 8 packets from burst F, got %d", len(received))
+	}
+	if received[0].SrcID != 2042214 || received[0].DstID != 91 || received[0].StreamID == first {
+		t.Fatalf("unexpected late entry %s", received[0])
+	}
+
+	// Signal lost ends the stream
+	if err := m.handle(frames[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.handle([]byte{FrameStart, 3, CmdDMRLost2}); err != nil {
+		t.Fatal(err)
+	}
+	if m.stream[1] != nil {
+		t.Fatal("expected stream to end on signal lost")
+	}
+}
+
+func TestMMDVMListenAndServe(t *testing.T) {
+	_, frames := testStream(t)
+
+	local, modem := net.Pipe()
+	defer modem.Close()
+	m := New(local, 1234)
+
+	var received = make(chan *dmr.Packet, len(frames))
+	m.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received <- p
+		return nil
+	})
+	var done = make(chan error)
+	go func() { done <- m.ListenAndServe() }()
+
+	// Garbage and frames of other modes are skipped
+	go modem.Write(append([]byte{0x00, 0x42, FrameStart, 4, 0x10, 0x00}, frames[0]...))
+	select {
+	case p := <-received:
+		if p.DataType != dmr.VoiceLC || p.DstID != 91 {
+			t.Fatalf("unexpected packet %s", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected voice LC header")
+	}
+
+	var data = make([]byte, FrameSize)
+	go func() {
+		if err := m.Send(&dmr.Packet{Timeslot: 1, DataType: dmr.VoiceBurstC, Data: frames[3][4:]}); err != nil {
+			t.Error(err)
+		}
+	}()
+	modem.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := modem.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, frames[3]) {
+		t.Fatalf("expected % x, got % x", frames[3], data)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("expected ListenAndServe to return nil after Close, got %v", err)
+	}
+	if m.Active() || m.Send(&dmr.Packet{Data: frames[3][4:]}) == nil {
+		t.Fatal("expected closed repeater")
+	}
+}
@@ -0,0 +1,203 @@
+// Package openbridge implements the OpenBridge protocol interconnecting DMR
+// networks, as implemented by HBlink. There's no login: both ends send DMRD
+// frames, signed with HMAC-SHA1 keyed by a shared passphrase, to the address
+// configured for the bridge.
+package openbridge
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/op/go-logging"
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/homebrew"
+)
+
+var log = logging.MustGetLogger("dmr/openbridge")
+
+// Frame layout: the Homebrew DMRD frame without the BER and RSSI, followed by
+// the HMAC-SHA1 of the frame.
+const (
+	payloadSize = 53
+	hashSize    = sha1.Size
+	FrameSize   = payloadSize + hashSize
+)
+
+// passphraseSize is the size HBlink pads and truncates the passphrase to.
+const passphraseSize = 20
+
+// ErrBadHMAC is returned for frames failing the HMAC check.
+var ErrBadHMAC = errors.New("openbridge: HMAC mismatch")
+
+var errClosed = errors.New("openbridge: closed")
+
+// Key returns the HMAC key for the passphrase, padded with zeroes or truncated
+// to 20 bytes like HBlink does.
+func Key(passphrase string) []byte {
+	var key = make([]byte, passphraseSize)
+	copy(key, passphrase)
+	return key
+}
+
+// MarshalFrame packs the packet in a DMRD frame sent by the network ID, signed
+// with the key.
+func MarshalFrame(p *dmr.Packet, networkID uint32, key []byte) ([]byte, error) {
+	data, err := homebrew.MarshalHomebrew(p, networkID)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha1.New, key)
+	mac.Write(data[:payloadSize])
+	return mac.Sum(data[:payloadSize]), nil
+}
+
+// UnmarshalFrame checks the HMAC of a DMRD frame signed with the key, and
+// parses it. The RepeaterID of the packet is the network ID of the sender.
+func UnmarshalFrame(data, key []byte) (*dmr.Packet, error) {
+	if len(data) != FrameSize {
+		return nil, fmt.Errorf("openbridge: expected %d bytes, got %d", FrameSize, len(data))
+	}
+	if !bytes.Equal(data[:4], homebrew.DMRData) {
+		return nil, fmt.Errorf("openbridge: expected %s frame, got %q", homebrew.DMRData, data[:4])
+	}
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(data[:payloadSize])
+	if !hmac.Equal(mac.Sum(nil), data[payloadSize:]) {
+		return nil, ErrBadHMAC
+	}
+
+	// No BER and RSSI over OpenBridge
+	var frame = make([]byte, payloadSize+2)
+	copy(frame, data[:payloadSize])
+	return homebrew.UnmarshalHomebrew(frame)
+}
+
+// OpenBridge is a bridge to another network. Frames are only accepted from
+// the IP address of Target, with a valid HMAC.
+type OpenBridge struct {
+	// NetworkID is sent as the repeater ID of our frames.
+	NetworkID uint32
+
+	// Target is the address of the other end of the bridge.
+	Target *net.UDPAddr
+
+	key    []byte
+	conn   *net.UDPConn
+	pf     dmr.PacketFunc
+	mutex  sync.Mutex
+	closed bool
+}
+
+// New listens on addr for frames from target, signed with the passphrase.
+func New(networkID uint32, passphrase string, addr, target *net.UDPAddr) (*OpenBridge, error) {
+	if addr == nil {
+		return nil, errors.New("openbridge: addr can't be nil")
+	}
+	if target == nil {
+		return nil, errors.New("openbridge: target can't be nil")
+	}
+	if passphrase == "" {
+		return nil, errors.New("openbridge: passphrase can't be empty")
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, errors.New("openbridge: " + err.Error())
+	}
+	return &OpenBridge{
+		NetworkID: networkID,
+		Target:    target,
+		key:       Key(passphrase),
+		conn:      conn,
+	}, nil
+}
+
+// Active returns whether the bridge socket is open.
+func (o *OpenBridge) Active() bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return !o.closed
+}
+
+// Close closes the bridge socket, which stops ListenAndServe.
+func (o *OpenBridge) Close() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	return o.conn.Close()
+}
+
+// ListenAndServe receives the frames of the other end, and passes the packets
+// to the PacketFunc.
+func (o *OpenBridge) ListenAndServe() error {
+	var data = make([]byte, 512)
+	for {
+		n, remote, err := o.conn.ReadFromUDP(data)
+		if err != nil {
+			if !o.Active() {
+				return nil
+			}
+			return err
+		}
+		if err := o.handle(remote, data[:n]); err != nil {
+			log.Errorf("frame from %s: %v\n", remote, err)
+		}
+	}
+}
+
+func (o *OpenBridge) handle(remote *net.UDPAddr, data []byte) error {
+	if !remote.IP.Equal(o.Target.IP) {
+		log.Debugf("ignored %d bytes from unknown host %s\n", len(data), remote)
+		return nil
+	}
+	if !bytes.HasPrefix(data, homebrew.DMRData) {
+		log.Debugf("ignored %d bytes from %s, not a DMRD frame\n", len(data), remote)
+		return nil
+	}
+
+	p, err := UnmarshalFrame(data, o.key)
+	if err != nil {
+		return err
+	}
+	if o.pf == nil {
+		return nil
+	}
+	return o.pf(o, p)
+}
+
+// Send sends the packet to the other end of the bridge.
+func (o *OpenBridge) Send(p *dmr.Packet) error {
+	data, err := MarshalFrame(p, o.NetworkID, o.key)
+	if err != nil {
+		return err
+	}
+	if !o.Active() {
+		return errClosed
+	}
+	_, err = o.conn.WriteToUDP(data, o.Target)
+	return err
+}
+
+// GetPacketFunc returns the callback for received packets.
+func (o *OpenBridge) GetPacketFunc() dmr.PacketFunc {
+	return o.pf
+}
+
+// SetPacketFunc sets the callback for received packets.
+func (o *OpenBridge) SetPacketFunc(f dmr.PacketFunc) {
+	o.pf = f
+}
+
+// Interface compliance check
+var _ dmr.Repeater = (*OpenBridge)(nil)
@@ -0,0 +1,138 @@
+package openbridge
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func testPacket() *dmr.Packet {
+	p := &dmr.Packet{
+		Timeslot: 0,
+		Sequence: 7,
+		SrcID:    2042214,
+		DstID:    91,
+		StreamID: 0x1f29665b,
+		DataType: dmr.VoiceBurstA,
+		CallType: dmr.CallTypeGroup,
+		BER:      3,
+		RSSI:     60,
+	}
+	p.SetData(bytes.Repeat([]byte{0x5a}, 33))
+	return p
+}
+
+func TestFrame(t *testing.T) {
+	var (
+		key = Key("s3cr3t")
+		p   = testPacket()
+	)
+	if len(key) != 20 || !bytes.Equal(key[:6], []byte("s3cr3t")) || key[6] != 0 {
+		t.Fatalf("expected zero padded key, got %q", key)
+	}
+	if !bytes.Equal(Key("0123456789012345678901234"), []byte("01234567890123456789")) {
+		t.Fatal("expected key truncated to 20 bytes")
+	}
+
+	data, err := MarshalFrame(p, 312000, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != FrameSize {
+		t.Fatalf("expected %d bytes, got %d", FrameSize, len(data))
+	}
+	mac := hmac.New(sha1.New, key)
+	mac.Write(data[:53])
+	if !bytes.Equal(mac.Sum(nil), data[53:]) {
+		t.Fatal("expected HMAC-SHA1 of the DMRD frame")
+	}
+
+	got, err := UnmarshalFrame(data, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SrcID != p.SrcID || got.DstID != p.DstID || got.StreamID != p.StreamID || got.Sequence != p.Sequence ||
+		got.DataType != p.DataType || got.RepeaterID != 312000 || !bytes.Equal(got.Data, p.Data) {
+		t.Fatalf("expected %s, got %s", p, got)
+	}
+	if got.BER != 0 || got.RSSI != 0 {
+		t.Fatalf("expected no BER and RSSI, got %d and %d", got.BER, got.RSSI)
+	}
+
+	// Tampered frame, wrong key
+	var tampered = append([]byte{}, data...)
+	tampered[10] ^= 0x01
+	if _, err := UnmarshalFrame(tampered, key); err != ErrBadHMAC {
+		t.Fatalf("expected %v for tampered frame, got %v", ErrBadHMAC, err)
+	}
+	if _, err := UnmarshalFrame(data, Key("other")); err != ErrBadHMAC {
+		t.Fatalf("expected %v for wrong key, got %v", ErrBadHMAC, err)
+	}
+	if _, err := UnmarshalFrame(data[:FrameSize-1], key); err == nil {
+		t.Fatal("expected error for short frame")
+	}
+}
+
+func TestOpenBridge(t *testing.T) {
+	local := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	a, err := New(1, "s3cr3t", local, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := New(2, "s3cr3t", local, a.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	a.Target = b.conn.LocalAddr().(*net.UDPAddr)
+
+	var received = make(chan *dmr.Packet, 2)
+	a.SetPacketFunc(func(_ dmr.Repeater, p *dmr.Packet) error {
+		received <- p
+		return nil
+	})
+	var done = make(chan error)
+	go func() { done <- a.ListenAndServe() }()
+
+	// Frames signed with another passphrase are dropped
+	data, err := MarshalFrame(testPacket(), 2, Key("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.conn.WriteToUDP(data, b.Target); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Send(testPacket()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case p := <-received:
+		if p.RepeaterID != 2 || p.DstID != 91 {
+			t.Fatalf("unexpected packet %s", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected packet")
+	}
+	select {
+	case p := <-received:
+		t.Fatalf("unexpected packet %s", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("expected ListenAndServe to return nil after Close, got %v", err)
+	}
+	if a.Send(testPacket()) == nil {
+		t.Fatal("expected error sending on a closed bridge")
+	}
+}
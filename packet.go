@@ -1,5 +1,7 @@
 package dmr
 
+import "fmt"
+
 // Data Type information element definitions, DMR Air Interface (AI) protocol, Table 6.1
 const (
 	PrivacyIndicator              uint8 = iota // Privacy Indicator information in a standalone burst
@@ -138,6 +140,72 @@ func (p *Packet) VoiceBits() []byte {
 	return b
 }
 
+// VoiceBitsChecked is the number of bits checked for errors per voice burst,
+// the BER of a voice frame is the number of bit errors found in these.
+const VoiceBitsChecked = 141
+
+// BERPercent returns the bit error rate of a voice frame in percent. Following
+// MMDVM, the BER byte is the number of bit errors found in VoiceBitsChecked.
+func (p *Packet) BERPercent() float64 {
+	return float64(p.BER) * 100 / VoiceBitsChecked
+}
+
+// RSSIdBm returns the received signal strength in dBm, the RSSI is reported as
+// the magnitude of the (negative) signal strength.
+func (p *Packet) RSSIdBm() int {
+	return -int(p.RSSI)
+}
+
+// CallTypeString returns the name of the call type, including unknown ones.
+func (p *Packet) CallTypeString() string {
+	if name, ok := CallTypeName[p.CallType]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown call type %d", p.CallType)
+}
+
+// DataTypeString returns the name of the data type, including unknown ones.
+func (p *Packet) DataTypeString() string {
+	if name, ok := DataTypeName[p.DataType]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown data type %d", p.DataType)
+}
+
+// String describes the packet as source, destination, timeslot, call type,
+// data type and stream, for example
+// "2042214 → TG 91 TS2 group voice (burst A) stream 0x1f29665b".
+func (p *Packet) String() string {
+	var dst = fmt.Sprintf("%d", p.DstID)
+	if p.CallType == CallTypeGroup {
+		dst = fmt.Sprintf("TG %d", p.DstID)
+	}
+	return fmt.Sprintf("%d → %s TS%d %s %s stream %#08x",
+		p.SrcID, dst, p.Timeslot+1, p.CallTypeString(), p.DataTypeString(), p.StreamID)
+}
+
+// Validate checks the addressing of the packet for combinations no radio
+// sends: an invalid timeslot or call type, a group call to ID 0, IDs exceeding
+// 24 bits, stream ID 0, which is reserved for no stream, and data types outside
+// the DMR Air Interface range.
+func (p *Packet) Validate() error {
+	switch {
+	case p.Timeslot > 1:
+		return fmt.Errorf("dmr: invalid timeslot %d", p.Timeslot)
+	case p.CallType != CallTypeGroup && p.CallType != CallTypePrivate:
+		return fmt.Errorf("dmr: invalid call type %d", p.CallType)
+	case p.CallType == CallTypeGroup && p.DstID == 0:
+		return fmt.Errorf("dmr: group call to TG 0")
+	case p.SrcID > 0xffffff || p.DstID > 0xffffff:
+		return fmt.Errorf("dmr: ID exceeds 24 bits, %d → %d", p.SrcID, p.DstID)
+	case p.StreamID == 0:
+		return fmt.Errorf("dmr: reserved stream ID 0")
+	case p.DataType > VoiceBurstF:
+		return fmt.Errorf("dmr: invalid data type %d", p.DataType)
+	}
+	return nil
+}
+
 func (p *Packet) SetData(data []byte) {
 	p.Data = data
 	p.Bits = BytesToBits(data)
@@ -0,0 +1,84 @@
+package dmr
+
+import "testing"
+
+func TestPacketString(t *testing.T) {
+	p := &Packet{
+		Timeslot: 1,
+		SrcID:    2042214,
+		DstID:    91,
+		StreamID: 0x1f29665b,
+		DataType: VoiceBurstA,
+		CallType: CallTypeGroup,
+	}
+	if s := p.String(); s != "2042214 → TG 91 TS2 group voice (burst A) stream 0x1f29665b" {
+		t.Fatalf("unexpected %q", s)
+	}
+
+	// Unknown values are named too.
+	p.DstID = 2042215
+	p.CallType = 7
+	p.DataType = 0xff
+	if s := p.String(); s != "2042214 → 2042215 TS2 unknown call type 7 unknown data type 255 stream 0x1f29665b" {
+		t.Fatalf("unexpected %q", s)
+	}
+}
+
+func TestPacketQuality(t *testing.T) {
+	var tests = []struct {
+		ber, rssi uint8
+		percent   float64
+		dBm       int
+	}{
+		{0, 0, 0, 0},
+		{3, 47, 300.0 / 141, -47},
+		{141, 120, 100, -120},
+	}
+	for _, test := range tests {
+		p := &Packet{BER: test.ber, RSSI: test.rssi}
+		if ber := p.BERPercent(); ber != test.percent {
+			t.Errorf("BER %d: expected %f%%, got %f%%", test.ber, test.percent, ber)
+		}
+		if rssi := p.RSSIdBm(); rssi != test.dBm {
+			t.Errorf("RSSI %d: expected %d dBm, got %d dBm", test.rssi, test.dBm, rssi)
+		}
+	}
+}
+
+func TestPacketValidate(t *testing.T) {
+	valid := Packet{
+		Timeslot: 1,
+		SrcID:    2042214,
+		DstID:    91,
+		StreamID: 0x1f29665b,
+		DataType: VoiceBurstA,
+		CallType: CallTypeGroup,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid packet, got %v", err)
+	}
+	private := valid
+	private.CallType = CallTypePrivate
+	private.DstID = 0
+	if err := private.Validate(); err != nil {
+		t.Fatalf("expected private call to ID 0 to be valid, got %v", err)
+	}
+
+	var tests = map[string]func(p *Packet){
+		"timeslot":  func(p *Packet) { p.Timeslot = 2 },
+		"call type": func(p *Packet) { p.CallType = 2 },
+		"TG 0":      func(p *Packet) { p.DstID = 0 },
+		"source ID": func(p *Packet) { p.SrcID = 0x1000000 },
+		"dest ID":   func(p *Packet) { p.DstID = 0x1000000 },
+		"stream ID": func(p *Packet) { p.StreamID = 0 },
+		"IPSC sync": func(p *Packet) { p.DataType = IPSCSync },
+		"data type": func(p *Packet) { p.DataType = 0xff },
+	}
+	for name, invalidate := range tests {
+		p := valid
+		invalidate(&p)
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package dmr
 
 import (
 	"bytes"
+	"fmt"
 )
 
 // Table 9.2: SYNC Patterns
@@ -67,3 +68,68 @@ func SyncPattern(bits []byte) uint8 {
 		return SyncPatternUnknown
 	}
 }
+
+// Burst classes, based on the contents of the SYNC field of a burst.
+const (
+	BurstClassUnknown  uint8 = iota
+	BurstClassVoice          // Voice SYNC, voice burst A
+	BurstClassData           // Data SYNC
+	BurstClassEmbedded       // Embedded signalling, voice bursts B to F
+)
+
+// BurstClassName is a map of burst class to string.
+var BurstClassName = map[uint8]string{
+	BurstClassUnknown:  "unknown",
+	BurstClassVoice:    "voice sync",
+	BurstClassData:     "data sync",
+	BurstClassEmbedded: "embedded signalling",
+}
+
+// SyncBitsFromPayload extracts the 48 SYNC bits from a 33 byte burst.
+func SyncBitsFromPayload(payload []byte) ([]byte, error) {
+	if len(payload) != PayloadBits/8 {
+		return nil, fmt.Errorf("dmr/sync: expected %d payload bytes, got %d", PayloadBits/8, len(payload))
+	}
+	var bits = BytesToBits(payload)
+	return bits[SyncOffsetBits : SyncOffsetBits+SyncBits], nil
+}
+
+// ClassifyBurst reads the SYNC field from a 33 byte burst and returns the SYNC
+// pattern and the burst class. Bursts without a SYNC pattern are classified as
+// embedded signalling if the field holds a valid EMB.
+func ClassifyBurst(payload []byte) (pattern uint8, class uint8, err error) {
+	sync, err := SyncBitsFromPayload(payload)
+	if err != nil {
+		return SyncPatternUnknown, BurstClassUnknown, err
+	}
+
+	pattern = SyncPattern(sync)
+	switch pattern {
+	case SyncPatternBSSourcedVoice, SyncPatternMSSourcedVoice, SyncPatternDirectVoiceTS1, SyncPatternDirectVoiceTS2:
+		return pattern, BurstClassVoice, nil
+	case SyncPatternBSSourcedData, SyncPatternMSSourcedData, SyncPatternMSSourcedRC, SyncPatternDirectDataTS1, SyncPatternDirectDataTS2:
+		return pattern, BurstClassData, nil
+	}
+
+	if bits, err := ParseEMBBitsFromSync(sync); err == nil {
+		if _, err := ParseEMB(bits); err == nil {
+			return pattern, BurstClassEmbedded, nil
+		}
+	}
+	return pattern, BurstClassUnknown, nil
+}
+
+// DataTypeBurstClass returns the burst class expected for a data type, to
+// validate the data type claimed by a frame against its payload.
+func DataTypeBurstClass(dataType uint8) uint8 {
+	switch dataType {
+	case VoiceBurstA:
+		return BurstClassVoice
+	case VoiceBurstB, VoiceBurstC, VoiceBurstD, VoiceBurstE, VoiceBurstF:
+		return BurstClassEmbedded
+	case IPSCSync, UnknownSlotType:
+		return BurstClassUnknown
+	default:
+		return BurstClassData
+	}
+}
@@ -0,0 +1,65 @@
+package dmr
+
+import (
+	"testing"
+
+	"github.com/polkabana/go-dmr/crc/quadres_16_7"
+)
+
+func testBurst(sync []byte) []byte {
+	var bits = make([]byte, PayloadBits)
+	copy(bits[SyncOffsetBits:], sync)
+	return BitsToBytes(bits)
+}
+
+func TestClassifyBurst(t *testing.T) {
+	tests := []struct {
+		sync    []byte
+		pattern uint8
+		class   uint8
+	}{
+		{bsSourcedVoice, SyncPatternBSSourcedVoice, BurstClassVoice},
+		{msSourcedVoice, SyncPatternMSSourcedVoice, BurstClassVoice},
+		{bsSourcedData, SyncPatternBSSourcedData, BurstClassData},
+		{msSourcedData, SyncPatternMSSourcedData, BurstClassData},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, SyncPatternUnknown, BurstClassUnknown},
+	}
+
+	for _, test := range tests {
+		pattern, class, err := ClassifyBurst(testBurst(BytesToBits(test.sync)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pattern != test.pattern || class != test.class {
+			t.Fatalf("sync %x: got %s/%s, want %s/%s", test.sync,
+				SyncPatternName[pattern], BurstClassName[class],
+				SyncPatternName[test.pattern], BurstClassName[test.class])
+		}
+	}
+
+	// Color code 9, first fragment, with the LC fragment bits set
+	var emb = []byte{1, 0, 0, 1, 0, 0, 1}
+	emb = append(emb, quadres_16_7.ParityBits(emb)...)
+	var sync = make([]byte, SyncBits)
+	copy(sync, emb[:8])
+	for i := 8; i < 40; i++ {
+		sync[i] = 1
+	}
+	copy(sync[40:], emb[8:])
+
+	payload := testBurst(sync)
+	_, class, err := ClassifyBurst(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if class != BurstClassEmbedded {
+		t.Fatalf("expected embedded signalling, got %s", BurstClassName[class])
+	}
+	if DataTypeBurstClass(VoiceBurstC) != class {
+		t.Fatal("expected voice burst C to carry embedded signalling")
+	}
+
+	if _, _, err := ClassifyBurst(payload[:32]); err == nil {
+		t.Fatal("expected error for short payload")
+	}
+}
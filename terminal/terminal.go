@@ -364,13 +364,13 @@ func (t *Terminal) voiceCallStart(p *dmr.Packet) error {
 func (t *Terminal) handlePacket(r dmr.Repeater, p *dmr.Packet) error {
 	// Ignore packets not addressed to us or any of the talk groups we monitor
 	if false && !t.accept[p.DstID] {
-		//log.Debugf("[%d->%d] (%s, %#04b): ignored, not sent to me", p.SrcID, p.DstID, dmr.DataTypeName[p.DataType], p.DataType)
+		//log.Debugf("[%d->%d] (%s, %#04b): ignored, not sent to me", p.SrcID, p.DstID, p.DataTypeString(), p.DataType)
 		return nil
 	}
 
 	var err error
 
-	t.warningf(p, "handle packet: %s", dmr.DataTypeName[p.DataType])
+	t.warningf(p, "handle packet: %s", p.DataTypeString())
 	//log.Debug(hex.Dump(p.Data))
 
 	//
@@ -394,7 +394,7 @@ func (t *Terminal) handlePacket(r dmr.Repeater, p *dmr.Packet) error {
 		err = t.handleTerminatorWithLC(p)
 		return nil
 	default:
-		t.warningf(p, "unhandled packet: %s", dmr.DataTypeName[p.DataType])
+		t.warningf(p, "unhandled packet: %s", p.DataTypeString())
 		log.Debug(hex.Dump(p.Data))
 		return nil
 	}
@@ -146,6 +146,45 @@ func (v *VBPTC) GetData(bits []byte) error {
 	return nil
 }
 
+// Encode encodes the data bits into a variable BPTC matrix with the given
+// number of rows, each data row protected by Hamming (16,11) and the last row
+// holding the column parity bits. The matrix is returned in transmit order,
+// column by column.
+func Encode(data []byte, rows uint8) ([]byte, error) {
+	if rows < 2 {
+		return nil, fmt.Errorf("vbptc: need at least 2 rows, got %d", rows)
+	}
+	if len(data) != int(rows-1)*11 {
+		return nil, fmt.Errorf("vbptc: expected %d data bits, got %d", int(rows-1)*11, len(data))
+	}
+
+	var (
+		row, col uint8
+		matrix   = make([]byte, int(rows)*16)
+		bits     = make([]byte, int(rows)*16)
+		errs     = make([]byte, 5)
+	)
+	for row = 0; row < rows-1; row++ {
+		copy(matrix[row*16:], data[row*11:row*11+11])
+		getParity(matrix[row*16:], errs)
+		copy(matrix[row*16+11:], errs)
+	}
+	for col = 0; col < 16; col++ {
+		var parity uint8
+		for row = 0; row < rows-1; row++ {
+			parity ^= matrix[row*16+col]
+		}
+		matrix[(rows-1)*16+col] = parity
+	}
+
+	for col = 0; col < 16; col++ {
+		for row = 0; row < rows; row++ {
+			bits[int(col)*int(rows)+int(row)] = matrix[row*16+col]
+		}
+	}
+	return bits, nil
+}
+
 func checkRow(bits, errs []byte) bool {
 	if bits == nil || errs == nil {
 		return false
@@ -88,6 +88,23 @@ type EmbeddedSignallingLC struct {
 	Checksum []byte
 }
 
+// NewEmbeddedSignallingLC returns the embedded signalling LC for the 9 LC
+// bytes, with the 5 bit checksum calculated. See DMR AI spec. page 141.
+func NewEmbeddedSignallingLC(data []byte) (*EmbeddedSignallingLC, error) {
+	if len(data) != 9 {
+		return nil, fmt.Errorf("dmr/emb lc: expected 9 LC bytes, got %d", len(data))
+	}
+
+	var sum uint16
+	for _, b := range data {
+		sum += uint16(b)
+	}
+	return &EmbeddedSignallingLC{
+		Bits:     BytesToBits(data),
+		Checksum: toBits(uint8(sum%31) << 3)[:5],
+	}, nil
+}
+
 // Check verifies the checksum in the embedded signalling LC.
 func (eslc *EmbeddedSignallingLC) Check() bool {
 	var checksum uint8
@@ -0,0 +1,185 @@
+// Package voice builds the bursts of a DMR voice transmission.
+package voice
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/lc"
+	"github.com/polkabana/go-dmr/vbptc"
+)
+
+// BurstDuration is the air time of a single voice burst.
+const BurstDuration = time.Millisecond * 60
+
+// Builder builds the packets of a voice stream: the voice LC header, the voice
+// bursts A to F carrying the embedded LC and the terminator with LC.
+type Builder struct {
+	SrcID     uint32
+	DstID     uint32
+	CallType  uint8
+	Timeslot  uint8
+	ColorCode uint8
+	StreamID  uint32
+
+	sequence   uint8
+	burst      uint8
+	superframe int
+	embedded   []byte
+	alias      []*lc.LC
+}
+
+// NewBuilder returns a Builder for a new stream with a random stream ID.
+func NewBuilder(srcID, dstID uint32, callType, timeslot, colorCode uint8) (*Builder, error) {
+	var id = make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &Builder{
+		SrcID:     srcID,
+		DstID:     dstID,
+		CallType:  callType,
+		Timeslot:  timeslot,
+		ColorCode: colorCode,
+		StreamID:  uint32(id[0])<<24 | uint32(id[1])<<16 | uint32(id[2])<<8 | uint32(id[3]),
+	}, nil
+}
+
+// LC returns the voice channel user Link Control for the stream.
+func (b *Builder) LC() *lc.LC {
+	var l = &lc.LC{
+		CallType: b.CallType,
+		Opcode:   lc.GroupVoiceChannelUser,
+		VoiceChannelUser: &lc.VoiceChannelUserPDU{
+			DstID: b.DstID,
+			SrcID: b.SrcID,
+		},
+	}
+	if b.CallType == dmr.CallTypePrivate {
+		l.Opcode = lc.UnitToUnitVoiceChannelUser
+	}
+	return l
+}
+
+// TalkerAlias sets the talker alias to embed in the stream. The talker alias
+// header and blocks take turns with the voice LC in the embedded signalling,
+// every other superframe carries the next one of them.
+func (b *Builder) TalkerAlias(name string, format uint8) error {
+	header, blocks, err := lc.BuildTalkerAlias(name, format)
+	if err != nil {
+		return err
+	}
+
+	b.alias = []*lc.LC{{Opcode: lc.TalkerAliasHeader, TalkerAliasHeader: header}}
+	for i, block := range blocks {
+		l := &lc.LC{Opcode: lc.TalkerAliasBlk1 + uint8(i)}
+		l.TalkerAliasBlocks[i] = block
+		b.alias = append(b.alias, l)
+	}
+	return nil
+}
+
+// Header returns the voice LC header, which starts the stream.
+func (b *Builder) Header() (*dmr.Packet, error) {
+	b.burst = 0
+	b.superframe = 0
+	return b.fullLC(dmr.VoiceLC)
+}
+
+// Voice returns the next voice burst carrying the voice bits, cycling through
+// bursts A to F of a superframe.
+func (b *Builder) Voice(bits []byte) (*dmr.Packet, error) {
+	var signal []byte
+	switch b.burst {
+	case 0:
+		signal = dmr.VoiceSyncBits()
+	case 5:
+		// Burst F carries a null embedded message.
+		var err error
+		if signal, err = dmr.BuildEmbeddedSignalling(b.ColorCode, dmr.SingleFragment, make([]byte, dmr.EMBSignallingLCFragmentBits)); err != nil {
+			return nil, err
+		}
+	default:
+		if b.burst == 1 {
+			if err := b.buildEmbedded(); err != nil {
+				return nil, err
+			}
+		}
+
+		var (
+			err  error
+			lcss = dmr.Continuation
+			o    = int(b.burst-1) * dmr.EMBSignallingLCFragmentBits
+		)
+		switch b.burst {
+		case 1:
+			lcss = dmr.FirstFragment
+		case 4:
+			lcss = dmr.LastFragment
+		}
+		if signal, err = dmr.BuildEmbeddedSignalling(b.ColorCode, lcss, b.embedded[o:o+dmr.EMBSignallingLCFragmentBits]); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := dmr.BuildVoiceBurst(bits, signal)
+	if err != nil {
+		return nil, err
+	}
+	p := b.packet(dmr.VoiceBurstA + b.burst)
+	p.SetData(data)
+	if b.burst = (b.burst + 1) % 6; b.burst == 0 {
+		b.superframe++
+	}
+	return p, nil
+}
+
+// Terminator returns the terminator with LC, which ends the stream.
+func (b *Builder) Terminator() (*dmr.Packet, error) {
+	return b.fullLC(dmr.TerminatorWithLC)
+}
+
+// buildEmbedded prepares the embedded signalling of the superframe: the voice
+// LC, or in every other superframe the next talker alias LC, if any.
+func (b *Builder) buildEmbedded() error {
+	var l = b.LC()
+	if len(b.alias) > 0 && b.superframe%2 == 1 {
+		l = b.alias[(b.superframe/2)%len(b.alias)]
+	}
+
+	eslc, err := dmr.NewEmbeddedSignallingLC(l.Bytes())
+	if err != nil {
+		return err
+	}
+	b.embedded, err = vbptc.Encode(eslc.Interleave(), 8)
+	return err
+}
+
+func (b *Builder) fullLC(dataType uint8) (*dmr.Packet, error) {
+	info, err := lc.EncodeFullLC(b.LC().Bytes(), dataType)
+	if err != nil {
+		return nil, err
+	}
+	burst, err := dmr.BuildDataBurst(info, b.ColorCode, dataType)
+	if err != nil {
+		return nil, err
+	}
+	p := b.packet(dataType)
+	p.SetData(burst)
+	return p, nil
+}
+
+func (b *Builder) packet(dataType uint8) *dmr.Packet {
+	p := &dmr.Packet{
+		Timeslot: b.Timeslot,
+		Sequence: b.sequence,
+		SrcID:    b.SrcID,
+		DstID:    b.DstID,
+		StreamID: b.StreamID,
+		DataType: dataType,
+		CallType: b.CallType,
+	}
+	b.sequence++
+	return p
+}
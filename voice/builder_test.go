@@ -0,0 +1,198 @@
+package voice
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/polkabana/go-dmr"
+	"github.com/polkabana/go-dmr/lc"
+	"github.com/polkabana/go-dmr/vbptc"
+)
+
+func testFullLC(t *testing.T, p *dmr.Packet) *lc.LC {
+	t.Helper()
+
+	data, err := lc.DecodeFullLC(p.InfoBits(), p.DataType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := lc.ParseLC(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func testVoice() []byte {
+	var voice = make([]byte, dmr.VoiceBits)
+	for i := range voice {
+		voice[i] = uint8(i % 3 % 2)
+	}
+	return voice
+}
+
+// testEmbeddedLC decodes the embedded LC of each superframe in the voice bursts.
+func testEmbeddedLC(t *testing.T, packets []*dmr.Packet, colorCode uint8) []*lc.LC {
+	t.Helper()
+
+	var (
+		signalling = vbptc.New(8)
+		embedded   []*lc.LC
+	)
+	for i, p := range packets {
+		if p.DataType == dmr.VoiceBurstA {
+			continue
+		}
+
+		emb, err := dmr.ParseEMB(p.EMBBits())
+		if err != nil {
+			t.Fatalf("burst %d: %v", i, err)
+		}
+		if emb.ColorCode != colorCode {
+			t.Fatalf("burst %d: unexpected EMB %s", i, emb)
+		}
+		if emb.LCSS == dmr.SingleFragment {
+			continue
+		}
+		if emb.LCSS == dmr.FirstFragment {
+			signalling.Clear()
+		}
+		frag, err := dmr.ParseEmbeddedSignallingLCFromSyncBits(p.SyncBits())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signalling.AddBurst(frag); err != nil {
+			t.Fatal(err)
+		}
+		if emb.LCSS != dmr.LastFragment {
+			continue
+		}
+
+		if err := signalling.CheckAndRepair(); err != nil {
+			t.Fatal(err)
+		}
+		var bits = make([]byte, 77)
+		if err := signalling.GetData(bits); err != nil {
+			t.Fatal(err)
+		}
+		eslc, err := dmr.DeinterleaveEmbeddedSignallingLC(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !eslc.Check() {
+			t.Fatalf("burst %d: embedded LC checksum failed", i)
+		}
+		l, err := lc.ParseLC(dmr.BitsToBytes(eslc.Bits))
+		if err != nil {
+			t.Fatal(err)
+		}
+		embedded = append(embedded, l)
+	}
+	return embedded
+}
+
+func TestBuilder(t *testing.T) {
+	b, err := NewBuilder(2042214, 91, dmr.CallTypeGroup, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := b.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.DataType != dmr.VoiceLC || header.StreamID != b.StreamID || header.Timeslot != 1 {
+		t.Fatalf("unexpected header %+v", header)
+	}
+	if slotType := header.SlotType(); slotType[0] != 3<<4|dmr.VoiceLC {
+		t.Fatalf("unexpected slot type %#02x", slotType[0])
+	}
+	if l := testFullLC(t, header); l.Opcode != lc.GroupVoiceChannelUser ||
+		l.VoiceChannelUser.SrcID != 2042214 || l.VoiceChannelUser.DstID != 91 {
+		t.Fatalf("unexpected header LC %s", l)
+	}
+
+	var (
+		voice   = testVoice()
+		packets []*dmr.Packet
+	)
+	for i := 0; i < 12; i++ {
+		p, err := b.Voice(voice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.DataType != dmr.VoiceBurstA+uint8(i%6) || p.Sequence != uint8(i+1) {
+			t.Fatalf("burst %d: unexpected data type %d, sequence %d", i, p.DataType, p.Sequence)
+		}
+		if !bytes.Equal(p.VoiceBits(), voice) {
+			t.Fatalf("burst %d: voice bits mismatch", i)
+		}
+		if i%6 == 0 {
+			if pattern := dmr.SyncPattern(p.SyncBits()); pattern != dmr.SyncPatternBSSourcedVoice {
+				t.Fatalf("burst %d: unexpected sync pattern %s", i, dmr.SyncPatternName[pattern])
+			}
+		}
+		packets = append(packets, p)
+	}
+	embedded := testEmbeddedLC(t, packets, 3)
+	if len(embedded) != 2 {
+		t.Fatalf("expected 2 embedded LCs, got %d", len(embedded))
+	}
+	for i, l := range embedded {
+		if l.VoiceChannelUser.SrcID != 2042214 || l.VoiceChannelUser.DstID != 91 {
+			t.Fatalf("superframe %d: unexpected embedded LC %s", i, l)
+		}
+	}
+
+	terminator, err := b.Terminator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terminator.DataType != dmr.TerminatorWithLC {
+		t.Fatalf("unexpected terminator data type %d", terminator.DataType)
+	}
+	testFullLC(t, terminator)
+}
+
+func TestBuilderTalkerAlias(t *testing.T) {
+	b, err := NewBuilder(2042214, 91, dmr.CallTypeGroup, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const name = "PD0MZ Maurice"
+	if err := b.TalkerAlias(name, lc.FormatUTF8); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Header(); err != nil {
+		t.Fatal(err)
+	}
+	var packets []*dmr.Packet
+	for i := 0; i < 4*6; i++ {
+		p, err := b.Voice(testVoice())
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, p)
+	}
+
+	embedded := testEmbeddedLC(t, packets, 3)
+	var expect = []uint8{lc.GroupVoiceChannelUser, lc.TalkerAliasHeader, lc.GroupVoiceChannelUser, lc.TalkerAliasBlk1}
+	if len(embedded) != len(expect) {
+		t.Fatalf("expected %d embedded LCs, got %d", len(expect), len(embedded))
+	}
+	for i, l := range embedded {
+		if l.Opcode != expect[i] {
+			t.Fatalf("superframe %d: expected opcode %d, got %d", i, expect[i], l.Opcode)
+		}
+	}
+
+	header := embedded[1].TalkerAliasHeader
+	if header.DataFormat != lc.FormatUTF8 || int(header.Length) != len(name) {
+		t.Fatalf("unexpected talker alias header %s", header)
+	}
+	alias := append(append([]byte{}, header.Data...), embedded[3].TalkerAliasBlocks[0].Data...)
+	if got := string(alias[:header.Length]); got != name {
+		t.Fatalf("expected talker alias %q, got %q", name, got)
+	}
+}
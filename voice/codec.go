@@ -0,0 +1,64 @@
+package voice
+
+import (
+	"github.com/polkabana/go-dmr"
+)
+
+// AMBEFrameBits is the size of a single AMBE+2 frame, a voice burst carries
+// three of them.
+const AMBEFrameBits = 72
+
+// SilenceFrame is an AMBE+2 coded frame of silence.
+var SilenceFrame = []byte{0xb9, 0xe8, 0x81, 0x52, 0x61, 0x73, 0x00, 0x2a, 0x6b}
+
+// Codec is the vocoder a voice stream appears to carry, see Classify.
+type Codec uint8
+
+const (
+	CodecUnknown Codec = iota // No voice bursts seen yet
+	CodecAMBE2                // Looks like AMBE+2
+	CodecInvalid              // Doesn't look like AMBE+2, e.g. silence or filler from a bridge
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecAMBE2:
+		return "AMBE+2"
+	case CodecInvalid:
+		return "invalid"
+	default:
+		return "unknown"
+	}
+}
+
+// Classify makes a best effort guess whether the 33 byte voice burst carries
+// AMBE+2 frames. Real AMBE+2 frames, including the coded silence, are never
+// constant, a burst with any frame of all identical bytes is CodecInvalid.
+func Classify(burst []byte) Codec {
+	if len(burst) != dmr.PayloadBits/8 {
+		return CodecInvalid
+	}
+
+	var (
+		bits  = dmr.BytesToBits(burst)
+		voice = make([]byte, 0, dmr.VoiceBits)
+	)
+	voice = append(voice, bits[:dmr.VoiceHalfBits]...)
+	voice = append(voice, bits[dmr.VoiceHalfBits+dmr.SignalBits:]...)
+
+	for i := 0; i < dmr.VoiceBits; i += AMBEFrameBits {
+		if constant(dmr.BitsToBytes(voice[i : i+AMBEFrameBits])) {
+			return CodecInvalid
+		}
+	}
+	return CodecAMBE2
+}
+
+func constant(data []byte) bool {
+	for _, b := range data[1:] {
+		if b != data[0] {
+			return false
+		}
+	}
+	return true
+}
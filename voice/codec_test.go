@@ -0,0 +1,35 @@
+package voice
+
+import (
+	"testing"
+
+	"github.com/polkabana/go-dmr"
+)
+
+func TestClassify(t *testing.T) {
+	// AMBE+2 coded silence, three frames
+	var silence = []byte{
+		0xb9, 0xe8, 0x81, 0x52, 0x61, 0x73, 0x00, 0x2a, 0x6b,
+		0xb9, 0xe8, 0x81, 0x52, 0x61, 0x73, 0x00, 0x2a, 0x6b,
+		0xb9, 0xe8, 0x81, 0x52, 0x61, 0x73, 0x00, 0x2a, 0x6b,
+	}
+	burst, err := dmr.BuildVoiceBurst(dmr.BytesToBits(silence), dmr.VoiceSyncBits())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codec := Classify(burst); codec != CodecAMBE2 {
+		t.Fatalf("expected %s, got %s", CodecAMBE2, codec)
+	}
+
+	burst, err = dmr.BuildVoiceBurst(make([]byte, dmr.VoiceBits), dmr.VoiceSyncBits())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codec := Classify(burst); codec != CodecInvalid {
+		t.Fatalf("expected %s for all zero burst, got %s", CodecInvalid, codec)
+	}
+
+	if codec := Classify(burst[:27]); codec != CodecInvalid {
+		t.Fatalf("expected %s for short burst, got %s", CodecInvalid, codec)
+	}
+}